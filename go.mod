@@ -0,0 +1,3 @@
+module nand2tetris
+
+go 1.21
@@ -1,24 +1,34 @@
-package main
+package assembler
 
 import (
-	"fmt"
-	"os"
+	"bytes"
+	"io"
 	"strconv"
+	"strings"
+
+	"nand2tetris/software/diagnostics"
 )
 
-func main() {
-	usage := "Usage: " + os.Args[0] + " name of the file"
-	if len(os.Args) != 2 {
-		fmt.Println(usage)
-		os.Exit(1)
-	}
+// Translates the assembly program read from the reader into the Hack machine
+// code written to the writer. All the errors found in the program are returned
+// together, nothing is written if there are any.
+func Assemble(reader io.Reader, writer io.Writer) error {
+	return AssembleWith(reader, writer, Options{})
+}
 
-	fileName := os.Args[1]
-	parser := NewParser(fileName)
-	defer parser.Close()
+// Translates the assembly program like Assemble and writes
+// the listing and the symbol table requested by the options
+func AssembleWith(reader io.Reader, writer io.Writer, options Options) error {
+	source, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	fileName := diagnostics.SourceName(reader)
+	parser := NewParser(bytes.NewReader(source), fileName)
 	symbolTable := NewSymbolTable()
 	currentInstruction := 0
 
+	// Syntax errors are reported by the second pass
 	for parser.Advance() {
 		switch parser.GetCommandType() {
 		case ADDRESS:
@@ -27,42 +37,86 @@ func main() {
 			currentInstruction++
 		case LABEL:
 			symbol := parser.GetSymbol()
-			symbolTable.AddEntry(symbol, currentInstruction)
+			symbolTable.AddLabel(symbol, currentInstruction)
 		}
 	}
 
-	parser = NewParser(fileName)
-	defer parser.Close()
-	fileSave, err := os.Create(fileName[:len(fileName)-3] + "hack")
-	if err != nil {
-		fmt.Println("Could not save file", fileName)
-		os.Exit(1)
-	}
-	defer fileSave.Close()
+	parser = NewParser(bytes.NewReader(source), fileName)
+	var code strings.Builder
+	// Instructions by the indexes of their lines for the listing
+	instructions := make(map[int]string)
 
 	for parser.Advance() {
+		instruction := ""
 		switch parser.GetCommandType() {
 		case ADDRESS:
-			symbol := parser.GetSymbol()
-			address := getAddress(symbol, symbolTable)
-			fileSave.WriteString(GetACommand(address) + "\n")
+			if address, ok := getAddress(parser, symbolTable); ok {
+				instruction = GetACommand(address)
+			}
 		case COMMAND:
-			dest, comp, jump := parser.GetMnemonics()
-			fileSave.WriteString(GetCCommand(dest, comp, jump) + "\n")
+			if command, ok := translateCommand(parser, options.Extended); ok {
+				instruction = command
+			}
+		}
+		if instruction != "" {
+			code.WriteString(instruction + "\n")
+			instructions[parser.index] = instruction
+		}
+	}
+	if err := parser.Diagnostics.Err(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer, code.String()); err != nil {
+		return err
+	}
+	return writeListing(parser.lines, instructions, symbolTable, options)
+}
+
+// Translates the current C-command, illegal mnemonics are reported
+// at their position in the command
+func translateCommand(parser *Parser, extended bool) (string, bool) {
+	dest, comp, jump := parser.GetMnemonics()
+	compOffset := 0
+	if dest != "" {
+		compOffset = len(dest) + 1
+	}
+	jumpOffset := compOffset + len(comp) + 1
+
+	destCode, destOk := GetDestinationCode(dest)
+	if !destOk {
+		parser.Add(diagnostics.Expected(parser.Position(0), dest, "destination mnemonic", diagnostics.Quote(dest)))
+	}
+	prefix := COMPUTE_PREFIX
+	compCode, compOk := GetComputeCode(comp)
+	if extended {
+		prefix, compCode, compOk = GetExtendedComputeCode(comp)
+	}
+	if !compOk {
+		found := diagnostics.Quote(comp)
+		if comp == "" {
+			found = "nothing"
 		}
+		parser.Add(diagnostics.Expected(parser.Position(compOffset), comp, "compute mnemonic", found))
+	}
+	jumpCode, jumpOk := GetJumpCode(jump)
+	if !jumpOk {
+		parser.Add(diagnostics.Expected(parser.Position(jumpOffset), jump, "jump mnemonic", diagnostics.Quote(jump)))
 	}
+	return prefix + compCode + destCode + jumpCode, destOk && compOk && jumpOk
 }
 
-func getAddress(symbol string, symbolTable *SymbolTable) int {
-	address, err := strconv.Atoi(symbol)
+// Evaluates the address expression of the current A-instruction,
+// values which do not fit into 15 bits are reported
+func getAddress(parser *Parser, symbolTable *SymbolTable) (int, bool) {
+	expression := parser.GetSymbol()
+	if address, err := strconv.Atoi(expression); err == nil && address <= MAX_ADDRESS {
+		return address, true
+	}
+	terms, _ := parseExpression(expression)
+	address, err := evaluate(expression, terms, symbolTable)
 	if err != nil {
-		if symbolTable.HasSymbol(symbol) {
-			address = symbolTable.GetAddress(symbol)
-		} else {
-			symbolTable.AddEntry(symbol, symbolTable.nextVariableAddress)
-			address = symbolTable.nextVariableAddress
-			symbolTable.nextVariableAddress++
-		}
+		parser.Errorf(1, expression, "%v", err)
+		return 0, false
 	}
-	return address
+	return address, true
 }
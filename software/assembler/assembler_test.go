@@ -0,0 +1,58 @@
+package assembler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Directory with the example programs
+const EXAMPLES_DIRECTORY = "../assembler-examples"
+
+// Assembles the example programs and compares the code with the output
+// of the original assembler stored in testdata
+func TestExamples(t *testing.T) {
+	for _, name := range []string{"Add", "Max", "Mult", "Pong", "Rect"} {
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open(filepath.Join(EXAMPLES_DIRECTORY, name+".asm"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var code bytes.Buffer
+			if err := Assemble(file, &code); err != nil {
+				t.Fatal(err)
+			}
+			expected, err := os.ReadFile(filepath.Join("testdata", name+".hack"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareLines(t, code.String(), string(expected))
+		})
+	}
+}
+
+// Assembles the code with the options, the instructions may be separated by spaces
+func assemble(code string, options Options) (string, error) {
+	var hack bytes.Buffer
+	source := strings.Join(strings.Fields(code), "\n")
+	err := AssembleWith(strings.NewReader(source), &hack, options)
+	return hack.String(), err
+}
+
+// Reports the first line which differs
+func compareLines(t *testing.T, found, expected string) {
+	t.Helper()
+	foundLines := strings.Split(found, "\n")
+	expectedLines := strings.Split(expected, "\n")
+	for i := 0; i < len(foundLines) && i < len(expectedLines); i++ {
+		if foundLines[i] != expectedLines[i] {
+			t.Fatalf("line %d: expected %s, found %s", i+1, expectedLines[i], foundLines[i])
+		}
+	}
+	if len(foundLines) != len(expectedLines) {
+		t.Fatalf("expected %d lines, found %d", len(expectedLines), len(foundLines))
+	}
+}
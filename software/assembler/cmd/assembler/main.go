@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"nand2tetris/software/assembler"
+)
+
+func main() {
+	list := flag.Bool("list", false, "write the listing of the ROM addresses, instructions and source lines with the symbol table to the .lst file")
+	extended := flag.Bool("extended", false, "accept the extended instruction set: the shifts D<<, A>>, M<< etc., the ALU control bits #b110011 and the other functions of the ALU like -1+D")
+	symbols := flag.Bool("symbols", false, "write the labels and the variables with their addresses to the .json file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [options] name of the file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := assemble(flag.Arg(0), *list, *symbols, *extended); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Translates the .asm file into the .hack file and the requested .lst
+// and .json files, the files are written only if there are no errors
+func assemble(fileName string, list, symbols, extended bool) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var code, listing, symbolMap bytes.Buffer
+	options := assembler.Options{Extended: extended}
+	if list {
+		options.Listing = &listing
+	}
+	if symbols {
+		options.Symbols = &symbolMap
+	}
+	if err := assembler.AssembleWith(file, &code, options); err != nil {
+		return err
+	}
+	baseName := fileName[:len(fileName)-3]
+	if list {
+		if err := os.WriteFile(baseName+"lst", listing.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	if symbols {
+		if err := os.WriteFile(baseName+"json", symbolMap.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(baseName+"hack", code.Bytes(), 0666)
+}
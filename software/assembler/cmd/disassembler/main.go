@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"nand2tetris/software/assembler"
+)
+
+func main() {
+	symbols := flag.String("symbols", "", "the .json file with the labels and the variables written by the assembler with -symbols")
+	extended := flag.Bool("extended", false, "decode the shifts of the extended instruction set and write the undefined computations as control bits #b")
+	output := flag.String("o", "", "name of the .asm file, the code is printed if empty")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [options] name of the .hack file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := disassemble(flag.Arg(0), *symbols, *output, *extended); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Translates the .hack file back into the assembly code,
+// the .asm file is written only if there are no errors
+func disassemble(fileName, symbolsFileName, outputFileName string, extended bool) error {
+	options := assembler.DisassemblyOptions{Warnings: os.Stderr, Extended: extended}
+	if symbolsFileName != "" {
+		data, err := os.ReadFile(symbolsFileName)
+		if err != nil {
+			return err
+		}
+		options.Symbols = &assembler.SymbolMap{}
+		if err := json.Unmarshal(data, options.Symbols); err != nil {
+			return fmt.Errorf("%s: %v", symbolsFileName, err)
+		}
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var code bytes.Buffer
+	if err := assembler.Disassemble(file, &code, options); err != nil {
+		return err
+	}
+	if outputFileName == "" {
+		_, err = os.Stdout.Write(code.Bytes())
+		return err
+	}
+	return os.WriteFile(outputFileName, code.Bytes(), 0666)
+}
@@ -1,9 +1,8 @@
-package main
+package assembler
 
 import (
 	"bytes"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
 )
@@ -15,143 +14,135 @@ func GetACommand(decimal int) string {
 	return "0" + buf.String()
 }
 
-// Returns compute command code
-func GetCCommand(dest, comp, jump string) string {
-	return "111" + GetComputeCode(comp) + GetDestinationCode(dest) + GetJumpCode(jump)
-}
-
-// Translate assembly language destination mnemonic into binary codes
-func GetDestinationCode(mnemonic string) string {
+// Translate assembly language destination mnemonic into binary codes,
+// returns false if the mnemonic is illegal
+func GetDestinationCode(mnemonic string) (string, bool) {
 	mnemonic = sortString(mnemonic)
 	switch mnemonic {
 	case "":
-		return "000"
+		return "000", true
 	case "M":
-		return "001"
+		return "001", true
 	case "D":
-		return "010"
+		return "010", true
 	case "DM":
-		return "011"
+		return "011", true
 	case "A":
-		return "100"
+		return "100", true
 	case "AM":
-		return "101"
+		return "101", true
 	case "AD":
-		return "110"
+		return "110", true
 	case "ADM":
-		return "111"
+		return "111", true
 	}
-	fmt.Fprintf(os.Stderr, "Illegal mnemonic: %s. Destination mnemonic expected.", mnemonic)
-	os.Exit(1)
-	return ""
+	return "", false
 }
 
-// Translate assembly language jump mnemonic into binary codes
-func GetJumpCode(mnemonic string) string {
+// Translate assembly language jump mnemonic into binary codes,
+// returns false if the mnemonic is illegal
+func GetJumpCode(mnemonic string) (string, bool) {
 	switch mnemonic {
 	case "":
-		return "000"
+		return "000", true
 	case "JGT":
-		return "001"
+		return "001", true
 	case "JEQ":
-		return "010"
+		return "010", true
 	case "JGE":
-		return "011"
+		return "011", true
 	case "JLT":
-		return "100"
+		return "100", true
 	case "JNE":
-		return "101"
+		return "101", true
 	case "JLE":
-		return "110"
+		return "110", true
 	case "JMP":
-		return "111"
+		return "111", true
 	}
-	fmt.Fprintf(os.Stderr, "Illegal mnemonic: %s. Jump mnemonic expected.", mnemonic)
-	os.Exit(1)
-	return ""
+	return "", false
 }
 
-// Translate assembly language compute mnemonic into binary codes
-func GetComputeCode(mnemonic string) string {
+// Translate assembly language compute mnemonic into binary codes,
+// returns false if the mnemonic is illegal
+func GetComputeCode(mnemonic string) (string, bool) {
 	switch mnemonic {
 	case "0":
-		return "0101010"
+		return "0101010", true
 	case "1":
-		return "0111111"
+		return "0111111", true
 	case "-1":
-		return "0111010"
+		return "0111010", true
 	case "D":
-		return "0001100"
+		return "0001100", true
 	case "A":
-		return "0110000"
+		return "0110000", true
 	case "M":
-		return "1110000"
+		return "1110000", true
 	case "!D":
-		return "0001101"
+		return "0001101", true
 	case "!A":
-		return "0110001"
+		return "0110001", true
 	case "!M":
-		return "1110001"
+		return "1110001", true
 	case "-D":
-		return "0001111"
+		return "0001111", true
 	case "-A":
-		return "0110011"
+		return "0110011", true
 	case "-M":
-		return "1110011"
+		return "1110011", true
 	case "1+D":
 		fallthrough
 	case "D+1":
-		return "0011111"
+		return "0011111", true
 	case "1+A":
 		fallthrough
 	case "A+1":
-		return "0110111"
+		return "0110111", true
 	case "1+M":
 		fallthrough
 	case "M+1":
-		return "1110111"
+		return "1110111", true
 	case "D-1":
-		return "0001110"
+		return "0001110", true
 	case "A-1":
-		return "0110010"
+		return "0110010", true
 	case "M-1":
-		return "1110010"
+		return "1110010", true
 	case "A+D":
 		fallthrough
 	case "D+A":
-		return "0000010"
+		return "0000010", true
 	case "M+D":
 		fallthrough
 	case "D+M":
-		return "1000010"
+		return "1000010", true
 	case "D-A":
-		return "0010011"
+		return "0010011", true
 	case "D-M":
-		return "1010011"
+		return "1010011", true
 	case "A-D":
-		return "0000111"
+		return "0000111", true
 	case "M-D":
-		return "1000111"
+		return "1000111", true
 	case "A&D":
 		fallthrough
 	case "D&A":
-		return "0000000"
+		return "0000000", true
 	case "M&D":
 		fallthrough
 	case "D&M":
-		return "1000000"
+		return "1000000", true
 	case "A|D":
 		fallthrough
 	case "D|A":
-		return "0010101"
+		return "0010101", true
 	case "M|D":
 		fallthrough
 	case "D|M":
-		return "1010101"
+		return "1010101", true
 	}
-	fmt.Fprintf(os.Stderr, "Illegal mnemonic: %s. Compute mnemonic expected.", mnemonic)
-	os.Exit(1)
-	return ""
+	return "", false
 }
 
 func sortString(w string) string {
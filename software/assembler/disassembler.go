@@ -0,0 +1,275 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Settings of the disassembly
+type DisassemblyOptions struct {
+	// Names of the labels and the variables of the program, e.g. written
+	// by AssembleWith, the labels are named after their addresses if nil
+	Symbols *SymbolMap
+	// Receives the warnings about the undefined instructions, one per line.
+	// The warnings are discarded if nil.
+	Warnings io.Writer
+	// Decodes the shifts and the undefined computations of the extended
+	// instruction set, see GetExtendedComputeCode
+	Extended bool
+}
+
+// Decoded instruction of the machine code
+type instruction struct {
+	position diagnostics.Position
+	code     string
+	// Value of the A-instruction, -1 for the C-instruction
+	value int
+	// Mnemonics of the C-instruction, comp is empty if undefined
+	dest, comp, jump string
+	// Symbol replacing the value of the A-instruction
+	symbol string
+}
+
+// Compute mnemonics by their codes, built from GetComputeCode
+var computeMnemonics = make(map[string]string)
+
+// Shift mnemonics of the extended instruction set by their codes
+var shiftMnemonics = make(map[string]string)
+
+// Destination and jump mnemonics by their codes
+var destinationMnemonics = make(map[string]string)
+var jumpMnemonics = make(map[string]string)
+
+func init() {
+	for _, register := range []string{"D", "A", "M"} {
+		for _, pattern := range computePatterns {
+			mnemonic := strings.ReplaceAll(pattern, "X", register)
+			if code, ok := GetComputeCode(mnemonic); ok {
+				if _, known := computeMnemonics[code]; !known {
+					computeMnemonics[code] = mnemonic
+				}
+			}
+		}
+	}
+	for _, mnemonic := range []string{"A>>", "D>>", "A<<", "D<<", "M>>", "M<<"} {
+		code, _ := GetShiftCode(mnemonic)
+		shiftMnemonics[code] = mnemonic
+	}
+	for _, mnemonic := range []string{"", "M", "D", "MD", "A", "AM", "AD", "AMD"} {
+		code, _ := GetDestinationCode(mnemonic)
+		destinationMnemonics[code] = mnemonic
+	}
+	for _, mnemonic := range []string{"", "JGT", "JEQ", "JGE", "JLT", "JNE", "JLE", "JMP"} {
+		code, _ := GetJumpCode(mnemonic)
+		jumpMnemonics[code] = mnemonic
+	}
+}
+
+// Names of the predefined registers, the programs starting with the
+// bootstrap code of the VM translator use the names of the VM pointers
+var registerNames = []string{"R0", "R1", "R2", "R3", "R4"}
+var pointerNames = []string{"SP", "LCL", "ARG", "THIS", "THAT"}
+
+// Translates the Hack machine code read from the reader back into assembly
+// code written to the writer. The jump targets become labels and the addresses
+// of the registers, the screen, the keyboard and the variables of the symbol
+// map are replaced by their names where they are used as addresses, so that
+// the code assembles to the same machine code. Lines which are not 16 binary
+// digits are errors, nothing is written if there are any. Instructions with
+// an undefined compute code are reported as warnings and replaced by 0, which
+// does nothing and keeps the addresses of the following instructions.
+func Disassemble(reader io.Reader, writer io.Writer, options DisassemblyOptions) error {
+	fileName := diagnostics.SourceName(reader)
+	var errors diagnostics.List
+	var instructions []*instruction
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		position := diagnostics.Position{FileName: fileName, Line: line, Column: 1}
+		if len(text) != 16 || strings.Trim(text, "01") != "" {
+			errors.Add(diagnostics.Expected(position, text, "16 binary digits", diagnostics.Quote(text)))
+			continue
+		}
+		instructions = append(instructions, decode(text, position, options.Extended, &errors))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if errors.HasErrors() {
+		return errors
+	}
+
+	labels := findLabels(instructions, options.Symbols)
+	nameSymbols(instructions, labels, options.Symbols)
+
+	output := bufio.NewWriter(writer)
+	for address, instruction := range instructions {
+		for _, label := range labels[address] {
+			fmt.Fprintf(output, "(%s)\n", label)
+		}
+		fmt.Fprintf(output, "    %s\n", instruction)
+	}
+	for _, label := range labels[len(instructions)] {
+		fmt.Fprintf(output, "(%s)\n", label)
+	}
+	if err := output.Flush(); err != nil {
+		return err
+	}
+	if len(errors) > 0 && options.Warnings != nil {
+		if _, err := fmt.Fprintln(options.Warnings, errors.Error()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decodes the instruction, the undefined computations and the C-instructions
+// without the bits 13 and 14 are reported. The extended instruction set
+// defines the shifts and writes the other computations as control bits.
+func decode(code string, position diagnostics.Position, extended bool, warnings *diagnostics.List) *instruction {
+	decoded := &instruction{position: position, code: code, value: -1}
+	if code[0] == '0' {
+		value, _ := strconv.ParseInt(code, 2, 16)
+		decoded.value = int(value)
+		return decoded
+	}
+	decoded.dest = destinationMnemonics[code[10:13]]
+	decoded.jump = jumpMnemonics[code[13:16]]
+	switch {
+	case extended && code[:3] == SHIFT_PREFIX:
+		decoded.comp = shiftMnemonics[code[3:10]]
+	case code[:3] != COMPUTE_PREFIX:
+		warnings.Add(diagnostics.Warningf(position, code, "C-instruction %s does not set the bits 13 and 14, they are ignored", code))
+		fallthrough
+	default:
+		decoded.comp = computeMnemonics[code[3:10]]
+		if decoded.comp == "" && extended {
+			decoded.comp = "#b" + code[3:10]
+		}
+	}
+	if decoded.comp == "" {
+		warnings.Add(diagnostics.Warningf(position, code, "undefined computation %s in instruction %s", code[3:10], code))
+	}
+	return decoded
+}
+
+// Returns the labels by their addresses: the labels of the symbol map
+// and the targets of the jumps, named after their addresses
+func findLabels(instructions []*instruction, symbolMap *SymbolMap) map[int][]string {
+	labels := make(map[int][]string)
+	if symbolMap != nil {
+		for _, label := range symbolMap.Labels {
+			if label.Address >= 0 && label.Address <= len(instructions) {
+				labels[label.Address] = append(labels[label.Address], label.Name)
+			}
+		}
+	}
+	for i, instruction := range instructions {
+		if target := instruction.value; isJumpTarget(instructions, i) && len(labels[target]) == 0 {
+			labels[target] = []string{"L" + strconv.Itoa(target)}
+		}
+	}
+	return labels
+}
+
+// True if the A-instruction is followed by a jump to its value within the program
+func isJumpTarget(instructions []*instruction, i int) bool {
+	value := instructions[i].value
+	return value >= 0 && value <= len(instructions) && i+1 < len(instructions) &&
+		instructions[i+1].comp != "" && instructions[i+1].jump != ""
+}
+
+// True if the A-instruction is followed by a C-instruction reading or writing M
+func isAddress(instructions []*instruction, i int) bool {
+	if i+1 >= len(instructions) {
+		return false
+	}
+	next := instructions[i+1]
+	return next.comp != "" && (strings.Contains(next.comp, "M") || strings.Contains(next.dest, "M"))
+}
+
+// Replaces the values of the A-instructions by the labels, the predefined
+// symbols and the variables. The assembler allocates the variables in the
+// order of their first use, the variables which would get a different
+// address are left as numbers.
+func nameSymbols(instructions []*instruction, labels map[int][]string, symbolMap *SymbolMap) {
+	names := registerNames
+	if len(instructions) > 0 && instructions[0].value == 256 {
+		names = pointerNames
+	}
+	variables := make(map[int]string)
+	if symbolMap != nil {
+		for _, variable := range symbolMap.Variables {
+			if _, defined := variables[variable.Address]; !defined {
+				variables[variable.Address] = variable.Name
+			}
+		}
+	}
+	var used []*instruction
+	for i, instruction := range instructions {
+		value := instruction.value
+		switch {
+		case value < 0:
+		case isJumpTarget(instructions, i):
+			instruction.symbol = labels[value][0]
+		case value == 0x4000:
+			instruction.symbol = "SCREEN"
+		case value == 0x6000:
+			instruction.symbol = "KBD"
+		case !isAddress(instructions, i):
+		case value < len(names):
+			instruction.symbol = names[value]
+		case value < 16:
+			instruction.symbol = "R" + strconv.Itoa(value)
+		case variables[value] != "":
+			instruction.symbol = variables[value]
+			used = append(used, instruction)
+		}
+	}
+	allocated := make(map[string]bool)
+	next := 16
+	for _, instruction := range used {
+		if instruction.symbol == "" || allocated[instruction.symbol] {
+			continue
+		}
+		if instruction.value != next {
+			name := instruction.symbol
+			for _, other := range used {
+				if other.symbol == name {
+					other.symbol = ""
+				}
+			}
+			continue
+		}
+		allocated[instruction.symbol] = true
+		next++
+	}
+}
+
+func (instruction *instruction) String() string {
+	if instruction.value >= 0 {
+		if instruction.symbol != "" {
+			return "@" + instruction.symbol
+		}
+		return "@" + strconv.Itoa(instruction.value)
+	}
+	if instruction.comp == "" {
+		return "0 // undefined instruction " + instruction.code
+	}
+	text := instruction.comp
+	if instruction.dest != "" {
+		text = instruction.dest + "=" + text
+	}
+	if instruction.jump != "" {
+		text += ";" + instruction.jump
+	}
+	return text
+}
@@ -0,0 +1,68 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Disassembles the example programs with and without their symbol maps
+// and checks that the assembly code assembles to the same machine code
+func TestDisassemblyRoundTrip(t *testing.T) {
+	for _, name := range []string{"Add", "Max", "Mult", "Pong", "Rect"} {
+		source, err := os.ReadFile(filepath.Join(EXAMPLES_DIRECTORY, name+".asm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var code, symbols bytes.Buffer
+		if err := AssembleWith(bytes.NewReader(source), &code, Options{Symbols: &symbols}); err != nil {
+			t.Fatal(err)
+		}
+		symbolMap := &SymbolMap{}
+		if err := json.Unmarshal(symbols.Bytes(), symbolMap); err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range []struct {
+			name    string
+			symbols *SymbolMap
+		}{{name, nil}, {name + " with symbols", symbolMap}} {
+			t.Run(test.name, func(t *testing.T) {
+				var assembly, reassembled bytes.Buffer
+				if err := Disassemble(bytes.NewReader(code.Bytes()), &assembly, DisassemblyOptions{Symbols: test.symbols}); err != nil {
+					t.Fatal(err)
+				}
+				if err := Assemble(&assembly, &reassembled); err != nil {
+					t.Fatal(err)
+				}
+				compareLines(t, reassembled.String(), code.String())
+			})
+		}
+	}
+}
+
+// Checks that an undefined instruction is reported and replaced by an instruction
+// of the same size, so that the following jump keeps its target
+func TestDisassembleUndefinedInstruction(t *testing.T) {
+	code := strings.Join([]string{
+		"0000000000000011",
+		"1110000001000000",
+		"1110101010000111",
+		"0000000000000011",
+		"1110101010000111",
+	}, "\n") + "\n"
+	var assembly, warnings, reassembled bytes.Buffer
+	if err := Disassemble(strings.NewReader(code), &assembly, DisassemblyOptions{Warnings: &warnings}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(warnings.String(), "undefined computation 0000001 in instruction 1110000001000000") {
+		t.Errorf("expected a warning about the undefined instruction, found %q", warnings.String())
+	}
+	if err := Assemble(&assembly, &reassembled); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(code, "1110000001000000", "1110101010000000", 1)
+	compareLines(t, reassembled.String(), expected)
+}
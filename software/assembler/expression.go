@@ -0,0 +1,159 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Largest value of the A-instruction, which has 15 bits
+const MAX_ADDRESS = 1<<15 - 1
+
+// Number or symbol of the address expression, which is the sum of its terms
+type term struct {
+	negative bool
+	number   int
+	symbol   string
+}
+
+// Syntax error in the address expression at the offset from its start
+type expressionError struct {
+	offset int
+	token  string
+	// Either expected and found or the message describe the error
+	expected string
+	found    string
+	message  string
+}
+
+// Reads the address expression of the A-instruction: numbers, decimal or with
+// the prefix 0x or 0b, and symbols, added and subtracted and grouped by
+// parentheses, e.g. SCREEN+32 or (KBD-1)
+type expressionParser struct {
+	text   string
+	offset int
+	terms  []term
+}
+
+// Returns the terms of the expression with their signs resolved
+func parseExpression(text string) ([]term, *expressionError) {
+	parser := &expressionParser{text: text}
+	if err := parser.parseSum(false); err != nil {
+		return nil, err
+	}
+	if parser.offset < len(text) {
+		return nil, parser.expected("'+', '-' or end of line")
+	}
+	return parser.terms, nil
+}
+
+// Parses the terms separated by + and -, the signs are inverted if negative
+func (parser *expressionParser) parseSum(negative bool) *expressionError {
+	if err := parser.parseTerm(negative); err != nil {
+		return err
+	}
+	for parser.offset < len(parser.text) {
+		operator := parser.text[parser.offset]
+		if operator != '+' && operator != '-' {
+			return nil
+		}
+		parser.offset++
+		if err := parser.parseTerm(negative != (operator == '-')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (parser *expressionParser) parseTerm(negative bool) *expressionError {
+	if parser.offset == len(parser.text) {
+		return parser.expected("constant or symbol")
+	}
+	if parser.text[parser.offset] == '(' {
+		parser.offset++
+		if err := parser.parseSum(negative); err != nil {
+			return err
+		}
+		if parser.offset == len(parser.text) || parser.text[parser.offset] != ')' {
+			return parser.expected("')'")
+		}
+		parser.offset++
+		return nil
+	}
+	start := parser.offset
+	for parser.offset < len(parser.text) && isSymbolChar(parser.text[parser.offset]) {
+		parser.offset++
+	}
+	token := parser.text[start:parser.offset]
+	if token == "" {
+		return parser.expected("constant or symbol")
+	}
+	if token[0] >= '0' && token[0] <= '9' {
+		number, ok := parseNumber(token)
+		if !ok {
+			return &expressionError{offset: start, token: token, message: "invalid constant " + token}
+		}
+		parser.terms = append(parser.terms, term{negative: negative, number: number})
+	} else {
+		parser.terms = append(parser.terms, term{negative: negative, symbol: token})
+	}
+	return nil
+}
+
+func (parser *expressionParser) expected(expected string) *expressionError {
+	if parser.offset == len(parser.text) {
+		return &expressionError{offset: parser.offset, expected: expected, found: "end of line"}
+	}
+	found := parser.text[parser.offset : parser.offset+1]
+	return &expressionError{offset: parser.offset, token: found, expected: expected, found: "'" + found + "'"}
+}
+
+// Parses the decimal number or the number with the prefix 0x or 0b,
+// the numbers above 32767 are reported by the range check
+func parseNumber(token string) (int, bool) {
+	base := 10
+	if prefix := strings.ToLower(token[:min(2, len(token))]); prefix == "0x" {
+		base = 16
+	} else if prefix == "0b" {
+		base = 2
+	}
+	if base != 10 {
+		token = token[2:]
+	}
+	if token == "" || strings.HasPrefix(token, "+") {
+		return 0, false
+	}
+	number, err := strconv.ParseUint(token, base, 32)
+	if errors.Is(err, strconv.ErrRange) {
+		return MAX_ADDRESS + 1, true
+	}
+	return int(number), err == nil
+}
+
+// Returns the value of the expression, the undefined symbols are
+// allocated as variables. Values outside of 0..32767 are errors.
+func evaluate(text string, terms []term, symbolTable *SymbolTable) (int, error) {
+	value := 0
+	for _, term := range terms {
+		number := term.number
+		if term.symbol != "" {
+			if symbolTable.HasSymbol(term.symbol) {
+				number = symbolTable.GetAddress(term.symbol)
+			} else {
+				number = symbolTable.AddVariable(term.symbol)
+			}
+		}
+		if term.negative {
+			number = -number
+		}
+		value += number
+	}
+	if value < 0 || value > MAX_ADDRESS {
+		if len(terms) == 1 && terms[0].symbol == "" {
+			return 0, fmt.Errorf("constant %s out of range 0..%d", text, MAX_ADDRESS)
+		}
+		return 0, fmt.Errorf("%s is %d, out of range 0..%d", text, value, MAX_ADDRESS)
+	}
+	return value, nil
+}
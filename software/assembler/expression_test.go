@@ -0,0 +1,66 @@
+package assembler
+
+import (
+	"strings"
+	"testing"
+)
+
+// Checks the values of the address expressions
+func TestExpressions(t *testing.T) {
+	for _, test := range []struct {
+		code     string
+		expected string
+	}{
+		{"@0x4000", "0100000000000000"},
+		{"@0X7fff", "0111111111111111"},
+		{"@0b1010", "0000000000001010"},
+		{"@SCREEN+32", "0100000000100000"},
+		{"@KBD-1", "0101111111111111"},
+		{"@(KBD-SCREEN)-(1+1)", "0001111111111110"},
+		{"@R15-R14+1", "0000000000000010"},
+		{"(L) @L+3", "0000000000000011"},
+		{"@x+1 @x", "0000000000010001\n0000000000010000"},
+		{"@32767", "0111111111111111"},
+	} {
+		t.Run(test.code, func(t *testing.T) {
+			found, err := assemble(test.code, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != test.expected+"\n" {
+				t.Errorf("expected %s, found %s", test.expected, found)
+			}
+		})
+	}
+}
+
+// Checks the syntax errors and the range of the address expressions
+func TestExpressionErrors(t *testing.T) {
+	for _, test := range []struct {
+		code     string
+		expected string
+	}{
+		{"@32768", "1:2: constant 32768 out of range 0..32767"},
+		{"@0x8000", "1:2: constant 0x8000 out of range 0..32767"},
+		{"@99999999999", "1:2: constant 99999999999 out of range 0..32767"},
+		{"@SCREEN+0x4000", "1:2: SCREEN+0x4000 is 32768, out of range 0..32767"},
+		{"@R1-R2", "1:2: R1-R2 is -1, out of range 0..32767"},
+		{"@0x", "1:2: invalid constant 0x"},
+		{"@0b102", "1:2: invalid constant 0b102"},
+		{"@12ab", "1:2: invalid constant 12ab"},
+		{"@1+", "1:4: expected constant or symbol, found end of line"},
+		{"@(1+2", "1:6: expected ')', found end of line"},
+		{"@1*2", "1:3: expected '+', '-' or end of line, found '*'"},
+		{"@", "1:2: expected symbol or constant after @, found end of line"},
+	} {
+		t.Run(test.code, func(t *testing.T) {
+			_, err := assemble(test.code, Options{})
+			if err == nil {
+				t.Fatalf("expected %s", test.expected)
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected %s, found %v", test.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package assembler
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Instruction prefixes of the compute instructions and of the shifts
+// of the extended instruction set
+const (
+	COMPUTE_PREFIX = "111"
+	SHIFT_PREFIX   = "101"
+)
+
+// Compute mnemonics of the documented instruction set with X standing for
+// each of the registers D, A and M, not all of them are defined
+var computePatterns = []string{"0", "1", "-1", "X", "!X", "-X", "X+1", "X-1", "D+X", "D-X", "X-D", "D&X", "D|X"}
+
+// Values of D and of A or M for which the computations are compared
+var samples = [...]struct{ x, y int16 }{
+	{0, 0}, {1, 0}, {0, 1}, {-1, -1}, {-1, 0}, {0, -1}, {0x1234, 0x0F0F}, {0x7FFF, 0x5555},
+	{-0x8000, 1}, {-21846, 12345}, {300, -77}, {-4096, 4095},
+}
+
+// Results of a computation for the samples
+type outputs [len(samples)]int16
+
+// Function of the ALU, memory is true if it reads M instead of A
+type aluFunction struct {
+	memory  bool
+	outputs outputs
+}
+
+// Compute codes by the functions they compute, the documented codes are
+// preferred to the other codes of the same function
+var aluCodes = make(map[aluFunction]string)
+
+func init() {
+	for _, register := range []string{"D", "A", "M"} {
+		for _, pattern := range computePatterns {
+			if code, ok := GetComputeCode(strings.ReplaceAll(pattern, "X", register)); ok {
+				registerALUCode(code)
+			}
+		}
+	}
+	for code := 0; code < 1<<7; code++ {
+		registerALUCode(strconv.FormatInt(int64(code)|1<<7, 2)[1:])
+	}
+}
+
+func registerALUCode(code string) {
+	control, _ := strconv.ParseInt(code[1:], 2, 8)
+	function := aluFunction{memory: code[0] == '1'}
+	for i, sample := range samples {
+		function.outputs[i] = computeALU(sample.x, sample.y, int(control))
+	}
+	if _, known := aluCodes[function]; !known {
+		aluCodes[function] = code
+	}
+}
+
+// Computes the function selected by the control bits zx, nx, zy, ny, f and no
+// like hardware/alu/ALU.hdl, x is D and y is A or M
+func computeALU(x, y int16, control int) int16 {
+	if control&0x20 != 0 {
+		x = 0
+	}
+	if control&0x10 != 0 {
+		x = ^x
+	}
+	if control&0x08 != 0 {
+		y = 0
+	}
+	if control&0x04 != 0 {
+		y = ^y
+	}
+	out := x & y
+	if control&0x02 != 0 {
+		out = x + y
+	}
+	if control&0x01 != 0 {
+		out = ^out
+	}
+	return out
+}
+
+// Translate the shift mnemonic of the extended instruction set into binary
+// codes, the instruction begins with SHIFT_PREFIX. D>> and A>> shift right
+// keeping the sign, D<< and A<< shift left. Returns false if the mnemonic
+// is illegal.
+func GetShiftCode(mnemonic string) (string, bool) {
+	switch mnemonic {
+	case "A>>":
+		return "0000000", true
+	case "D>>":
+		return "0010000", true
+	case "A<<":
+		return "0100000", true
+	case "D<<":
+		return "0110000", true
+	case "M>>":
+		return "1000000", true
+	case "M<<":
+		return "1100000", true
+	}
+	return "", false
+}
+
+// Translate the compute mnemonic of the extended instruction set into
+// the instruction prefix and binary codes. Besides the documented mnemonics
+// and the shifts it accepts:
+//
+//	#b110011    the control bits zx, nx, zy, ny, f and no reading A,
+//	#b1110011   or preceded by the a-bit, 1 reads M
+//	-1+D, !(D&A), !D&!M, ...
+//
+// The last are expressions of D, A or M, 0 and 1 with the operators ! - + & |
+// and parentheses, which the ALU computes, i.e. the functions of its control bits.
+// Returns false if the mnemonic is illegal.
+func GetExtendedComputeCode(mnemonic string) (string, string, bool) {
+	if code, ok := GetComputeCode(mnemonic); ok {
+		return COMPUTE_PREFIX, code, true
+	}
+	if code, ok := GetShiftCode(mnemonic); ok {
+		return SHIFT_PREFIX, code, true
+	}
+	if bits, isRaw := strings.CutPrefix(mnemonic, "#b"); isRaw {
+		if len(bits) != 6 && len(bits) != 7 || strings.Trim(bits, "01") != "" {
+			return "", "", false
+		}
+		if len(bits) == 6 {
+			bits = "0" + bits
+		}
+		return COMPUTE_PREFIX, bits, true
+	}
+	parser := &computeParser{text: mnemonic}
+	result, ok := parser.parseOr()
+	if !ok || parser.offset < len(mnemonic) {
+		return "", "", false
+	}
+	code, ok := aluCodes[aluFunction{memory: parser.register == 'M', outputs: result}]
+	return COMPUTE_PREFIX, code, ok
+}
+
+// Evaluates the compute expression for the samples, the operators are
+// ! and - before + and -, before &, before |
+type computeParser struct {
+	text   string
+	offset int
+	// Register used as the y input, A or M, 0 if there is none
+	register byte
+}
+
+func (parser *computeParser) parseOr() (outputs, bool) {
+	return parser.parseBinary("|", parser.parseAnd)
+}
+
+func (parser *computeParser) parseAnd() (outputs, bool) {
+	return parser.parseBinary("&", parser.parseSum)
+}
+
+func (parser *computeParser) parseSum() (outputs, bool) {
+	return parser.parseBinary("+-", parser.parseUnary)
+}
+
+// Parses the operands separated by the operators, from left to right
+func (parser *computeParser) parseBinary(operators string, parseOperand func() (outputs, bool)) (outputs, bool) {
+	result, ok := parseOperand()
+	for ok && parser.offset < len(parser.text) && strings.IndexByte(operators, parser.text[parser.offset]) != -1 {
+		operator := parser.text[parser.offset]
+		parser.offset++
+		var operand outputs
+		if operand, ok = parseOperand(); !ok {
+			break
+		}
+		for i := range result {
+			switch operator {
+			case '|':
+				result[i] |= operand[i]
+			case '&':
+				result[i] &= operand[i]
+			case '+':
+				result[i] += operand[i]
+			case '-':
+				result[i] -= operand[i]
+			}
+		}
+	}
+	return result, ok
+}
+
+func (parser *computeParser) parseUnary() (outputs, bool) {
+	if parser.offset == len(parser.text) {
+		return outputs{}, false
+	}
+	switch operator := parser.text[parser.offset]; operator {
+	case '!', '-':
+		parser.offset++
+		result, ok := parser.parseUnary()
+		for i := range result {
+			if operator == '!' {
+				result[i] = ^result[i]
+			} else {
+				result[i] = -result[i]
+			}
+		}
+		return result, ok
+	case '(':
+		parser.offset++
+		result, ok := parser.parseOr()
+		if !ok || parser.offset == len(parser.text) || parser.text[parser.offset] != ')' {
+			return result, false
+		}
+		parser.offset++
+		return result, true
+	}
+	return parser.parseOperand()
+}
+
+// Parses a register or a decimal number
+func (parser *computeParser) parseOperand() (outputs, bool) {
+	var result outputs
+	c := parser.text[parser.offset]
+	switch {
+	case c == 'D':
+		for i, sample := range samples {
+			result[i] = sample.x
+		}
+	case c == 'A' || c == 'M':
+		if parser.register != 0 && parser.register != c {
+			return result, false
+		}
+		parser.register = c
+		for i, sample := range samples {
+			result[i] = sample.y
+		}
+	case c >= '0' && c <= '9':
+		start := parser.offset
+		for parser.offset < len(parser.text) && parser.text[parser.offset] >= '0' && parser.text[parser.offset] <= '9' {
+			parser.offset++
+		}
+		number, err := strconv.ParseInt(parser.text[start:parser.offset], 10, 16)
+		for i := range result {
+			result[i] = int16(number)
+		}
+		return result, err == nil
+	default:
+		return result, false
+	}
+	parser.offset++
+	return result, true
+}
@@ -0,0 +1,78 @@
+package assembler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Instructions of the extended instruction set with their codes
+var extendedInstructions = []struct {
+	code     string
+	expected string
+}{
+	{"M=M<<;JLT", "1011100000001100"},
+	{"D=D>>", "1010010000010000"},
+	{"A=A>>", "1010000000100000"},
+	{"AM=A<<", "1010100000101000"},
+	{"D=#b110011", "1110110011010000"},
+	{"D=#b1110011", "1111110011010000"},
+	// Functions with a documented mnemonic get its code
+	{"D=-1+D", "1110001110010000"},
+	{"D=-D+M", "1111000111010000"},
+	{"D=!(D&A)", "1110000001010000"},
+	{"D=!D&!M", "1111010100010000"},
+}
+
+// Checks the codes of the extended instructions, which are errors without
+// the extended instruction set
+func TestExtendedInstructions(t *testing.T) {
+	for _, test := range extendedInstructions {
+		t.Run(test.code, func(t *testing.T) {
+			found, err := assemble(test.code, Options{Extended: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != test.expected+"\n" {
+				t.Errorf("expected %s, found %s", test.expected, found)
+			}
+			if _, err := assemble(test.code, Options{}); err == nil || !strings.Contains(err.Error(), "expected compute mnemonic") {
+				t.Errorf("expected an error of the documented instruction set, found %v", err)
+			}
+		})
+	}
+}
+
+// Checks the computations which the ALU cannot compute
+func TestExtendedErrors(t *testing.T) {
+	for _, code := range []string{"D=D+2", "D=A+M", "D=#b12", "D=#b11001", "D=D<<<", "D=D&"} {
+		t.Run(code, func(t *testing.T) {
+			_, err := assemble(code, Options{Extended: true})
+			expected := "1:3: expected compute mnemonic, found '" + code[2:] + "'"
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected %s, found %v", expected, err)
+			}
+		})
+	}
+}
+
+// Disassembles the extended instructions and assembles them back
+func TestDisassembleExtended(t *testing.T) {
+	var code strings.Builder
+	for _, test := range extendedInstructions {
+		code.WriteString(test.expected + "\n")
+	}
+	var assembly, warnings bytes.Buffer
+	options := DisassemblyOptions{Warnings: &warnings, Extended: true}
+	if err := Disassemble(strings.NewReader(code.String()), &assembly, options); err != nil {
+		t.Fatal(err)
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("expected no warnings, found %s", warnings.String())
+	}
+	found, err := assemble(assembly.String(), Options{Extended: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareLines(t, found, code.String())
+}
@@ -0,0 +1,96 @@
+package assembler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Settings of the assembly, the outputs are written only if they are not nil
+type Options struct {
+	// Accepts the shifts and the other computations of the ALU,
+	// see GetExtendedComputeCode
+	Extended bool
+	// Receives the listing pairing each ROM address with its instruction
+	// and its source line, followed by the symbol table
+	Listing io.Writer
+	// Receives the symbol table as JSON
+	Symbols io.Writer
+}
+
+// Symbol defined by the program with its ROM or RAM address
+type Symbol struct {
+	Name    string `json:"name"`
+	Address int    `json:"address"`
+}
+
+// Labels with the ROM addresses of their instructions and variables with
+// their RAM addresses, in the order of their definition
+type SymbolMap struct {
+	Labels    []Symbol `json:"labels"`
+	Variables []Symbol `json:"variables"`
+}
+
+// Returns the symbols defined by the program, without the predefined ones
+func (symbolTable *SymbolTable) GetSymbolMap() SymbolMap {
+	symbolMap := SymbolMap{Labels: []Symbol{}, Variables: []Symbol{}}
+	for _, label := range symbolTable.labels {
+		symbolMap.Labels = append(symbolMap.Labels, Symbol{label, symbolTable.GetAddress(label)})
+	}
+	for _, variable := range symbolTable.variables {
+		symbolMap.Variables = append(symbolMap.Variables, Symbol{variable, symbolTable.GetAddress(variable)})
+	}
+	return symbolMap
+}
+
+// Writes the symbol table, one symbol with its address per line
+func (symbolMap SymbolMap) String() string {
+	var text strings.Builder
+	for _, group := range []struct {
+		name    string
+		symbols []Symbol
+	}{{"labels", symbolMap.Labels}, {"variables", symbolMap.Variables}} {
+		fmt.Fprintf(&text, "%s:\n", group.name)
+		for _, symbol := range group.symbols {
+			fmt.Fprintf(&text, "  %-40s %5d\n", symbol.Name, symbol.Address)
+		}
+	}
+	return strings.TrimSuffix(text.String(), "\n")
+}
+
+// Writes the listing and the symbol table requested by the options. The lines
+// produced by the includes and the macros follow the line which produced them.
+func writeListing(lines []sourceLine, instructions map[int]string, symbolTable *SymbolTable, options Options) error {
+	symbolMap := symbolTable.GetSymbolMap()
+	if options.Listing != nil {
+		writer := bufio.NewWriter(options.Listing)
+		fmt.Fprintf(writer, "%5s  %-16s  %-4s  %5s  %s\n", "ROM", "binary", "hex", "line", "source")
+		address := 0
+		for i, line := range lines {
+			if instruction, ok := instructions[i]; ok {
+				value, _ := strconv.ParseUint(instruction, 2, 16)
+				fmt.Fprintf(writer, "%5d  %s  %04x  %5d  %s\n", address, instruction, value, line.line, line.source)
+				address++
+			} else {
+				fmt.Fprintf(writer, "%31s%5d  %s\n", "", line.line, line.source)
+			}
+		}
+		fmt.Fprintf(writer, "\n%s\n", symbolMap)
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+	if options.Symbols != nil {
+		code, err := json.MarshalIndent(symbolMap, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := options.Symbols.Write(append(code, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
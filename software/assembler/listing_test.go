@@ -0,0 +1,69 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const LISTING_PROGRAM = `.macro INC r
+@r
+M=M+1
+.endm
+@i
+M=1
+(LOOP)
+INC i
+@LOOP
+0;JMP
+`
+
+// Checks the listing of the addresses, the codes and the source lines,
+// the lines of the macro expansions follow their invocation
+func TestListing(t *testing.T) {
+	var code, listing bytes.Buffer
+	if err := AssembleWith(strings.NewReader(LISTING_PROGRAM), &code, Options{Listing: &listing}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `  ROM  binary            hex    line  source
+                                   1  .macro INC r
+                                   2  @r
+                                   3  M=M+1
+                                   4  .endm
+    0  0000000000010000  0010      5  @i
+    1  1110111111001000  efc8      6  M=1
+                                   7  (LOOP)
+                                   8  INC i
+    2  0000000000010000  0010      8  + @i
+    3  1111110111001000  fdc8      8  + M=M+1
+    4  0000000000000010  0002      9  @LOOP
+    5  1110101010000111  ea87     10  0;JMP
+
+labels:
+  LOOP                                         2
+variables:
+  i                                           16
+`
+	compareLines(t, listing.String(), expected)
+}
+
+// Checks the symbol table written as JSON, without the predefined symbols
+func TestSymbols(t *testing.T) {
+	var code, symbols bytes.Buffer
+	if err := AssembleWith(strings.NewReader(LISTING_PROGRAM+"@R5\n@SCREEN\n@j\n"), &code, Options{Symbols: &symbols}); err != nil {
+		t.Fatal(err)
+	}
+	var symbolMap SymbolMap
+	if err := json.Unmarshal(symbols.Bytes(), &symbolMap); err != nil {
+		t.Fatal(err)
+	}
+	expected := SymbolMap{
+		Labels:    []Symbol{{"LOOP", 2}},
+		Variables: []Symbol{{"i", 16}, {"j", 17}},
+	}
+	if !reflect.DeepEqual(symbolMap, expected) {
+		t.Errorf("expected %+v, found %+v", expected, symbolMap)
+	}
+}
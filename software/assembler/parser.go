@@ -1,18 +1,22 @@
-package main
+package assembler
 
 import (
-	"bufio"
-	"fmt"
-	"os"
+	"io"
 	"strings"
+
+	"nand2tetris/software/diagnostics"
 )
 
 // Encapsulates access to the input code. Reads an assembly language command,
 // parses it, and provides convenient access to the command’s components
 // (fields and symbols). In addition, removes all white space and comments.
+// Malformed commands are recorded in Diagnostics and skipped.
 type Parser struct {
-	scanner *bufio.Scanner
-	file    *os.File
+	Diagnostics diagnostics.List
+	lines       []sourceLine
+	// Index of the current line in lines
+	index  int
+	column int
 }
 
 type CommandType int
@@ -23,28 +27,21 @@ const (
 	LABEL   = iota
 )
 
-// Opens the input file
-func NewParser(fileName string) *Parser {
-	file, err := os.Open(fileName)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not open file %s", fileName)
-		os.Exit(1)
-	}
-	scanner := bufio.NewScanner(file)
-	return &Parser{scanner: scanner, file: file}
-}
-
-// Closes the file
-func (parser *Parser) Close() error {
-	return parser.file.Close()
+// Creates the parser of the input, the file name is used by the diagnostics
+// and the included files are relative to it. The includes, macros and
+// constants are expanded first, see preprocessor.
+func NewParser(reader io.Reader, fileName string) *Parser {
+	lines, errors := preprocess(reader, fileName)
+	return &Parser{Diagnostics: errors, lines: lines, index: -1}
 }
 
 // Reads the next command from the input and makes it current
 // Returns true if there are more commands in the input
 func (parser *Parser) Advance() bool {
-	for parser.scanner.Scan() {
+	for parser.index+1 < len(parser.lines) {
+		parser.index++
 		text := parser.getAssemblyCode()
-		if len(text) > 0 {
+		if len(text) > 0 && parser.validate(text) {
 			return true
 		}
 	}
@@ -63,11 +60,12 @@ func (parser *Parser) GetCommandType() CommandType {
 	return COMMAND
 }
 
-// Returns the symbol or decimal xxx of the current command @xxx or (xxx)
+// Returns the address expression xxx of the current command @xxx or the
+// symbol xxx of (xxx), see parseExpression.
 // Should be called only when CommandType is ADDRESS or LABEL
 func (parser *Parser) GetSymbol() string {
 	text := parser.getAssemblyCode()
-	if text[len(text)-1] == ')' {
+	if text[0] == '(' {
 		text = text[:len(text)-1]
 	}
 	return text[1:]
@@ -93,11 +91,81 @@ func (parser *Parser) GetMnemonics() (string, string, string) {
 	return dest, comp, jump
 }
 
+// Returns the position of the character of the current command
+// at the given offset, the command starts at offset 0
+func (parser *Parser) Position(offset int) diagnostics.Position {
+	position := parser.lines[parser.index].position
+	position.Column = parser.column + offset
+	return position
+}
+
+// Records the diagnostic of the current command, noting the macro
+// invocation or the include which produced it
+func (parser *Parser) Add(diagnostic *diagnostics.Diagnostic) {
+	diagnostic.Note = parser.lines[parser.index].note
+	parser.Diagnostics.Add(diagnostic)
+}
+
+// Records the diagnostic of the text of the current command at the given offset
+func (parser *Parser) Errorf(offset int, token string, format string, args ...interface{}) {
+	parser.Add(diagnostics.Errorf(parser.Position(offset), token, format, args...))
+}
+
+// Checks the syntax of the labels and A-commands
+func (parser *Parser) validate(text string) bool {
+	switch text[0] {
+	case '@':
+		expression := text[1:]
+		if expression == "" {
+			parser.Add(diagnostics.Expected(parser.Position(1), "", "symbol or constant after @", "end of line"))
+			return false
+		}
+		if _, err := parseExpression(expression); err != nil {
+			if err.expected != "" {
+				parser.Add(diagnostics.Expected(parser.Position(1+err.offset), err.token, err.expected, err.found))
+			} else {
+				parser.Errorf(1+err.offset, err.token, "%s", err.message)
+			}
+			return false
+		}
+		return true
+	case '(':
+		if text[len(text)-1] != ')' {
+			parser.Add(diagnostics.Expected(parser.Position(len(text)), "", diagnostics.Quote(")"), "end of line"))
+			return false
+		}
+		symbol := text[1 : len(text)-1]
+		if symbol == "" {
+			parser.Add(diagnostics.Expected(parser.Position(1), ")", "label", diagnostics.Quote(")")))
+			return false
+		}
+		return parser.validateLabel(symbol, 1)
+	}
+	return true
+}
+
+// Symbols consist of letters, digits, _ . $ and : and do not begin with a digit
+func (parser *Parser) validateLabel(symbol string, offset int) bool {
+	if symbol[0] >= '0' && symbol[0] <= '9' {
+		parser.Errorf(offset, symbol, "label %s begins with a digit", symbol)
+		return false
+	}
+	for i := range symbol {
+		if c := symbol[i]; !isSymbolChar(c) {
+			parser.Errorf(offset+i, symbol, "invalid character %q in symbol %s", c, symbol)
+			return false
+		}
+	}
+	return true
+}
+
 func (parser *Parser) getAssemblyCode() string {
-	text := parser.scanner.Text()
+	text := parser.lines[parser.index].text
 	commentIndex := strings.Index(text, "//")
 	if commentIndex != -1 {
 		text = text[:commentIndex]
 	}
-	return strings.TrimSpace(text)
+	trimmed := strings.TrimLeft(text, " \t\r")
+	parser.column = len(text) - len(trimmed) + 1
+	return strings.TrimSpace(trimmed)
 }
@@ -0,0 +1,331 @@
+package assembler
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Line of the assembly code after the expansion of the directives
+type sourceLine struct {
+	// Code read by the parser, empty for the directives and the macro definitions
+	text string
+	// Shown by the listing, the original line or the expanded code
+	source string
+	// Line of the text in its file, in the macro definition for the expanded lines
+	position diagnostics.Position
+	// Line of the assembled file with the line, its .include or its macro invocation
+	line int
+	// Macro invocations and includes which produced the line
+	note string
+}
+
+// Parameterized sequence of lines, expanded where its name is used as a command
+type macro struct {
+	name       string
+	parameters []string
+	position   diagnostics.Position
+	body       []sourceLine
+}
+
+// Expands the directives of the assembly code before it is parsed:
+//
+//	.include "file.asm"     inserts the file, relative to the including file
+//	.define NAME value      replaces the symbol NAME by the value in the following lines
+//	.macro NAME a, b        defines the macro with the parameters a and b,
+//	...                     the lines up to .endm are inserted where NAME a, b
+//	.endm                   is used as a command, with the arguments replacing the parameters
+//
+// The labels defined by a macro are local to each of its expansions.
+type preprocessor struct {
+	diagnostics diagnostics.List
+	lines       []sourceLine
+	constants   map[string]string
+	macros      map[string]*macro
+	// Macro being defined
+	defining *macro
+	// Files being included and macros being expanded, innermost last
+	files     []string
+	expanding []string
+	// Number of the expansions, which keeps their local labels apart
+	expansions int
+}
+
+// Reads the assembly code and returns its lines with the directives expanded
+func preprocess(reader io.Reader, fileName string) ([]sourceLine, diagnostics.List) {
+	preprocessor := &preprocessor{constants: make(map[string]string), macros: make(map[string]*macro)}
+	preprocessor.readFile(reader, fileName, 0, "")
+	return preprocessor.lines, preprocessor.diagnostics
+}
+
+// Adds the lines of the file, included at the given line of the assembled file
+// if it is not 0
+func (preprocessor *preprocessor) readFile(reader io.Reader, fileName string, line int, note string) {
+	preprocessor.files = append(preprocessor.files, fileName)
+	scanner := bufio.NewScanner(reader)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		source := sourceLine{text: text, source: text, position: diagnostics.Position{FileName: fileName, Line: number, Column: 1}, line: line, note: note}
+		if line == 0 {
+			source.line = number
+		} else {
+			source.source = "+ " + text
+		}
+		preprocessor.readLine(source)
+	}
+	if err := scanner.Err(); err != nil {
+		preprocessor.diagnostics.AddError(err)
+	}
+	if preprocessor.defining != nil && len(preprocessor.files) == 1 {
+		preprocessor.errorf(preprocessor.defining.position, preprocessor.defining.name, "", "macro %s is not terminated by .endm", preprocessor.defining.name)
+		preprocessor.defining = nil
+	}
+	preprocessor.files = preprocessor.files[:len(preprocessor.files)-1]
+}
+
+func (preprocessor *preprocessor) readLine(line sourceLine) {
+	indentation, code := splitCode(line.text)
+	directive := ""
+	if command := firstField(code); strings.HasPrefix(command, ".") {
+		directive = command
+	}
+	position := line.position
+	position.Column = len(indentation) + 1
+	if defining := preprocessor.defining; defining != nil {
+		switch directive {
+		case ".endm":
+			preprocessor.defining = nil
+		case "":
+			defining.body = append(defining.body, line)
+		default:
+			preprocessor.errorf(position, directive, line.note, "directive %s inside macro %s", directive, defining.name)
+		}
+		line.text = ""
+		preprocessor.lines = append(preprocessor.lines, line)
+		return
+	}
+	if directive == "" {
+		preprocessor.expand(line, indentation, code, nil)
+		return
+	}
+	arguments := strings.TrimSpace(code[len(directive):])
+	line.text = ""
+	preprocessor.lines = append(preprocessor.lines, line)
+	switch directive {
+	case ".define":
+		name := firstField(arguments)
+		value := strings.TrimSpace(arguments[len(name):])
+		if value == "" {
+			preprocessor.diagnostics.Add(withNote(diagnostics.Expected(position, directive, "name and value after .define", diagnostics.Quote(arguments)), line.note))
+		} else if preprocessor.checkName(position, name, line.note) && preprocessor.checkMnemonic(position, name, line.note) {
+			preprocessor.constants[name] = value
+		}
+	case ".include":
+		if len(arguments) < 2 || arguments[0] != '"' || arguments[len(arguments)-1] != '"' {
+			preprocessor.diagnostics.Add(withNote(diagnostics.Expected(position, directive, "quoted file name after .include", diagnostics.Quote(arguments)), line.note))
+			return
+		}
+		preprocessor.include(arguments[1:len(arguments)-1], position, line)
+	case ".macro":
+		name := firstField(arguments)
+		// The body of an invalid macro is read up to .endm but not registered
+		valid := preprocessor.checkName(position, name, line.note)
+		if previous, defined := preprocessor.macros[name]; valid && defined {
+			preprocessor.errorf(position, name, line.note, "macro %s is already defined at %v", name, previous.position)
+			valid = false
+		}
+		defined := &macro{name: name, position: position}
+		if parameters := strings.TrimSpace(arguments[len(name):]); parameters != "" {
+			for _, parameter := range strings.Split(parameters, ",") {
+				parameter = strings.TrimSpace(parameter)
+				if preprocessor.checkName(position, parameter, line.note) && preprocessor.checkMnemonic(position, parameter, line.note) {
+					defined.parameters = append(defined.parameters, parameter)
+				}
+			}
+		}
+		if valid {
+			preprocessor.macros[name] = defined
+		}
+		preprocessor.defining = defined
+	case ".endm":
+		preprocessor.errorf(position, directive, line.note, ".endm without .macro")
+	default:
+		preprocessor.errorf(position, directive, line.note, "unknown directive %s", directive)
+	}
+}
+
+// Adds the lines of the included file
+func (preprocessor *preprocessor) include(name string, position diagnostics.Position, line sourceLine) {
+	fileName := filepath.Join(filepath.Dir(position.FileName), name)
+	for _, included := range preprocessor.files {
+		if included == fileName {
+			preprocessor.errorf(position, name, line.note, "file %s includes itself", fileName)
+			return
+		}
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		preprocessor.errorf(position, name, line.note, "cannot include %s: %v", name, unwrapPathError(err))
+		return
+	}
+	defer file.Close()
+	note := joinNotes("included at "+position.String(), line.note)
+	preprocessor.readFile(file, fileName, line.line, note)
+}
+
+// Replaces the constants in the code and adds it to the lines, the macros are
+// expanded with the replacements of their parameters and local labels
+func (preprocessor *preprocessor) expand(line sourceLine, indentation, code string, replacements map[string]string) {
+	code = replaceSymbols(replaceSymbols(code, replacements), preprocessor.constants)
+	if line.source == "" {
+		line.source = "+ " + code
+	}
+	name := firstField(code)
+	called, isMacro := preprocessor.macros[name]
+	if !isMacro {
+		line.text = indentation + code
+		preprocessor.lines = append(preprocessor.lines, line)
+		return
+	}
+	position := line.position
+	position.Column = len(indentation) + 1
+	var arguments []string
+	if rest := strings.TrimSpace(code[len(name):]); rest != "" {
+		for _, argument := range strings.Split(rest, ",") {
+			arguments = append(arguments, strings.TrimSpace(argument))
+		}
+	}
+	if len(arguments) != len(called.parameters) {
+		preprocessor.errorf(position, called.name, line.note, "macro %s expects %d arguments, found %d", called.name, len(called.parameters), len(arguments))
+		return
+	}
+	for _, expanding := range preprocessor.expanding {
+		if expanding == called.name {
+			preprocessor.errorf(position, called.name, line.note, "macro %s invokes itself", called.name)
+			return
+		}
+	}
+
+	// The invocation is listed, its lines follow it
+	invocation := line
+	invocation.text = ""
+	preprocessor.lines = append(preprocessor.lines, invocation)
+	preprocessor.expansions++
+	expansion := make(map[string]string)
+	for i, parameter := range called.parameters {
+		expansion[parameter] = arguments[i]
+	}
+	for _, body := range called.body {
+		if _, bodyCode := splitCode(body.text); strings.HasPrefix(bodyCode, "(") && strings.HasSuffix(bodyCode, ")") {
+			label := bodyCode[1 : len(bodyCode)-1]
+			expansion[label] = label + "$" + called.name + "." + strconv.Itoa(preprocessor.expansions)
+		}
+	}
+	preprocessor.expanding = append(preprocessor.expanding, called.name)
+	note := joinNotes("in macro "+called.name+" invoked at "+position.String(), line.note)
+	for _, body := range called.body {
+		bodyIndentation, bodyCode := splitCode(body.text)
+		expanded := sourceLine{position: body.position, line: line.line, note: note}
+		preprocessor.expand(expanded, bodyIndentation, bodyCode, expansion)
+	}
+	preprocessor.expanding = preprocessor.expanding[:len(preprocessor.expanding)-1]
+}
+
+// Checks the name of a constant, a macro or a parameter
+func (preprocessor *preprocessor) checkName(position diagnostics.Position, name, note string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' || strings.IndexFunc(name, func(c rune) bool { return c > 127 || !isSymbolChar(byte(c)) }) != -1 {
+		preprocessor.errorf(position, name, note, "invalid name %q", name)
+		return false
+	}
+	return true
+}
+
+// Checks that the name of a constant or a parameter is not a destination or
+// jump mnemonic, which would be replaced in the C-instructions as well
+func (preprocessor *preprocessor) checkMnemonic(position diagnostics.Position, name, note string) bool {
+	_, isDestination := GetDestinationCode(name)
+	_, isJump := GetJumpCode(name)
+	if name != "" && (isDestination || isJump) {
+		preprocessor.errorf(position, name, note, "mnemonic %s cannot be redefined", name)
+		return false
+	}
+	return true
+}
+
+func (preprocessor *preprocessor) errorf(position diagnostics.Position, token, note, format string, args ...interface{}) {
+	preprocessor.diagnostics.Add(withNote(diagnostics.Errorf(position, token, format, args...), note))
+}
+
+// Sets the note of the diagnostic
+func withNote(diagnostic *diagnostics.Diagnostic, note string) *diagnostics.Diagnostic {
+	diagnostic.Note = note
+	return diagnostic
+}
+
+// Joins the note of the line with the note of the line which produced it
+func joinNotes(note, outer string) string {
+	if outer == "" {
+		return note
+	}
+	return note + ", " + outer
+}
+
+// Returns the first word of the code, empty if there is none
+func firstField(code string) string {
+	if fields := strings.Fields(code); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// Splits the line into its indentation and its code without the comment
+func splitCode(text string) (string, string) {
+	if commentIndex := strings.Index(text, "//"); commentIndex != -1 {
+		text = text[:commentIndex]
+	}
+	trimmed := strings.TrimLeft(text, " \t")
+	return text[:len(text)-len(trimmed)], strings.TrimSpace(trimmed)
+}
+
+// Replaces the symbols of the code found in the replacements,
+// the symbols which are a part of a longer symbol are kept
+func replaceSymbols(code string, replacements map[string]string) string {
+	if len(replacements) == 0 {
+		return code
+	}
+	var result strings.Builder
+	for i := 0; i < len(code); {
+		if !isSymbolChar(code[i]) {
+			result.WriteByte(code[i])
+			i++
+			continue
+		}
+		start := i
+		for i < len(code) && isSymbolChar(code[i]) {
+			i++
+		}
+		if replacement, found := replacements[code[start:i]]; found {
+			result.WriteString(replacement)
+		} else {
+			result.WriteString(code[start:i])
+		}
+	}
+	return result.String()
+}
+
+func isSymbolChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_.$:", c) != -1
+}
+
+// Returns the error of the operation on the file without the file name
+func unwrapPathError(err error) error {
+	if pathError, ok := err.(*os.PathError); ok {
+		return pathError.Err
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package assembler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Assembles Main.asm written with the other files into a temporary directory
+func assembleFiles(t *testing.T, files map[string]string) (string, error) {
+	t.Helper()
+	directory := t.TempDir()
+	for fileName, code := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(directory, fileName)), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(directory, fileName), []byte(code), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err := os.Open(filepath.Join(directory, "Main.asm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var code bytes.Buffer
+	err = Assemble(file, &code)
+	return code.String(), err
+}
+
+// Checks the expansion of the directives against the same program written
+// without them
+func TestPreprocessor(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{"define", map[string]string{"Main.asm": `
+.define SIZE 16
+@SIZE
+D=A
+`}, "@16\nD=A"},
+		{"define containing a mnemonic", map[string]string{"Main.asm": `
+.define DX 5
+.define END_JMP 7
+@DX
+D=A
+@END_JMP
+0;JMP
+`}, "@5\nD=A\n@7\n0;JMP"},
+		{"include", map[string]string{
+			"Main.asm":  ".include \"lib.asm\"\n@SIZE\nD=A\n",
+			"lib.asm":   ".define SIZE 8\n.include \"sub/x.asm\"\n",
+			"sub/x.asm": "@1\n",
+		}, "@1\n@8\nD=A"},
+		{"macro", map[string]string{"Main.asm": `
+.macro MOVE from, to
+@from
+D=M
+@to
+M=D
+.endm
+MOVE R1, R2
+MOVE x, y
+`}, "@R1\nD=M\n@R2\nM=D\n@x\nD=M\n@y\nM=D"},
+		{"local labels", map[string]string{"Main.asm": `
+.macro WAIT
+(LOOP)
+@LOOP
+0;JMP
+.endm
+WAIT
+WAIT
+@LOOP
+`}, "(L1)\n@L1\n0;JMP\n(L2)\n@L2\n0;JMP\n@LOOP"},
+		{"nested macros", map[string]string{"Main.asm": `
+.macro SET address, value
+@value
+D=A
+@address
+M=D
+.endm
+.macro CLEAR address
+SET address, 0
+.endm
+CLEAR R5
+`}, "@0\nD=A\n@R5\nM=D"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := assembleFiles(t, test.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expected bytes.Buffer
+			if err := Assemble(strings.NewReader(test.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			compareLines(t, found, expected.String())
+		})
+	}
+}
+
+// Checks the errors of the directives, each reported once at the line
+// of the directive or the invocation
+func TestPreprocessorErrors(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{"missing include", map[string]string{"Main.asm": ".include \"lib.asm\"\n"},
+			"Main.asm:1:1: cannot include lib.asm"},
+		{"include without quotes", map[string]string{"Main.asm": ".include lib.asm\n"},
+			"Main.asm:1:1: expected quoted file name after .include"},
+		{"recursive include", map[string]string{
+			"Main.asm": ".include \"lib.asm\"\n",
+			"lib.asm":  "@1\n.include \"lib.asm\"\n",
+		}, "lib.asm:2:1: file"},
+		{"define without value", map[string]string{"Main.asm": ".define SIZE\n"},
+			"Main.asm:1:1: expected name and value after .define"},
+		{"invalid name", map[string]string{"Main.asm": ".define 1X 5\n"},
+			`Main.asm:1:1: invalid name "1X"`},
+		{"define destination", map[string]string{"Main.asm": ".define D 5\nD=A\n"},
+			"Main.asm:1:1: mnemonic D cannot be redefined"},
+		{"define unordered destination", map[string]string{"Main.asm": ".define MD 5\nMD=A\n"},
+			"Main.asm:1:1: mnemonic MD cannot be redefined"},
+		{"define jump", map[string]string{"Main.asm": ".define JMP 1\n0;JMP\n"},
+			"Main.asm:1:1: mnemonic JMP cannot be redefined"},
+		{"mnemonic parameter", map[string]string{"Main.asm": ".macro SET A, value\n@value\nD=A\n.endm\n"},
+			"Main.asm:1:1: mnemonic A cannot be redefined"},
+		{"unknown directive", map[string]string{"Main.asm": "@1\n  .org 100\n"},
+			"Main.asm:2:3: unknown directive .org"},
+		{"endm without macro", map[string]string{"Main.asm": ".endm\n"},
+			"Main.asm:1:1: .endm without .macro"},
+		{"macro not terminated", map[string]string{"Main.asm": "@1\n.macro M\n@2\n"},
+			"Main.asm:2:1: macro M is not terminated by .endm"},
+		{"macro redefined", map[string]string{"Main.asm": ".macro M\n.endm\n.macro M\n.endm\n"},
+			"Main.asm:3:1: macro M is already defined at"},
+		{"directive inside macro", map[string]string{"Main.asm": ".macro M\n.include \"lib.asm\"\n.endm\n"},
+			"Main.asm:2:1: directive .include inside macro M"},
+		{"argument count", map[string]string{"Main.asm": ".macro M a, b\n@a\n.endm\nM 1\n"},
+			"Main.asm:4:1: macro M expects 2 arguments, found 1"},
+		{"recursive macro", map[string]string{"Main.asm": ".macro M\nM\n.endm\nM\n"},
+			"Main.asm:2:1: macro M invokes itself (in macro M invoked at"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := assembleFiles(t, test.files)
+			if err == nil {
+				t.Fatalf("expected %s", test.expected)
+			}
+			if !strings.Contains(err.Error(), test.expected) || strings.Contains(err.Error(), "\n") {
+				t.Errorf("expected only %s, found:\n%v", test.expected, err)
+			}
+		})
+	}
+}
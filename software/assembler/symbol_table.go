@@ -1,4 +1,4 @@
-package main
+package assembler
 
 import "strconv"
 
@@ -6,6 +6,9 @@ import "strconv"
 type SymbolTable struct {
 	nextVariableAddress int
 	table               map[string]int
+	// Labels and variables of the program in the order of their definition
+	labels    []string
+	variables []string
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -30,6 +33,21 @@ func (symbolTable *SymbolTable) AddEntry(symbol string, address int) {
 	symbolTable.table[symbol] = address
 }
 
+// Adds the label of the instruction at the given ROM address
+func (symbolTable *SymbolTable) AddLabel(symbol string, address int) {
+	symbolTable.AddEntry(symbol, address)
+	symbolTable.labels = append(symbolTable.labels, symbol)
+}
+
+// Allocates the next RAM address to the variable and returns it
+func (symbolTable *SymbolTable) AddVariable(symbol string) int {
+	address := symbolTable.nextVariableAddress
+	symbolTable.AddEntry(symbol, address)
+	symbolTable.variables = append(symbolTable.variables, symbol)
+	symbolTable.nextVariableAddress++
+	return address
+}
+
 // True if contains given symbol
 func (symbolTable *SymbolTable) HasSymbol(symbol string) bool {
 	_, has := symbolTable.table[symbol]
@@ -1,30 +1,141 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"nand2tetris/software/diagnostics"
+	"nand2tetris/software/jackc"
 )
 
 func main() {
-	usage := "Usage: " + os.Args[0] + " name of the directory containg .jack files"
-	if len(os.Args) != 2 {
-		fmt.Println(usage)
+	level := flag.Int("O", jackc.NO_OPTIMIZATION, "optimization level of the expressions: 1 folds constants, 2 also replaces multiplications by powers of two")
+	strict := flag.Bool("strict", false, "report the warnings of the type checker as errors")
+	poolStrings := flag.Bool("pool-strings", false, "create each distinct string constant of a class once and reuse it")
+	precedence := flag.Bool("precedence", false, "evaluate * and / before + and -, before the comparisons, before & and |")
+	strip := flag.Bool("strip", false, "leave out the subroutines which are never called from Main.main")
+	extended := flag.Bool("extended", false, "accept for loops, else if, break, continue, +=, -=, ++ and --")
+	tokens := flag.Bool("tokens", false, "write the tokens of each class to XxxT.xml instead of compiling")
+	tree := flag.Bool("tree", false, "write the parse tree of each class to Xxx.xml instead of compiling")
+	outputDirectory := flag.String("d", "", "directory of the .xml files, the directory of the .jack files by default")
+	flag.Usage = func() {
+		fmt.Println("Usage: " + os.Args[0] + " [-O level] [--strict] [--extended] [--precedence] [--pool-strings] [--tokens] [--tree] [-d directory] name of the directory containg .jack files")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	directoryName := os.Args[1]
-	files, _ := ioutil.ReadDir(directoryName)
+	var err error
+	if *tokens || *tree {
+		err = analyzeDirectory(flag.Arg(0), *outputDirectory, *tokens, *tree)
+	} else {
+		options := jackc.Options{OptimizationLevel: *level, Strict: *strict, Extended: *extended, Precedence: *precedence, PoolStrings: *poolStrings, StripUnused: *strip, Warnings: os.Stderr}
+		err = compileDirectory(flag.Arg(0), options)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-	for _, file := range files {
-		idx := strings.LastIndex(file.Name(), ".jack")
-		if idx == -1 {
-			continue
+// Compiles the .jack files of the directory together into a .vm file each,
+// so that the calls between the classes are checked, a program may only call
+// the classes of the directory and the OS. The errors of all the files are
+// returned together, no .vm file is written if there are any.
+func compileDirectory(directoryName string, options jackc.Options) error {
+	sources, fileNames, err := readSources(directoryName)
+	if err != nil {
+		return err
+	}
+	options.CheckClasses = true
+	code, err := jackc.CompileClasses(sources, options)
+	if err != nil {
+		return err
+	}
+	for i, fileName := range fileNames {
+		if err := os.WriteFile(fileName+".vm", code[i], 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the tokens and the parse trees of the .jack files of the directory
+// as XxxT.xml and Xxx.xml into the output directory. The syntax errors of all
+// the files are returned together, no .xml file is written if there are any.
+func analyzeDirectory(directoryName, outputDirectory string, tokens, tree bool) error {
+	sources, fileNames, err := readSources(directoryName)
+	if err != nil {
+		return err
+	}
+	if outputDirectory == "" {
+		outputDirectory = directoryName
+	}
+	var errors diagnostics.List
+	outputs := make(map[string][]byte)
+	for i, source := range sources {
+		baseName := filepath.Join(outputDirectory, filepath.Base(fileNames[i]))
+		code := source.Reader.(*bytes.Reader)
+		if tokens {
+			var buffer bytes.Buffer
+			err := jackc.WriteTokens(code, source.Name, &buffer)
+			if !tree {
+				// The parser reports the same errors of the tokenizer
+				errors.AddError(err)
+			}
+			outputs[baseName+"T.xml"] = buffer.Bytes()
+			code.Seek(0, io.SeekStart)
 		}
-		compilationEngine := NewCompilationEngine(directoryName + file.Name()[0:idx])
-		defer compilationEngine.Close()
+		if tree {
+			class, err := jackc.NewParser(code, source.Name).ParseClass()
+			errors.AddError(err)
+			var buffer bytes.Buffer
+			if err == nil {
+				jackc.WriteTree(class, &buffer)
+			}
+			outputs[baseName+".xml"] = buffer.Bytes()
+		}
+	}
+	if err := errors.Err(); err != nil {
+		return err
+	}
+	for fileName, output := range outputs {
+		if err := os.WriteFile(fileName, output, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the .jack files of the directory, returns them together
+// with their paths without the extension
+func readSources(directoryName string) ([]jackc.Source, []string, error) {
+	entries, err := os.ReadDir(directoryName)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		compilationEngine.CompileClass()
+	var sources []jackc.Source
+	var fileNames []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".jack" {
+			continue
+		}
+		fileName := filepath.Join(directoryName, strings.TrimSuffix(entry.Name(), ".jack"))
+		code, err := os.ReadFile(fileName + ".jack")
+		if err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, jackc.Source{Name: fileName + ".jack", Reader: bytes.NewReader(code)})
+		fileNames = append(fileNames, fileName)
 	}
+	return sources, fileNames, nil
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Checks the XML files written into the directory of the sources, given
+// without the trailing slash, and into the output directory
+func TestAnalyzeDirectory(t *testing.T) {
+	directory := t.TempDir()
+	for fileName, code := range map[string]string{
+		"Main.jack":  "class Main { function void main() { return; } }",
+		"Point.jack": "class Point { field int x; }",
+		"notes.txt":  "not a class",
+	} {
+		if err := os.WriteFile(filepath.Join(directory, fileName), []byte(code), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(directory, "xml")
+	if err := os.Mkdir(output, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		outputDirectory string
+		expected        string
+	}{
+		{"", directory},
+		{output, output},
+	} {
+		if err := analyzeDirectory(directory, test.outputDirectory, true, true); err != nil {
+			t.Fatal(err)
+		}
+		for _, fileName := range []string{"Main.xml", "MainT.xml", "Point.xml", "PointT.xml"} {
+			if _, err := os.Stat(filepath.Join(test.expected, fileName)); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
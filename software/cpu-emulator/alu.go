@@ -0,0 +1,64 @@
+package main
+
+// ALU control bits in the order in which they appear in the compute
+// instruction: zx, nx, zy, ny, f, no.
+const (
+	ALU_NO = 1 << iota
+	ALU_F  = 1 << iota
+	ALU_NY = 1 << iota
+	ALU_ZY = 1 << iota
+	ALU_NX = 1 << iota
+	ALU_ZX = 1 << iota
+)
+
+// Computes the ALU function selected by the six control bits, exactly like
+// hardware/alu/ALU.hdl. Returns the output and the zr and ng flags.
+func Compute(x, y int16, control int) (int16, bool, bool) {
+	if control&ALU_ZX != 0 {
+		x = 0
+	}
+	if control&ALU_NX != 0 {
+		x = ^x
+	}
+	if control&ALU_ZY != 0 {
+		y = 0
+	}
+	if control&ALU_NY != 0 {
+		y = ^y
+	}
+	var out int16
+	if control&ALU_F != 0 {
+		out = x + y
+	} else {
+		out = x & y
+	}
+	if control&ALU_NO != 0 {
+		out = ^out
+	}
+	return out, out == 0, out < 0
+}
+
+// Control bits of the shifts of the extended instruction set, the other
+// control bits are 0
+const (
+	SHIFT_X    = ALU_NX
+	SHIFT_LEFT = ALU_ZX
+)
+
+// Shifts x if SHIFT_X is set, y otherwise, by one bit to the left if
+// SHIFT_LEFT is set, to the right keeping the sign otherwise. Returns the
+// output, the zr and ng flags and false if the control bits are undefined.
+func Shift(x, y int16, control int) (int16, bool, bool, bool) {
+	if control&^(SHIFT_X|SHIFT_LEFT) != 0 {
+		return 0, false, false, false
+	}
+	in := y
+	if control&SHIFT_X != 0 {
+		in = x
+	}
+	out := in >> 1
+	if control&SHIFT_LEFT != 0 {
+		out = in << 1
+	}
+	return out, out == 0, out < 0, true
+}
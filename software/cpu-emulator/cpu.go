@@ -0,0 +1,187 @@
+package main
+
+import "fmt"
+
+const (
+	JUMP_GT = 1 << iota
+	JUMP_EQ = 1 << iota
+	JUMP_LT = 1 << iota
+)
+
+// Highest three bits of the shift instructions of the extended instruction set
+const SHIFT_PREFIX = 0x5
+
+const (
+	DEST_M = 1 << iota
+	DEST_D = 1 << iota
+	DEST_A = 1 << iota
+)
+
+// The Hack Central Processing Unit together with the instruction memory and
+// the data memory, modelled after hardware/computer-architecture/CPU.hdl.
+// Each call to Step executes a single instruction, i.e. one clock cycle.
+// The instructions beginning with 101 are the shifts if Extended is set,
+// otherwise the bits 13 and 14 are ignored like by the hardware.
+type CPU struct {
+	A      int16
+	D      int16
+	PC     int
+	Cycles int
+	Memory *Memory
+	rom    [ROM_SIZE]int16
+	size   int
+	// Executes the shifts of the extended instruction set
+	Extended bool
+	// Last backward jump, see checkLoop
+	loop *loopState
+	// Set when a loop repeats without changing the state of the computer
+	halted bool
+}
+
+// State of the computer when the backward jump to target was taken,
+// writes holds the previous values of the memory written since then
+type loopState struct {
+	target int
+	a, d   int16
+	writes map[int]int16
+}
+
+// Creates new CPU with the given program stored in the ROM
+func NewCPU(program []int16) *CPU {
+	cpu := &CPU{Memory: NewMemory(), size: len(program)}
+	copy(cpu.rom[:], program)
+	return cpu
+}
+
+// Restarts the program, the registers and the memory are left unchanged
+func (cpu *CPU) Reset() {
+	cpu.PC = 0
+	cpu.loop = nil
+	cpu.halted = false
+}
+
+// Executes the instruction pointed by PC
+func (cpu *CPU) Step() error {
+	instruction := cpu.rom[cpu.PC]
+	cpu.Cycles++
+	if instruction >= 0 {
+		cpu.A = instruction
+		cpu.PC = (cpu.PC + 1) % ROM_SIZE
+		return nil
+	}
+
+	address := int(cpu.A) & 0x7FFF
+	y := cpu.A
+	if instruction&0x1000 != 0 {
+		inM, err := cpu.Memory.Read(address)
+		if err != nil {
+			return fmt.Errorf("ROM[%d]: %v", cpu.PC, err)
+		}
+		y = inM
+	}
+	control := int(instruction>>6) & 0x3F
+	var out int16
+	var zr, ng bool
+	if cpu.Extended && uint16(instruction)>>13 == SHIFT_PREFIX {
+		var defined bool
+		if out, zr, ng, defined = Shift(cpu.D, y, control); !defined {
+			return fmt.Errorf("ROM[%d]: undefined shift instruction %016b", cpu.PC, uint16(instruction))
+		}
+	} else {
+		out, zr, ng = Compute(cpu.D, y, control)
+	}
+
+	dest := int(instruction>>3) & 0x7
+	if dest&DEST_M != 0 {
+		if cpu.loop != nil {
+			if _, written := cpu.loop.writes[address]; !written {
+				cpu.loop.writes[address], _ = cpu.Memory.Read(address)
+			}
+		}
+		if err := cpu.Memory.Write(address, out); err != nil {
+			return fmt.Errorf("ROM[%d]: %v", cpu.PC, err)
+		}
+	}
+	if dest&DEST_D != 0 {
+		cpu.D = out
+	}
+	jumpTarget := address
+	if dest&DEST_A != 0 {
+		cpu.A = out
+	}
+
+	if isJump(int(instruction)&0x7, zr, ng) {
+		if jumpTarget <= cpu.PC {
+			cpu.checkLoop(jumpTarget)
+		}
+		cpu.PC = jumpTarget
+	} else {
+		cpu.PC = (cpu.PC + 1) % ROM_SIZE
+	}
+	return nil
+}
+
+// Executes at most the given number of instructions, stopping earlier when
+// the program reaches a halt loop. Returns true if the program halted.
+func (cpu *CPU) Run(cycles int) (bool, error) {
+	for i := 0; i < cycles; i++ {
+		if cpu.IsHalted() {
+			return true, nil
+		}
+		if err := cpu.Step(); err != nil {
+			return false, err
+		}
+	}
+	return cpu.IsHalted(), nil
+}
+
+// True if the CPU is stuck in an infinite loop which does not change
+// the state of the computer, like the common idiom (END) @END 0;JMP
+// or the loop of Sys.halt, or if it has run past the end of the program.
+func (cpu *CPU) IsHalted() bool {
+	if cpu.halted || cpu.PC >= cpu.size {
+		return true
+	}
+	instruction := cpu.rom[cpu.PC]
+	// Unconditional jump which does not store the result anywhere
+	if instruction >= 0 || instruction&0x3F != 0x07 {
+		return false
+	}
+	target := int(cpu.A) & 0x7FFF
+	if target == cpu.PC {
+		return true
+	}
+	return target == cpu.PC-1 && int(cpu.rom[target]) == target
+}
+
+// Compares the state of the computer with the state at the previous backward
+// jump to the same target. The loop is halted if the registers are the same
+// and the memory written in between holds its previous values again.
+// The keyboard is not part of the state, a loop waiting for a key which
+// is never pressed is halted as well.
+func (cpu *CPU) checkLoop(target int) {
+	if loop := cpu.loop; loop != nil && loop.target == target && loop.a == cpu.A && loop.d == cpu.D {
+		unchanged := true
+		for address, value := range loop.writes {
+			if current, _ := cpu.Memory.Read(address); current != value {
+				unchanged = false
+				break
+			}
+		}
+		if unchanged {
+			cpu.halted = true
+			return
+		}
+	}
+	if cpu.loop == nil {
+		cpu.loop = &loopState{writes: make(map[int]int16)}
+	}
+	cpu.loop.target, cpu.loop.a, cpu.loop.d = target, cpu.A, cpu.D
+	clear(cpu.loop.writes)
+}
+
+func isJump(jump int, zr, ng bool) bool {
+	return (jump&JUMP_LT != 0 && ng) ||
+		(jump&JUMP_EQ != 0 && zr) ||
+		(jump&JUMP_GT != 0 && !ng && !zr)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nand2tetris/software/assembler"
+)
+
+// Maximal number of cycles of the test programs
+const TEST_CYCLES = 100000
+
+// Runs the programs of assembler-examples, assembled into testdata, and
+// checks the memory after they halt
+func TestExamples(t *testing.T) {
+	for _, test := range []struct {
+		program  string
+		initial  map[int]int16
+		expected map[int]int16
+	}{
+		{"Add.hack", nil, map[int]int16{0: 5}},
+		{"Max.hack", map[int]int16{0: 3, 1: 5}, map[int]int16{2: 5}},
+		{"Max.hack", map[int]int16{0: 9, 1: -2}, map[int]int16{2: 9}},
+		{"Mult.hack", map[int]int16{0: 6, 1: 7}, map[int]int16{2: 42}},
+		{"Mult.hack", map[int]int16{0: 0, 1: 7}, map[int]int16{2: 0}},
+		{"Rect.hack", map[int]int16{0: 4}, map[int]int16{SCREEN_ADDRESS: -1, SCREEN_ADDRESS + 96: -1, SCREEN_ADDRESS + 128: 0}},
+	} {
+		t.Run(test.program, func(t *testing.T) {
+			program, err := LoadProgram(filepath.Join("testdata", test.program), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cpu := NewCPU(program)
+			for address, value := range test.initial {
+				cpu.Memory.Write(address, value)
+			}
+			checkRun(t, cpu, true)
+			checkMemory(t, cpu, test.expected)
+		})
+	}
+}
+
+// Checks which loops are recognized as the end of the program
+func TestHaltLoops(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		code   []string
+		key    int16
+		halted bool
+	}{
+		{"jump to itself", []string{
+			"0000000000000000", // (END) @END
+			"1110101010000111", // 0;JMP
+		}, 0, true},
+		{"jump to the A-instruction", []string{
+			"0000000000000101", // @5
+			"1110110000010000", // D=A
+			"0000000000000010", // (END) @END
+			"1110101010000111", // 0;JMP
+		}, 0, true},
+		{"end of the program", []string{
+			"0000000000000101", // @5
+			"1110110000010000", // D=A
+		}, 0, true},
+		// The loop of Sys.halt pushes true and pops it again
+		{"loop restoring the memory", []string{
+			"0000000100000000", // @256
+			"1110110000010000", // D=A
+			"0000000000000000", // @SP
+			"1110001100001000", // M=D
+			"0000000000000000", // (LOOP) @SP
+			"1111110111101000", // AM=M+1
+			"1110110010100000", // A=A-1
+			"1110111010001000", // M=-1
+			"0000000000000000", // @SP
+			"1111110010101000", // AM=M-1
+			"1111110000010000", // D=M
+			"0000000000000100", // @LOOP
+			"1110001100000101", // D;JNE
+		}, 0, true},
+		{"loop changing the memory", []string{
+			"0000000000010000", // (LOOP) @i
+			"1111110111001000", // M=M+1
+			"0000000000000000", // @LOOP
+			"1110101010000111", // 0;JMP
+		}, 0, false},
+		{"loop changing D", []string{
+			"1110011111010000", // (LOOP) D=D+1
+			"0000000000000000", // @LOOP
+			"1110101010000111", // 0;JMP
+		}, 0, false},
+		{"waiting for a key", waitForKey, 0, true},
+		{"key pressed", waitForKey, 65, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			program, err := ReadProgram(strings.NewReader(strings.Join(test.code, "\n")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cpu := NewCPU(program)
+			cpu.Memory.SetKey(test.key)
+			checkRun(t, cpu, test.halted)
+		})
+	}
+}
+
+// Waits until a key is pressed, then loops changing the memory
+var waitForKey = []string{
+	"0110000000000000", // (WAIT) @KBD
+	"1111110000010000", // D=M
+	"0000000000000000", // @WAIT
+	"1110001100000010", // D;JEQ
+	"0000000000010000", // (LOOP) @i
+	"1111110111001000", // M=M+1
+	"0000000000000100", // @LOOP
+	"1110101010000111", // 0;JMP
+}
+
+// Executes the shifts of the extended instruction set
+func TestShifts(t *testing.T) {
+	for _, test := range []struct {
+		code     string
+		expected map[int]int16
+	}{
+		{"@6 D=A D=D<< @0 M=D", map[int]int16{0: 12}},
+		{"@6 D=A D=D>> @0 M=D", map[int]int16{0: 3}},
+		{"@0x4001 A=A<< D=A @0 M=D", map[int]int16{0: -0x7FFE}},
+		{"@1 M=-1 M=M>> M=M<<", map[int]int16{1: -2}},
+		{"@1 M=1 M=M<<", map[int]int16{1: 2}},
+		// The jump tests the shifted value
+		{"@END D=-1 D=D<<;JLT @0 M=1 (END)", map[int]int16{0: 0}},
+		{"@END D=1 D=D>>;JEQ @0 M=1 (END)", map[int]int16{0: 0}},
+		{"@END D=1 D=D<<;JLE @0 M=1 (END)", map[int]int16{0: 1}},
+	} {
+		t.Run(test.code, func(t *testing.T) {
+			cpu := NewCPU(assemble(t, test.code, true))
+			cpu.Extended = true
+			checkRun(t, cpu, true)
+			checkMemory(t, cpu, test.expected)
+		})
+	}
+}
+
+// Assembles the code, the instructions may be separated by spaces
+func assemble(t *testing.T, code string, extended bool) []int16 {
+	t.Helper()
+	var hack bytes.Buffer
+	source := strings.Join(strings.Fields(code), "\n")
+	if err := assembler.AssembleWith(strings.NewReader(source), &hack, assembler.Options{Extended: extended}); err != nil {
+		t.Fatal(err)
+	}
+	program, err := ReadProgram(&hack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func checkRun(t *testing.T, cpu *CPU, halted bool) {
+	t.Helper()
+	stopped, err := cpu.Run(TEST_CYCLES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped != halted {
+		t.Fatalf("expected halted %v after %d cycles, found %v", halted, cpu.Cycles, stopped)
+	}
+}
+
+func checkMemory(t *testing.T, cpu *CPU, expected map[int]int16) {
+	t.Helper()
+	for address, value := range expected {
+		if found, _ := cpu.Memory.Read(address); found != value {
+			t.Errorf("expected RAM[%d]=%d, found %d", address, value, found)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/testscript"
+)
+
+// List of memory assignments given as address=value
+type assignments map[int]int16
+
+func (values assignments) String() string {
+	return fmt.Sprint(map[int]int16(values))
+}
+
+func (values assignments) Set(text string) error {
+	idx := strings.Index(text, "=")
+	if idx == -1 {
+		return fmt.Errorf("expected address=value, found %q", text)
+	}
+	address, err := strconv.Atoi(text[:idx])
+	if err != nil {
+		return fmt.Errorf("invalid address %q", text[:idx])
+	}
+	value, err := strconv.ParseInt(text[idx+1:], 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid value %q", text[idx+1:])
+	}
+	values[address] = int16(value)
+	return nil
+}
+
+func main() {
+	initial := make(assignments)
+	cycles := flag.Int("cycles", 1000000, "maximal number of executed instructions")
+	dump := flag.String("dump", "0-15", "range of RAM addresses printed after the execution, e.g. 0-2")
+	extended := flag.Bool("extended", false, "execute the instructions beginning with 101 as the shifts of the extended instruction set")
+	key := flag.Int("key", 0, "code of the key held down during the execution")
+	flag.Var(initial, "set", "initial memory value given as address=value, may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [options] name of the .hack or .asm file or of the .tst test script")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if strings.HasSuffix(flag.Arg(0), ".tst") {
+		result, err := testscript.RunFile(flag.Arg(0), &scriptBackend{extended: *extended}, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %s\n", flag.Arg(0), result)
+		return
+	}
+
+	first, last, err := testscript.ParseRange(*dump)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	program, err := LoadProgram(flag.Arg(0), *extended)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cpu := NewCPU(program)
+	cpu.Extended = *extended
+	cpu.Memory.SetKey(int16(*key))
+	for address, value := range initial {
+		if err := cpu.Memory.Write(address, value); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	halted, err := cpu.Run(*cycles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if halted {
+		fmt.Printf("Halted after %d cycles\n", cpu.Cycles)
+	} else {
+		fmt.Printf("Stopped after %d cycles\n", cpu.Cycles)
+	}
+	fmt.Printf("A=%d D=%d PC=%d\n", cpu.A, cpu.D, cpu.PC)
+	for address := first; address <= last; address++ {
+		value, err := cpu.Memory.Read(address)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("RAM[%d]=%d\n", address, value)
+	}
+}
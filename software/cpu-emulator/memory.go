@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+const (
+	RAM_SIZE       = 0x4000
+	SCREEN_ADDRESS = 0x4000
+	SCREEN_SIZE    = 0x2000
+	KBD_ADDRESS    = 0x6000
+)
+
+// The complete address space of the Hack computer's data memory, as described
+// in hardware/computer-architecture/Memory.hdl: 16K of RAM, followed by the 8K
+// screen memory map at SCREEN and a single keyboard register at KBD.
+// Access to any address above KBD is invalid.
+type Memory struct {
+	ram      [RAM_SIZE]int16
+	screen   [SCREEN_SIZE]int16
+	keyboard int16
+}
+
+// Creates zeroed memory
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Returns the value stored at the given address
+func (memory *Memory) Read(address int) (int16, error) {
+	switch {
+	case address >= 0 && address < SCREEN_ADDRESS:
+		return memory.ram[address], nil
+	case address >= SCREEN_ADDRESS && address < KBD_ADDRESS:
+		return memory.screen[address-SCREEN_ADDRESS], nil
+	case address == KBD_ADDRESS:
+		return memory.keyboard, nil
+	}
+	return 0, fmt.Errorf("invalid memory address %d", address)
+}
+
+// Stores the value at the given address. The keyboard register is read-only
+// for the program, writes to it are ignored just like in Memory.hdl.
+func (memory *Memory) Write(address int, value int16) error {
+	switch {
+	case address >= 0 && address < SCREEN_ADDRESS:
+		memory.ram[address] = value
+	case address >= SCREEN_ADDRESS && address < KBD_ADDRESS:
+		memory.screen[address-SCREEN_ADDRESS] = value
+	case address == KBD_ADDRESS:
+	default:
+		return fmt.Errorf("invalid memory address %d", address)
+	}
+	return nil
+}
+
+// Sets the code of the currently pressed key, 0 if no key is pressed
+func (memory *Memory) SetKey(key int16) {
+	memory.keyboard = key
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/assembler"
+)
+
+const ROM_SIZE = 0x8000
+
+// Reads a program in the Hack machine language, one 16 characters long binary
+// instruction per line, as produced by the assembler.
+func ReadProgram(reader io.Reader) ([]int16, error) {
+	var program []int16
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+		if len(text) != 16 {
+			return nil, fmt.Errorf("line %d: expected 16 bits instruction, found %q", line, text)
+		}
+		instruction, err := strconv.ParseUint(text, 2, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: expected binary instruction, found %q", line, text)
+		}
+		if len(program) == ROM_SIZE {
+			return nil, fmt.Errorf("line %d: program does not fit into %d words of ROM", line, ROM_SIZE)
+		}
+		program = append(program, int16(instruction))
+	}
+	return program, scanner.Err()
+}
+
+// Reads a program from the given .hack file, an .asm file is assembled
+// in the memory first, with the extended instruction set if extended is set
+func LoadProgram(fileName string, extended bool) ([]int16, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if filepath.Ext(fileName) == ".asm" {
+		var code bytes.Buffer
+		if err := assembler.AssembleWith(file, &code, assembler.Options{Extended: extended}); err != nil {
+			return nil, err
+		}
+		reader = &code
+	}
+	program, err := ReadProgram(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return program, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"nand2tetris/software/testscript"
+)
+
+// Runs the test scripts of the CPU emulator, e.g. Max.tst. Variables are
+// the registers A, D and PC and the memory RAM[address].
+type scriptBackend struct {
+	cpu      *CPU
+	extended bool
+}
+
+func (backend *scriptBackend) Load(path string) error {
+	if extension := filepath.Ext(path); extension != ".hack" && extension != ".asm" {
+		return fmt.Errorf("%s: only .hack and .asm programs can be loaded", path)
+	}
+	program, err := LoadProgram(path, backend.extended)
+	if err != nil {
+		return err
+	}
+	backend.cpu = NewCPU(program)
+	backend.cpu.Extended = backend.extended
+	return nil
+}
+
+func (backend *scriptBackend) Get(name string) (int, error) {
+	if backend.cpu == nil {
+		return 0, fmt.Errorf("no program loaded")
+	}
+	switch name {
+	case "A":
+		return int(backend.cpu.A), nil
+	case "D":
+		return int(backend.cpu.D), nil
+	case "PC":
+		return backend.cpu.PC, nil
+	}
+	address, err := parseRAMAddress(name)
+	if err != nil {
+		return 0, err
+	}
+	value, err := backend.cpu.Memory.Read(address)
+	return int(value), err
+}
+
+func (backend *scriptBackend) Set(name string, value int) error {
+	if backend.cpu == nil {
+		return fmt.Errorf("no program loaded")
+	}
+	switch name {
+	case "A":
+		backend.cpu.A = int16(value)
+	case "D":
+		backend.cpu.D = int16(value)
+	case "PC":
+		if value < 0 || value >= ROM_SIZE {
+			return fmt.Errorf("PC %d out of range", value)
+		}
+		backend.cpu.PC = value
+	default:
+		address, err := parseRAMAddress(name)
+		if err != nil {
+			return err
+		}
+		return backend.cpu.Memory.Write(address, int16(value))
+	}
+	return nil
+}
+
+func (backend *scriptBackend) Execute(command string) error {
+	if backend.cpu == nil {
+		return fmt.Errorf("no program loaded")
+	}
+	if command != "ticktock" {
+		return fmt.Errorf("%s is not supported by the CPU emulator", command)
+	}
+	return backend.cpu.Step()
+}
+
+func (backend *scriptBackend) ExecutePart(part string, args []string, directory string) error {
+	return fmt.Errorf("unknown command %s", part)
+}
+
+func parseRAMAddress(name string) (int, error) {
+	variable, err := testscript.ParseVariable(name)
+	if err != nil {
+		return 0, err
+	}
+	if variable.Name != "RAM" || variable.Index == -1 {
+		return 0, fmt.Errorf("unknown variable %s", name)
+	}
+	return variable.Index, nil
+}
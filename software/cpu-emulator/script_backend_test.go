@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nand2tetris/software/assembler"
+	"nand2tetris/software/testscript"
+	"nand2tetris/software/vmtranslator"
+)
+
+const MAX_SCRIPT = `load Max.hack,
+output-file Max.out,
+compare-to Max.cmp,
+output-list RAM[0]%D2.6.2 RAM[1]%D2.6.2 RAM[2]%D2.6.2;
+set RAM[0] 3, set RAM[1] 5;
+repeat 14 {
+    ticktock;
+}
+output;
+set PC 0, set RAM[0] 23456, set RAM[1] 12345;
+repeat 14 {
+    ticktock;
+}
+output;
+`
+
+const MAX_COMPARE = `|  RAM[0]  |  RAM[1]  |  RAM[2]  |
+|       3  |       5  |       5  |
+|   23456  |   12345  |   23456  |
+`
+
+// Runs a script like Max.tst on the program assembled into testdata
+func TestScript(t *testing.T) {
+	directory := t.TempDir()
+	copyFile(t, filepath.Join("testdata", "Max.hack"), filepath.Join(directory, "Max.hack"))
+	for fileName, text := range map[string]string{"Max.tst": MAX_SCRIPT, "Max.cmp": MAX_COMPARE} {
+		if err := os.WriteFile(filepath.Join(directory, fileName), []byte(text), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := testscript.RunFile(filepath.Join(directory, "Max.tst"), &scriptBackend{}, io.Discard); err != nil {
+		t.Error(err)
+	}
+}
+
+// Runs the CPU emulator scripts of the VM examples, which load the .asm
+// program translated from the .vm files of their directory, with each
+// of the translation options
+func TestVMExampleScripts(t *testing.T) {
+	for _, test := range []struct {
+		directory string
+		script    string
+		program   string
+	}{
+		{"FibonacciElement", "FibonacciElement.tst", "FibonacciElement.asm"},
+		{"NestedCall", "NestedCall.tst", "NestedCall.asm"},
+		{"StaticTest", "StaticsTest.tst", "StaticsTest.asm"},
+	} {
+		for _, translation := range []struct {
+			name    string
+			options vmtranslator.Options
+		}{
+			{"plain", vmtranslator.Options{}},
+			{"optimized", vmtranslator.Options{Optimize: true}},
+			{"shared", vmtranslator.Options{SharedRoutines: true}},
+			{"optimized shared", vmtranslator.Options{Optimize: true, SharedRoutines: true}},
+			{"tree shaking", vmtranslator.Options{TreeShake: true}},
+		} {
+			t.Run(test.directory+"/"+translation.name, func(t *testing.T) {
+				runVMExampleScript(t, test.directory, test.script, test.program, translation.options)
+			})
+		}
+	}
+}
+
+// VM program counting the iterations of while (Sys.id(n)) with n = 5 into
+// temp 1, Jack translates the loop into not and if-goto, !5 is -6, so the
+// loop never runs
+const NEGATED_CONDITION = `function Sys.init 0
+push constant 5
+pop temp 0
+push constant 0
+pop temp 1
+label LOOP
+push temp 0
+call Sys.id 1
+not
+if-goto END
+push temp 1
+push constant 1
+add
+pop temp 1
+push temp 0
+push constant 1
+sub
+pop temp 0
+goto LOOP
+label END
+goto END
+function Sys.id 0
+push argument 0
+return
+`
+
+// Checks that the optimizations keep the meaning of a negated condition
+// which is not a boolean
+func TestVMNegatedCondition(t *testing.T) {
+	for _, translation := range []struct {
+		name    string
+		options vmtranslator.Options
+	}{
+		{"plain", vmtranslator.Options{}},
+		{"optimized", vmtranslator.Options{Optimize: true}},
+		{"optimized shared", vmtranslator.Options{Optimize: true, SharedRoutines: true}},
+	} {
+		t.Run(translation.name, func(t *testing.T) {
+			var code, hack bytes.Buffer
+			sources := []vmtranslator.Source{{Name: "Sys.vm", Reader: strings.NewReader(NEGATED_CONDITION)}}
+			if _, err := vmtranslator.TranslateWith(sources, &code, translation.options); err != nil {
+				t.Fatal(err)
+			}
+			if err := assembler.Assemble(&code, &hack); err != nil {
+				t.Fatal(err)
+			}
+			program, err := ReadProgram(&hack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cpu := NewCPU(program)
+			checkRun(t, cpu, true)
+			checkMemory(t, cpu, map[int]int16{5: 5, 6: 0})
+		})
+	}
+}
+
+// Translates the .vm files of the directory with the options into the program
+// in a temporary directory and runs the script there
+func runVMExampleScript(t *testing.T, directoryName, script, program string, options vmtranslator.Options) {
+	t.Helper()
+	directory := filepath.Join("..", "virtual-machine-examples", directoryName)
+	temporary := t.TempDir()
+	fileNames, err := filepath.Glob(filepath.Join(directory, "*.vm"))
+	if err != nil || len(fileNames) == 0 {
+		t.Fatalf("no .vm files in %s: %v", directory, err)
+	}
+	var sources []vmtranslator.Source
+	for _, fileName := range fileNames {
+		file, err := os.Open(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		sources = append(sources, vmtranslator.Source{Name: filepath.Base(fileName), Reader: file})
+	}
+	output, err := os.Create(filepath.Join(temporary, program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+	if _, err := vmtranslator.TranslateWith(sources, output, options); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{script, strings.TrimSuffix(script, ".tst") + ".cmp"} {
+		copyFile(t, filepath.Join(directory, name), filepath.Join(temporary, name))
+	}
+
+	if _, err := testscript.RunFile(filepath.Join(temporary, script), &scriptBackend{}, io.Discard); err != nil {
+		t.Error(err)
+	}
+}
+
+func copyFile(t *testing.T, source, destination string) {
+	t.Helper()
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(destination, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Location in the source file, lines and columns are numbered from 1
+type Position struct {
+	FileName string
+	Line     int
+	Column   int
+}
+
+func (position Position) String() string {
+	return position.FileName + ":" + strconv.Itoa(position.Line) + ":" + strconv.Itoa(position.Column)
+}
+
+// Problem found in the source file. Either Expected and Found describe
+// what the tool expected at the position of the offending token,
+// or Message describes the problem. Warnings do not stop the tools.
+type Diagnostic struct {
+	Position
+	Token    string
+	Expected string
+	Found    string
+	Message  string
+	Warning  bool
+	// Context of the position, e.g. the macro invocation which
+	// produced the line, printed after the description
+	Note string
+}
+
+// Creates a diagnostic of the token which is not what the tool expected
+func Expected(position Position, token, expected, found string) *Diagnostic {
+	return &Diagnostic{Position: position, Token: token, Expected: expected, Found: found}
+}
+
+// Creates a diagnostic of the token with the formatted message
+func Errorf(position Position, token string, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{Position: position, Token: token, Message: fmt.Sprintf(format, args...)}
+}
+
+// Creates a warning of the token with the formatted message
+func Warningf(position Position, token string, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{Position: position, Token: token, Message: fmt.Sprintf(format, args...), Warning: true}
+}
+
+// Formats the diagnostic as file:line:col: message,
+// warnings as file:line:col: warning: message, followed by the note
+func (diagnostic *Diagnostic) Error() string {
+	if diagnostic.FileName == "" {
+		return diagnostic.Message
+	}
+	prefix := ""
+	if diagnostic.Warning {
+		prefix = "warning: "
+	}
+	note := ""
+	if diagnostic.Note != "" {
+		note = " (" + diagnostic.Note + ")"
+	}
+	if diagnostic.Expected != "" {
+		return fmt.Sprintf("%v: %sexpected %s, found %s%s", diagnostic.Position, prefix, diagnostic.Expected, diagnostic.Found, note)
+	}
+	return fmt.Sprintf("%v: %s%s%s", diagnostic.Position, prefix, diagnostic.Message, note)
+}
+
+// Diagnostics collected while processing the files, so that all the
+// problems are reported at once instead of stopping at the first one
+type List []*Diagnostic
+
+// Appends the diagnostic to the list
+func (list *List) Add(diagnostic *Diagnostic) {
+	*list = append(*list, diagnostic)
+}
+
+// Appends the diagnostics of the error to the list, other errors
+// are added as messages without the position
+func (list *List) AddError(err error) {
+	switch err := err.(type) {
+	case nil:
+	case *Diagnostic:
+		list.Add(err)
+	case List:
+		*list = append(*list, err...)
+	default:
+		list.Add(&Diagnostic{Message: err.Error()})
+	}
+}
+
+// Returns the list as an error or nil if there are no diagnostics
+func (list List) Err() error {
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+// Returns true if the list contains diagnostics which are not warnings
+func (list List) HasErrors() bool {
+	for _, diagnostic := range list {
+		if !diagnostic.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Formats the diagnostics, one per line
+func (list List) Error() string {
+	lines := make([]string, len(list))
+	for i, diagnostic := range list {
+		lines[i] = diagnostic.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Quotes the token for the expected and found descriptions
+func Quote(token string) string {
+	return "'" + token + "'"
+}
+
+// Returns the name of the file read by the reader, e.g. *os.File,
+// or <input> for the readers without a name
+func SourceName(reader io.Reader) string {
+	if named, ok := reader.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return "<input>"
+}
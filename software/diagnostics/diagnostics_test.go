@@ -0,0 +1,72 @@
+package diagnostics
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Checks the format of the errors and the warnings
+func TestDiagnosticError(t *testing.T) {
+	position := Position{FileName: "Main.jack", Line: 3, Column: 7}
+	withNote := Errorf(position, "M", "macro M invokes itself")
+	withNote.Note = "in macro M invoked at Main.asm:5:1"
+	for _, test := range []struct {
+		diagnostic *Diagnostic
+		expected   string
+	}{
+		{Errorf(position, "x", "unknown variable %s", "x"), "Main.jack:3:7: unknown variable x"},
+		{Warningf(position, "x", "local variable %s is never used", "x"), "Main.jack:3:7: warning: local variable x is never used"},
+		{Expected(position, "}", "';'", Quote("}")), "Main.jack:3:7: expected ';', found '}'"},
+		{withNote, "Main.jack:3:7: macro M invokes itself (in macro M invoked at Main.asm:5:1)"},
+		{&Diagnostic{Message: "cannot read the file"}, "cannot read the file"},
+	} {
+		if found := test.diagnostic.Error(); found != test.expected {
+			t.Errorf("expected %s, found %s", test.expected, found)
+		}
+	}
+}
+
+// Checks the errors collected by the list
+func TestList(t *testing.T) {
+	var list List
+	if list.Err() != nil || list.HasErrors() {
+		t.Fatal("expected no errors in the empty list")
+	}
+	position := Position{FileName: "Main.vm", Line: 1, Column: 1}
+	list.AddError(nil)
+	list.Add(Warningf(position, "", "first"))
+	if list.Err() == nil || list.HasErrors() {
+		t.Fatal("expected only a warning")
+	}
+	list.AddError(List{Errorf(position, "", "second"), Errorf(position, "", "third")})
+	list.AddError(errors.New("fourth"))
+	if !list.HasErrors() {
+		t.Fatal("expected errors")
+	}
+	expected := "Main.vm:1:1: warning: first\nMain.vm:1:1: second\nMain.vm:1:1: third\nfourth"
+	if found := list.Err().Error(); found != expected {
+		t.Errorf("expected\n%s\nfound\n%s", expected, found)
+	}
+}
+
+// Checks the names of the files and of the other readers
+func TestSourceName(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "Main.asm")
+	if err := os.WriteFile(fileName, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if found := SourceName(file); found != fileName {
+		t.Errorf("expected %s, found %s", fileName, found)
+	}
+	if found := SourceName(strings.NewReader("")); found != "<input>" {
+		t.Errorf("expected <input>, found %s", found)
+	}
+}
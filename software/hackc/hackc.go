@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"nand2tetris/software/assembler"
+	"nand2tetris/software/diagnostics"
+	"nand2tetris/software/jackc"
+	jackos "nand2tetris/software/os"
+	"nand2tetris/software/vmtranslator"
+)
+
+// Number of the instructions which fit into the ROM
+const ROM_SIZE = 32768
+
+func main() {
+	output := flag.String("o", "", "name of the .hack file, by default named after the first input")
+	keepVM := flag.Bool("keep-vm", false, "write the .vm file of each compiled .jack file next to it")
+	list := flag.Bool("list", false, "write the listing of the ROM addresses, instructions and assembly lines with the symbol table to the .lst file")
+	strip := flag.Bool("strip", false, "leave out the Jack subroutines, including those of the OS, which are never called from Sys.init")
+	keepASM := flag.Bool("keep-asm", false, "write the .asm file next to the .hack file")
+	osDirectory := flag.String("os", "", "directory with the .jack files of the OS used instead of software/os")
+	optimize := flag.Bool("O", false, "optimize the translated code and report the number of saved instructions")
+	shared := flag.Bool("shared", false, "call shared routines for calls, returns and comparisons instead of inlining them")
+	treeShake := flag.Bool("tree-shake", false, "leave out the VM functions which are never called from Sys.init and list the kept and the removed ones")
+	strict := flag.Bool("strict", false, "report the warnings of the Jack type checker as errors")
+	extended := flag.Bool("extended", false, "accept the extensions of the Jack language: for loops, else if, break, continue, +=, -=, ++ and --")
+	extendedISA := flag.Bool("extended-isa", false, "assemble the .asm file with the extended instruction set: the shifts D<<, A>>, M<< etc., the ALU control bits #b110011 and the other functions of the ALU")
+	poolStrings := flag.Bool("pool-strings", false, "create each distinct string constant of a Jack class once and reuse it")
+	precedence := flag.Bool("precedence", false, "evaluate the Jack operators by their precedence: * and / before + and -, before the comparisons, before & and |")
+	jackOptimization := flag.Int("jack-optimization", jackc.NO_OPTIMIZATION, "optimization level of the Jack expressions: 1 folds constants, 2 also replaces multiplications by powers of two")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [options] .jack, .vm or .asm files or directories containing them")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	build := &build{keepVM: *keepVM, keepASM: *keepASM, list: *list, strip: *strip, osDirectory: *osDirectory}
+	build.options = vmtranslator.Options{Optimize: *optimize, SharedRoutines: *shared, TreeShake: *treeShake}
+	build.asmOptions = assembler.Options{Extended: *extendedISA}
+	build.jackOptions = jackc.Options{OptimizationLevel: *jackOptimization, Strict: *strict, Extended: *extended, Precedence: *precedence, PoolStrings: *poolStrings, Warnings: os.Stderr}
+	if *osDirectory != "" {
+		build.jackOptions.OS = os.DirFS(*osDirectory)
+	}
+	if err := build.run(flag.Args(), *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Source file of the program kept in the memory
+type source struct {
+	name string
+	code []byte
+}
+
+// Reader of the source, named for the diagnostics
+type sourceReader struct {
+	*bytes.Reader
+	name string
+}
+
+func (reader sourceReader) Name() string {
+	return reader.name
+}
+
+func (source source) reader() sourceReader {
+	return sourceReader{Reader: bytes.NewReader(source.code), name: source.name}
+}
+
+// Builds the program from the .jack, .vm or .asm files. Every stage runs in
+// the memory, only the .hack file and the requested intermediate files are written.
+type build struct {
+	keepVM      bool
+	keepASM     bool
+	list        bool
+	strip       bool
+	osDirectory string
+	options     vmtranslator.Options
+	jackOptions jackc.Options
+	asmOptions  assembler.Options
+	jack        []source
+	vm          []source
+	asm         []source
+	// Included classes of the OS and the VM code compiled from the .jack files of the program
+	osJack   []source
+	compiled []source
+	vmInputs int
+}
+
+func (build *build) run(inputs []string, output string) error {
+	for _, input := range inputs {
+		if err := build.addInput(input); err != nil {
+			return err
+		}
+	}
+	if output == "" {
+		output = getOutputName(inputs[0])
+	}
+
+	var asm source
+	if len(build.asm) > 0 {
+		if len(build.asm) > 1 || len(build.jack) > 0 || len(build.vm) > 0 {
+			return fmt.Errorf("an .asm file cannot be built together with other files")
+		}
+		asm = build.asm[0]
+	} else {
+		if len(build.jack) == 0 && len(build.vm) == 0 {
+			return fmt.Errorf("no .jack, .vm or .asm files found")
+		}
+		if err := build.compile(); err != nil {
+			return err
+		}
+		if err := build.includeOS(); err != nil {
+			return err
+		}
+		if err := build.stripUnused(); err != nil {
+			return err
+		}
+		if err := build.writeVM(); err != nil {
+			return err
+		}
+		var code bytes.Buffer
+		sources := make([]vmtranslator.Source, len(build.vm))
+		for i, vm := range build.vm {
+			sources[i] = vmtranslator.Source{Name: vm.name, Reader: vm.reader()}
+		}
+		report, err := vmtranslator.TranslateWith(sources, &code, build.options)
+		if err != nil {
+			return err
+		}
+		if build.options.TreeShake {
+			fmt.Println(report.FunctionSummary())
+		}
+		if build.options.Optimize || build.options.SharedRoutines || build.options.TreeShake {
+			fmt.Println(report)
+		}
+		// The labels beyond the ROM would be reported by the assembler as out of range
+		if report.Optimized > ROM_SIZE {
+			return fmt.Errorf("%s: the program has %d instructions, the ROM holds only %d", output, report.Optimized, ROM_SIZE)
+		}
+		asm = source{name: strings.TrimSuffix(output, ".hack") + ".asm", code: code.Bytes()}
+		if build.keepASM {
+			if err := os.WriteFile(asm.name, asm.code, 0666); err != nil {
+				return err
+			}
+		}
+	}
+
+	var hack, listing bytes.Buffer
+	options := build.asmOptions
+	if build.list {
+		options.Listing = &listing
+	}
+	if err := assembler.AssembleWith(asm.reader(), &hack, options); err != nil {
+		return err
+	}
+	if build.list {
+		if err := os.WriteFile(strings.TrimSuffix(output, ".hack")+".lst", listing.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	if instructions := bytes.Count(hack.Bytes(), []byte("\n")); instructions > ROM_SIZE {
+		return fmt.Errorf("%s: the program has %d instructions, the ROM holds only %d", output, instructions, ROM_SIZE)
+	}
+	return os.WriteFile(output, hack.Bytes(), 0666)
+}
+
+// Adds the file or the .jack and .vm files of the directory. The .vm files
+// of the directory compiled from its .jack files are skipped.
+func (build *build) addInput(input string) error {
+	info, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return build.addFile(input)
+	}
+	files, err := filepath.Glob(filepath.Join(input, "*.jack"))
+	if err != nil {
+		return err
+	}
+	vmFiles, err := filepath.Glob(filepath.Join(input, "*.vm"))
+	if err != nil {
+		return err
+	}
+	for _, vmFile := range vmFiles {
+		if _, err := os.Stat(strings.TrimSuffix(vmFile, ".vm") + ".jack"); os.IsNotExist(err) {
+			files = append(files, vmFile)
+		}
+	}
+	for _, file := range files {
+		if err := build.addFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (build *build) addFile(fileName string) error {
+	code, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	switch filepath.Ext(fileName) {
+	case ".jack":
+		build.jack = append(build.jack, source{name: fileName, code: code})
+	case ".vm":
+		build.vm = append(build.vm, source{name: fileName, code: code})
+	case ".asm":
+		build.asm = append(build.asm, source{name: fileName, code: code})
+	default:
+		return fmt.Errorf("%s: expected .jack, .vm or .asm file", fileName)
+	}
+	return nil
+}
+
+// Compiles the .jack files of the program together, so that the calls
+// between the classes are checked. Without .vm files the program may only
+// call its own classes and the OS. The errors of all the files are returned together.
+func (build *build) compile() error {
+	build.vmInputs = len(build.vm)
+	options := build.jackOptions
+	options.CheckClasses = build.vmInputs == 0
+	vm, err := build.compileClasses(build.jack, options)
+	build.compiled = vm
+	return err
+}
+
+// Compiles the program again together with the included OS classes, without
+// the subroutines which are never called. The .vm files of the program may
+// call any function, so nothing is stripped if there are any.
+func (build *build) stripUnused() error {
+	if !build.strip || len(build.jack) == 0 {
+		return nil
+	}
+	if build.vmInputs > 0 {
+		fmt.Fprintln(os.Stderr, "-strip is ignored, the program has .vm files")
+		return nil
+	}
+	// The warnings were reported by the first compilation
+	options := build.jackOptions
+	options.StripUnused = true
+	options.Warnings = nil
+	build.vm = build.vm[:build.vmInputs]
+	vm, err := build.compileClasses(append(append([]source{}, build.jack...), build.osJack...), options)
+	if err != nil {
+		return err
+	}
+	build.compiled = vm[:len(build.jack)]
+	// Every function called by the remaining code has to be kept
+	defined, called := build.scanFunctions()
+	return build.checkFunctions(defined, called)
+}
+
+// Writes the .vm files compiled from the .jack files of the program if requested
+func (build *build) writeVM() error {
+	if !build.keepVM {
+		return nil
+	}
+	for _, vm := range build.compiled {
+		if err := os.WriteFile(vm.name, vm.code, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (build *build) compileClasses(jack []source, options jackc.Options) ([]source, error) {
+	sources := make([]jackc.Source, len(jack))
+	for i, class := range jack {
+		sources[i] = jackc.Source{Name: class.name, Reader: class.reader()}
+	}
+	code, err := jackc.CompileClasses(sources, options)
+	if err != nil {
+		return nil, err
+	}
+	vm := make([]source, len(jack))
+	for i, class := range jack {
+		vm[i] = source{name: strings.TrimSuffix(class.name, ".jack") + ".vm", code: code[i]}
+	}
+	build.vm = append(build.vm, vm...)
+	return vm, nil
+}
+
+// Compiles the OS classes called by the program, and the classes called by
+// them, unless the program defines them itself. Without Sys.init, which is
+// called by the bootstrap code, the Sys class of the OS is included as well.
+// Functions which are defined neither by the program nor by the OS are reported.
+func (build *build) includeOS() error {
+	included := make(map[string]bool)
+	for {
+		defined, called := build.scanFunctions()
+		called["Sys.init"] = true
+		var missing []string
+		for function := range called {
+			if defined[function] {
+				continue
+			}
+			className := function
+			if idx := strings.LastIndex(function, "."); idx != -1 {
+				className = function[:idx]
+			}
+			if !included[className] && !defined[className] {
+				included[className] = true
+				missing = append(missing, className)
+			}
+		}
+		if len(missing) == 0 {
+			return build.checkFunctions(defined, called)
+		}
+		sort.Strings(missing)
+		for _, className := range missing {
+			jack, err := build.readOSClass(className)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			// The OS classes are compiled one by one, so their calls are not checked
+			options := build.jackOptions
+			options.Warnings = nil
+			if _, err := build.compileClasses([]source{jack}, options); err != nil {
+				return err
+			}
+			build.osJack = append(build.osJack, jack)
+		}
+	}
+}
+
+// Returns the functions defined by the VM code together with their classes
+// and the functions called by it. Syntax errors are reported by the translator.
+func (build *build) scanFunctions() (map[string]bool, map[string]bool) {
+	defined := make(map[string]bool)
+	called := make(map[string]bool)
+	for _, vm := range build.vm {
+		parser := vmtranslator.NewParser(vm.reader(), vm.name)
+		for parser.Advance() {
+			switch parser.GetCommandType() {
+			case vmtranslator.FUNCTION:
+				function := parser.GetFirstArgument()
+				defined[function] = true
+				if idx := strings.LastIndex(function, "."); idx != -1 {
+					defined[function[:idx]] = true
+				}
+			case vmtranslator.CALL:
+				called[parser.GetFirstArgument()] = true
+			}
+		}
+	}
+	return defined, called
+}
+
+func (build *build) checkFunctions(defined, called map[string]bool) error {
+	var undefined []string
+	for function := range called {
+		if !defined[function] {
+			undefined = append(undefined, function)
+		}
+	}
+	if len(undefined) == 0 {
+		return nil
+	}
+	sort.Strings(undefined)
+	var errors diagnostics.List
+	for _, function := range undefined {
+		errors.Add(&diagnostics.Diagnostic{Message: "function " + function + " is called but not defined"})
+	}
+	return errors
+}
+
+// Reads the .jack file of the OS class from the -os directory
+// or from the sources of the OS embedded into the program
+func (build *build) readOSClass(className string) (source, error) {
+	fileName := className + ".jack"
+	var code []byte
+	var err error
+	if build.osDirectory != "" {
+		fileName = filepath.Join(build.osDirectory, fileName)
+		code, err = os.ReadFile(fileName)
+	} else {
+		code, err = jackos.Files.ReadFile(fileName)
+		fileName = filepath.Join("software", "os", fileName)
+	}
+	return source{name: fileName, code: code}, err
+}
+
+// The .hack file of a directory is named after the directory and placed
+// inside it, the .hack file of a file replaces its extension
+func getOutputName(input string) string {
+	input = filepath.Clean(input)
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		return filepath.Join(input, filepath.Base(input)+".hack")
+	}
+	return strings.TrimSuffix(input, filepath.Ext(input)) + ".hack"
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nand2tetris/software/jackc"
+	"nand2tetris/software/vmtranslator"
+)
+
+// OS providing only the functions called by the test programs, the OS of
+// software/os fits into the ROM only when optimized or with the shared routines
+var minimalOS = map[string]string{
+	"Sys.jack": `class Sys {
+    function void init() {
+        do Main.main();
+        do Sys.halt();
+        return;
+    }
+    function void halt() {
+        while (true) {
+        }
+        return;
+    }
+}
+`,
+	"Memory.jack": `class Memory {
+    static int free;
+    function int alloc(int size) {
+        var int block;
+        if (free = 0) {
+            let free = 2048;
+        }
+        let block = free;
+        let free = free + size;
+        return block;
+    }
+    function void poke(int address, int value) {
+        var Array memory;
+        let memory = 0;
+        let memory[address] = value;
+        return;
+    }
+}
+`,
+}
+
+// Program with two classes calling the OS, RAM[8000] is 6 + 7 = 13
+var pointSum = map[string]string{
+	"Main.jack": `class Main {
+    function void main() {
+        var Point p;
+        let p = Point.new(6, 7);
+        do Memory.poke(8000, p.sum());
+        return;
+    }
+}
+`,
+	"Point.jack": `class Point {
+    field int x, y;
+    constructor Point new(int ax, int ay) {
+        let x = ax;
+        let y = ay;
+        return this;
+    }
+    method int sum() {
+        return x + y;
+    }
+}
+`,
+}
+
+// Program using the OS functions which the compiler calls by itself: Math.multiply
+// and Math.divide for * and /, String.new and String.appendChar for the string
+// constants and Memory.alloc for the constructors. RAM[8000] is 21 + 'i' = 126.
+var implicitCalls = map[string]string{
+	"Main.jack": `class Main {
+    function void main() {
+        var int x;
+        var String s;
+        var Point p;
+        let x = 7 * 6;
+        let x = x / 2;
+        let s = "hi";
+        let p = Point.new(x);
+        do Memory.poke(8000, p.get() + s.charAt(1));
+        return;
+    }
+}
+`,
+	"Point.jack": `class Point {
+    field int x;
+    constructor Point new(int ax) {
+        let x = ax;
+        return this;
+    }
+    method int get() {
+        return x;
+    }
+    method int unused() {
+        return x * 2;
+    }
+}
+`,
+}
+
+// Program with the expressions which the optimization levels of the Jack
+// compiler fold and reduce, RAM[8000] is 40 + 12 - 5 + 5 - 4 - 28 - 3 = 17
+var foldedExpressions = map[string]string{
+	"Main.jack": `class Main {
+    function void main() {
+        var int x, y;
+        let x = 5;
+        let y = -7;
+        do Memory.poke(8000, (x * 8) + (3 * 4) - (x * 1) + (~(~x)) - (16 / 4) + (y * 4) + (y / 2));
+        return;
+    }
+}
+`,
+}
+
+// Program using the extensions of the Jack language, RAM[8000] is the sum
+// of the even numbers below 7 plus 20 and 1, 0 + 2 + 4 + 6 + 20 + 1 = 33
+var extendedStatements = map[string]string{
+	"Main.jack": `class Main {
+    function void main() {
+        var int i, sum;
+        let sum = 0;
+        for (let i = 0; i < 10; i++) {
+            if (i = 7) {
+                break;
+            } else if ((i & 1) = 1) {
+                continue;
+            }
+            let sum += i;
+        }
+        let i = 20;
+        while (true) {
+            let sum += i;
+            let i -= 19;
+            if (i < 0) {
+                break;
+            }
+        }
+        do Memory.poke(8000, sum);
+        return;
+    }
+}
+`,
+}
+
+// Program whose expression depends on the operator precedence, RAM[8000]
+// is (2 + 3) * 4 - 1 = 19 from left to right and 2 + 3 * 4 - 1 = 13 by precedence
+var mixedOperators = map[string]string{
+	"Main.jack": `class Main {
+    function void main() {
+        var int x;
+        let x = 3;
+        do Memory.poke(8000, 2 + x * 4 - 1);
+        return;
+    }
+}
+`,
+}
+
+// Program comparing two equal string constants, RAM[8000] is -1 if they
+// are pooled in the same object, RAM[8001] is twice the last character, 2 * 108
+var equalStrings = map[string]string{
+	"Main.jack": `class Main {
+    function void main() {
+        var String s, t;
+        let s = "pool";
+        let t = "pool";
+        do Memory.poke(8000, s = t);
+        do Memory.poke(8001, s.charAt(3) + t.charAt(3));
+        return;
+    }
+}
+`,
+}
+
+// Builds the programs with the OS and runs them with the CPU emulator
+func TestBuildAndRun(t *testing.T) {
+	emulator := buildEmulator(t)
+	for _, test := range []struct {
+		name    string
+		build   build
+		classes map[string]string
+		// Expected line of the dump of the emulator
+		expected string
+	}{
+		{"minimal os", build{osDirectory: writeClasses(t, minimalOS)}, pointSum, "RAM[8000]=13"},
+		{"optimized", build{options: vmtranslator.Options{Optimize: true}}, implicitCalls, "RAM[8000]=126"},
+		{"shared", build{options: vmtranslator.Options{SharedRoutines: true}}, implicitCalls, "RAM[8000]=126"},
+		{"strip", build{strip: true}, implicitCalls, "RAM[8000]=126"},
+		{"strip optimized", build{strip: true, options: vmtranslator.Options{Optimize: true}}, implicitCalls, "RAM[8000]=126"},
+		{"not folded", build{options: vmtranslator.Options{Optimize: true}}, foldedExpressions, "RAM[8000]=17"},
+		{"folded", build{options: vmtranslator.Options{Optimize: true}, jackOptions: jackc.Options{OptimizationLevel: jackc.FOLD_CONSTANTS}}, foldedExpressions, "RAM[8000]=17"},
+		{"reduced", build{options: vmtranslator.Options{Optimize: true}, jackOptions: jackc.Options{OptimizationLevel: jackc.REDUCE_MULTIPLICATIONS}}, foldedExpressions, "RAM[8000]=17"},
+		{"extended", build{options: vmtranslator.Options{Optimize: true}, jackOptions: jackc.Options{Extended: true}}, extendedStatements, "RAM[8000]=33"},
+		{"extended optimized", build{options: vmtranslator.Options{Optimize: true, SharedRoutines: true}, jackOptions: jackc.Options{Extended: true, OptimizationLevel: jackc.REDUCE_MULTIPLICATIONS}}, extendedStatements, "RAM[8000]=33"},
+		{"left to right", build{options: vmtranslator.Options{Optimize: true}}, mixedOperators, "RAM[8000]=19"},
+		{"precedence", build{options: vmtranslator.Options{Optimize: true}, jackOptions: jackc.Options{Precedence: true}}, mixedOperators, "RAM[8000]=13"},
+		{"precedence folded", build{options: vmtranslator.Options{Optimize: true}, jackOptions: jackc.Options{Precedence: true, OptimizationLevel: jackc.REDUCE_MULTIPLICATIONS}}, mixedOperators, "RAM[8000]=13"},
+		{"strings", build{options: vmtranslator.Options{Optimize: true}}, equalStrings, "RAM[8000]=0"},
+		{"pooled strings", build{options: vmtranslator.Options{Optimize: true}, jackOptions: jackc.Options{PoolStrings: true}}, equalStrings, "RAM[8000]=-1"},
+		{"pooled characters", build{options: vmtranslator.Options{Optimize: true}, jackOptions: jackc.Options{PoolStrings: true}}, equalStrings, "RAM[8001]=216"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			directory := writeClasses(t, test.classes)
+			build := test.build
+			build.jackOptions.Warnings = io.Discard
+			output := filepath.Join(directory, "Main.hack")
+			if err := build.run([]string{directory}, output); err != nil {
+				t.Fatal(err)
+			}
+			result, err := exec.Command(emulator, "-dump", "8000-8001", output).CombinedOutput()
+			if err != nil {
+				t.Fatalf("%v: %s", err, result)
+			}
+			if !strings.HasPrefix(string(result), "Halted") || !strings.Contains(string(result), test.expected+"\n") {
+				t.Errorf("expected the program to halt with %s, found:\n%s", test.expected, result)
+			}
+		})
+	}
+}
+
+// Checks that the functions defined neither by the program nor by the OS are reported
+func TestUndefinedFunctions(t *testing.T) {
+	directory := writeClasses(t, map[string]string{"Main.vm": `function Main.main 0
+call Main.f 0
+call Output.printInt 1
+pop temp 0
+push constant 0
+return
+`})
+	build := build{osDirectory: writeClasses(t, minimalOS)}
+	err := build.run([]string{directory}, filepath.Join(directory, "Main.hack"))
+	expected := "function Main.f is called but not defined\nfunction Output.printInt is called but not defined"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected\n%s\nfound\n%v", expected, err)
+	}
+}
+
+// Writes the files into the directory Main in a temporary directory
+func writeClasses(t *testing.T, classes map[string]string) string {
+	t.Helper()
+	directory := filepath.Join(t.TempDir(), "Main")
+	if err := os.Mkdir(directory, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, code := range classes {
+		if err := os.WriteFile(filepath.Join(directory, fileName), []byte(code), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+// Builds the CPU emulator from software/cpu-emulator, which is not importable
+func buildEmulator(t *testing.T) string {
+	t.Helper()
+	emulator := filepath.Join(t.TempDir(), "cpu-emulator")
+	if output, err := exec.Command("go", "build", "-o", emulator, "../cpu-emulator").CombinedOutput(); err != nil {
+		t.Fatalf("cannot build the CPU emulator: %v\n%s", err, output)
+	}
+	return emulator
+}
@@ -0,0 +1,121 @@
+package main
+
+type primitiveType int
+
+const (
+	COMPOSITE primitiveType = iota
+	NAND      primitiveType = iota
+	DFF       primitiveType = iota
+	DEVICE    primitiveType = iota
+)
+
+// Chip implemented natively by the simulator. Values of the pins are given
+// in the order of the chip interface, inputs first.
+type Device interface {
+	// Computes the outputs from the combinational inputs and the internal state
+	Evaluate(inputs []int, outputs []int)
+	// Samples the inputs on the rising edge of the clock
+	Tick(inputs []int)
+	// Commits the sampled state on the falling edge of the clock
+	Tock()
+}
+
+// Device which exposes its memory to the test scripts, e.g. RAM16K[0]
+type MemoryDevice interface {
+	Device
+	Memory
+}
+
+// Nand and DFF are the only logic primitives, the remaining built-in chips
+// model the computer's devices which are not described in HDL.
+var primitiveChips = map[string]*Chip{
+	"Nand": {
+		Name:      "Nand",
+		Inputs:    []PinDefinition{{"a", 1}, {"b", 1}},
+		Outputs:   []PinDefinition{{"out", 1}},
+		primitive: NAND,
+	},
+	"DFF": {
+		Name:      "DFF",
+		Inputs:    []PinDefinition{{"in", 1}},
+		Outputs:   []PinDefinition{{"out", 1}},
+		primitive: DFF,
+	},
+}
+
+var deviceChips = map[string]*Chip{
+	"ROM32K": {
+		Name:          "ROM32K",
+		Inputs:        []PinDefinition{{"address", 15}},
+		Outputs:       []PinDefinition{{"out", 16}},
+		primitive:     DEVICE,
+		combinational: []int{0},
+		newDevice:     func() Device { return &memoryDevice{words: make([]int, 0x8000)} },
+	},
+	"Screen": {
+		Name:          "Screen",
+		Inputs:        []PinDefinition{{"in", 16}, {"load", 1}, {"address", 13}},
+		Outputs:       []PinDefinition{{"out", 16}},
+		primitive:     DEVICE,
+		combinational: []int{2},
+		newDevice:     func() Device { return &memoryDevice{words: make([]int, 0x2000), writable: true} },
+	},
+	"Keyboard": {
+		Name:      "Keyboard",
+		Outputs:   []PinDefinition{{"out", 16}},
+		primitive: DEVICE,
+		newDevice: func() Device { return &memoryDevice{words: make([]int, 1)} },
+	},
+}
+
+// Built-in chips of the Java simulator which behave exactly like a chip
+// described in HDL, the Register for the CPU's A and D registers
+var aliasChips = map[string]string{
+	"ARegister": "Register",
+	"DRegister": "Register",
+}
+
+// Memory with an optional clocked write port (in, load, address)
+// and a combinational read port (address) -> out
+type memoryDevice struct {
+	words    []int
+	writable bool
+	pending  bool
+	address  int
+	value    int
+}
+
+func (device *memoryDevice) Evaluate(inputs []int, outputs []int) {
+	address := 0
+	if len(inputs) > 0 {
+		address = inputs[len(inputs)-1]
+	}
+	outputs[0] = device.words[address]
+}
+
+func (device *memoryDevice) Tick(inputs []int) {
+	device.pending = device.writable && inputs[1] != 0
+	if device.pending {
+		device.value = inputs[0]
+		device.address = inputs[2]
+	}
+}
+
+func (device *memoryDevice) Tock() {
+	if device.pending {
+		device.words[device.address] = device.value
+		device.pending = false
+	}
+}
+
+func (device *memoryDevice) Size() int {
+	return len(device.words)
+}
+
+func (device *memoryDevice) Read(address int) int {
+	return device.words[address]
+}
+
+func (device *memoryDevice) Write(address int, value int) {
+	device.words[address] = value & 0xFFFF
+}
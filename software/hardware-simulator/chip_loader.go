@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type signalKind int
+
+const (
+	SIGNAL_FALSE    signalKind = iota
+	SIGNAL_TRUE     signalKind = iota
+	SIGNAL_PIN      signalKind = iota
+	SIGNAL_INTERNAL signalKind = iota
+)
+
+// Connection with all the names resolved to indexes and sub buses
+// resolved to inclusive bit ranges
+type compiledConnection struct {
+	pin        int
+	pinFrom    int
+	pinTo      int
+	kind       signalKind
+	signal     int
+	signalFrom int
+}
+
+type compiledPart struct {
+	chip        *Chip
+	connections []compiledConnection
+}
+
+// Validated chip which can be instantiated by the simulator. Pins are
+// indexed in the order of the interface, inputs first.
+type Chip struct {
+	Name          string
+	Inputs        []PinDefinition
+	Outputs       []PinDefinition
+	internals     []PinDefinition
+	parts         []compiledPart
+	primitive     primitiveType
+	combinational []int
+	newDevice     func() Device
+}
+
+// Returns the index of the pin with the given name or -1
+func (chip *Chip) PinIndex(name string) int {
+	for i, pin := range chip.pins() {
+		if pin.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// True if the pin with the given index is an input pin
+func (chip *Chip) IsInput(index int) bool {
+	return index < len(chip.Inputs)
+}
+
+func (chip *Chip) pins() []PinDefinition {
+	pins := make([]PinDefinition, 0, len(chip.Inputs)+len(chip.Outputs))
+	return append(append(pins, chip.Inputs...), chip.Outputs...)
+}
+
+// Finds chip definitions by name. A part is searched first among the
+// primitives, then in the directory of the chip which uses it, next in the
+// library directories (including their subdirectories) and at last among the
+// built-in devices.
+type ChipLoader struct {
+	library map[string]string
+	chips   map[string]*Chip
+	loading map[string]bool
+}
+
+// Creates new loader which uses all .hdl files found in the given directories
+func NewChipLoader(libraries ...string) (*ChipLoader, error) {
+	library := make(map[string]string)
+	for _, directory := range libraries {
+		err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSuffix(info.Name(), ".hdl")
+			if !info.IsDir() && name != info.Name() {
+				if _, has := library[name]; !has {
+					library[name] = path
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &ChipLoader{library: library, chips: make(map[string]*Chip), loading: make(map[string]bool)}, nil
+}
+
+// Loads the chip from the given .hdl file together with all its parts
+func (loader *ChipLoader) LoadFile(fileName string) (*Chip, error) {
+	definition, err := ParseHDLFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if expected := strings.TrimSuffix(filepath.Base(fileName), ".hdl"); definition.Name != expected {
+		return nil, fmt.Errorf("%s: chip name %s does not match the file name", fileName, definition.Name)
+	}
+	if chip, has := loader.chips[definition.Name]; has {
+		return chip, nil
+	}
+	return loader.compile(definition)
+}
+
+// Returns the chip with the given name used by a chip from the given directory
+func (loader *ChipLoader) Load(name, directory string) (*Chip, error) {
+	if chip, has := primitiveChips[name]; has {
+		return chip, nil
+	}
+	if chip, has := loader.chips[name]; has {
+		return chip, nil
+	}
+	fileName := filepath.Join(directory, name+".hdl")
+	if _, err := os.Stat(fileName); err == nil {
+		return loader.LoadFile(fileName)
+	}
+	if fileName, has := loader.library[name]; has {
+		return loader.LoadFile(fileName)
+	}
+	if chip, has := deviceChips[name]; has {
+		return chip, nil
+	}
+	if alias, has := aliasChips[name]; has {
+		chip, err := loader.Load(alias, directory)
+		if err != nil {
+			return nil, err
+		}
+		aliased := *chip
+		aliased.Name = name
+		loader.chips[name] = &aliased
+		return &aliased, nil
+	}
+	return nil, fmt.Errorf("chip %s not found", name)
+}
+
+func (loader *ChipLoader) compile(definition *ChipDefinition) (*Chip, error) {
+	if loader.loading[definition.Name] {
+		return nil, fmt.Errorf("%s: chip %s uses itself as a part", definition.FileName, definition.Name)
+	}
+	loader.loading[definition.Name] = true
+	defer delete(loader.loading, definition.Name)
+
+	compiler := &chipCompiler{
+		definition: definition,
+		chip:       &Chip{Name: definition.Name, Inputs: definition.Inputs, Outputs: definition.Outputs},
+		internals:  make(map[string]int),
+		driven:     make(map[string][]bool),
+	}
+	if err := compiler.checkInterface(); err != nil {
+		return nil, err
+	}
+	directory := filepath.Dir(definition.FileName)
+	parts := make([]*Chip, len(definition.Parts))
+	for i, part := range definition.Parts {
+		chip, err := loader.Load(part.Chip, directory)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", definition.FileName, part.Line, err)
+		}
+		parts[i] = chip
+	}
+	// Outputs of the parts define the internal pins,
+	// so they are resolved before any of them is used as an input
+	for i, part := range definition.Parts {
+		if err := compiler.defineInternals(part, parts[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i, part := range definition.Parts {
+		compiled, err := compiler.compilePart(part, parts[i])
+		if err != nil {
+			return nil, err
+		}
+		compiler.chip.parts = append(compiler.chip.parts, compiled)
+	}
+	loader.chips[definition.Name] = compiler.chip
+	return compiler.chip, nil
+}
+
+type chipCompiler struct {
+	definition *ChipDefinition
+	chip       *Chip
+	internals  map[string]int
+	driven     map[string][]bool
+}
+
+func (compiler *chipCompiler) checkInterface() error {
+	names := make(map[string]bool)
+	for _, pin := range compiler.chip.pins() {
+		if pin.Name == "true" || pin.Name == "false" {
+			return fmt.Errorf("%s: %s cannot be used as a pin name", compiler.definition.FileName, pin.Name)
+		}
+		if names[pin.Name] {
+			return fmt.Errorf("%s: pin %s defined twice", compiler.definition.FileName, pin.Name)
+		}
+		names[pin.Name] = true
+	}
+	for _, pin := range compiler.chip.Outputs {
+		compiler.driven[pin.Name] = make([]bool, pin.Width)
+	}
+	return nil
+}
+
+func (compiler *chipCompiler) defineInternals(part PartDefinition, chip *Chip) error {
+	for _, connection := range part.Connections {
+		pin := chip.PinIndex(connection.Pin.Name)
+		if pin == -1 || chip.IsInput(pin) {
+			continue
+		}
+		name := connection.Signal.Name
+		if name == "true" || name == "false" || compiler.chip.PinIndex(name) != -1 {
+			continue
+		}
+		from, to, err := compiler.resolveRange(connection.Pin, chip.pins()[pin], connection.Line)
+		if err != nil {
+			return err
+		}
+		if connection.Signal.From != -1 {
+			return compiler.errorf(connection.Line, "sub bus of an internal pin %s cannot be assigned", name)
+		}
+		width := to - from + 1
+		if index, has := compiler.internals[name]; has {
+			if compiler.chip.internals[index].Width != width {
+				return compiler.errorf(connection.Line, "internal pin %s has width %d, found %d", name, compiler.chip.internals[index].Width, width)
+			}
+			continue
+		}
+		compiler.internals[name] = len(compiler.chip.internals)
+		compiler.chip.internals = append(compiler.chip.internals, PinDefinition{name, width})
+		compiler.driven[name] = make([]bool, width)
+	}
+	return nil
+}
+
+func (compiler *chipCompiler) compilePart(part PartDefinition, chip *Chip) (compiledPart, error) {
+	compiled := compiledPart{chip: chip}
+	assigned := make(map[int][]bool)
+	for _, connection := range part.Connections {
+		pin := chip.PinIndex(connection.Pin.Name)
+		if pin == -1 {
+			return compiled, compiler.errorf(connection.Line, "chip %s has no pin %s", chip.Name, connection.Pin.Name)
+		}
+		pinDefinition := chip.pins()[pin]
+		from, to, err := compiler.resolveRange(connection.Pin, pinDefinition, connection.Line)
+		if err != nil {
+			return compiled, err
+		}
+		if assigned[pin] == nil {
+			assigned[pin] = make([]bool, pinDefinition.Width)
+		}
+		for bit := from; bit <= to; bit++ {
+			if assigned[pin][bit] && chip.IsInput(pin) {
+				return compiled, compiler.errorf(connection.Line, "input pin %s[%d] of %s connected twice", pinDefinition.Name, bit, chip.Name)
+			}
+			assigned[pin][bit] = true
+		}
+		result := compiledConnection{pin: pin, pinFrom: from, pinTo: to}
+		if err := compiler.resolveSignal(&result, connection, chip.IsInput(pin)); err != nil {
+			return compiled, err
+		}
+		compiled.connections = append(compiled.connections, result)
+	}
+	return compiled, nil
+}
+
+func (compiler *chipCompiler) resolveSignal(result *compiledConnection, connection Connection, isInput bool) error {
+	name := connection.Signal.Name
+	width := result.pinTo - result.pinFrom + 1
+	if name == "true" || name == "false" {
+		if !isInput {
+			return compiler.errorf(connection.Line, "%s cannot be assigned", name)
+		}
+		if connection.Signal.From != -1 {
+			return compiler.errorf(connection.Line, "%s has no sub buses", name)
+		}
+		result.kind = SIGNAL_FALSE
+		if name == "true" {
+			result.kind = SIGNAL_TRUE
+		}
+		return nil
+	}
+
+	var signal PinDefinition
+	if index := compiler.chip.PinIndex(name); index != -1 {
+		if isInput && !compiler.chip.IsInput(index) {
+			return compiler.errorf(connection.Line, "output pin %s cannot be used as an input of a part", name)
+		}
+		if !isInput && compiler.chip.IsInput(index) {
+			return compiler.errorf(connection.Line, "input pin %s cannot be assigned", name)
+		}
+		result.kind = SIGNAL_PIN
+		result.signal = index
+		signal = compiler.chip.pins()[index]
+	} else if index, has := compiler.internals[name]; has {
+		result.kind = SIGNAL_INTERNAL
+		result.signal = index
+		signal = compiler.chip.internals[index]
+	} else {
+		return compiler.errorf(connection.Line, "internal pin %s has no source", name)
+	}
+
+	from, to, err := compiler.resolveRange(connection.Signal, signal, connection.Line)
+	if err != nil {
+		return err
+	}
+	if to-from+1 != width {
+		return compiler.errorf(connection.Line, "width of %s (%d) does not match width of %s (%d)", name, to-from+1, connection.Pin.Name, width)
+	}
+	result.signalFrom = from
+	if !isInput {
+		driven := compiler.driven[name]
+		for bit := from; bit <= to; bit++ {
+			if driven[bit] {
+				return compiler.errorf(connection.Line, "pin %s[%d] has more than one source", name, bit)
+			}
+			driven[bit] = true
+		}
+	}
+	return nil
+}
+
+func (compiler *chipCompiler) resolveRange(reference PinReference, pin PinDefinition, line int) (int, int, error) {
+	if reference.From == -1 {
+		return 0, pin.Width - 1, nil
+	}
+	if reference.To >= pin.Width {
+		return 0, 0, compiler.errorf(line, "sub bus %s[%d..%d] out of range, width of %s is %d", reference.Name, reference.From, reference.To, pin.Name, pin.Width)
+	}
+	return reference.From, reference.To, nil
+}
+
+func (compiler *chipCompiler) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", compiler.definition.FileName, line, fmt.Sprintf(format, args...))
+}
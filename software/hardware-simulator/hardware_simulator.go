@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/testscript"
+)
+
+// List of pin assignments given as pin=value
+type assignments []string
+
+func (values *assignments) String() string {
+	return strings.Join(*values, ",")
+}
+
+func (values *assignments) Set(text string) error {
+	*values = append(*values, text)
+	return nil
+}
+
+func main() {
+	var inputs assignments
+	libraries := flag.String("lib", "", "comma separated list of directories searched for the parts, e.g. ../../hardware")
+	ticks := flag.Int("ticks", 0, "number of clock cycles simulated after setting the inputs")
+	flag.Var(&inputs, "set", "input value given as pin=value, may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [options] .hdl files, directories containing them or .tst test scripts")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var directories []string
+	if *libraries != "" {
+		directories = strings.Split(*libraries, ",")
+	}
+	loader, err := NewChipLoader(directories...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	files, err := findChips(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(files) != 1 && (len(inputs) > 0 || *ticks > 0) {
+		fmt.Fprintln(os.Stderr, "-set and -ticks require a single chip")
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, file := range files {
+		if strings.HasSuffix(file, ".tst") {
+			result, err := testscript.RunFile(file, &scriptBackend{loader: loader}, os.Stdout)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			} else {
+				fmt.Printf("%s: %s\n", file, result)
+			}
+			continue
+		}
+		simulator, err := simulate(loader, file, inputs, *ticks)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		nands, dffs := simulator.Size()
+		fmt.Printf("%s: %d Nand gates, %d DFFs\n", simulator.Chip.Name, nands, dffs)
+		for _, pin := range simulator.Chip.Outputs {
+			value, _ := simulator.GetPin(pin.Name)
+			fmt.Printf("  %s=%d\n", pin.Name, value)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Loads the chip, sets its inputs and runs the clock
+func simulate(loader *ChipLoader, fileName string, inputs []string, ticks int) (*Simulator, error) {
+	chip, err := loader.LoadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	simulator, err := NewSimulator(chip)
+	if err != nil {
+		return nil, err
+	}
+	for _, input := range inputs {
+		idx := strings.Index(input, "=")
+		if idx == -1 {
+			return nil, fmt.Errorf("expected pin=value, found %q", input)
+		}
+		value, err := strconv.Atoi(input[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", input[idx+1:])
+		}
+		if err := simulator.SetPin(input[:idx], value); err != nil {
+			return nil, err
+		}
+	}
+	simulator.Eval()
+	for i := 0; i < ticks; i++ {
+		simulator.Tick()
+		simulator.Tock()
+	}
+	return simulator, nil
+}
+
+// Returns the .hdl files given directly or found in the given directories
+func findChips(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(path, ".hdl") {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
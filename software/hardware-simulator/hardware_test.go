@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nand2tetris/software/testscript"
+)
+
+// Directory with the chips of the computer, used as the library of the parts
+const HARDWARE_DIRECTORY = "../../hardware"
+
+// Test scripts which wait for the user and cannot run unattended
+var interactiveScripts = map[string]bool{"Memory.tst": true}
+
+// Builds every chip of hardware/ from Nand gates and DFFs
+func TestHardwareChips(t *testing.T) {
+	loader, err := NewChipLoader(HARDWARE_DIRECTORY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := findChips([]string{HARDWARE_DIRECTORY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no chips found in %s", HARDWARE_DIRECTORY)
+	}
+	for _, file := range files {
+		name, _ := filepath.Rel(HARDWARE_DIRECTORY, file)
+		t.Run(name, func(t *testing.T) {
+			if _, err := simulate(loader, file, nil, 0); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Checks the outputs of the combinational chips and of the clocked chips
+// after the given number of cycles
+func TestSimulate(t *testing.T) {
+	loader, err := NewChipLoader(HARDWARE_DIRECTORY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		chip     string
+		inputs   []string
+		ticks    int
+		expected map[string]int
+	}{
+		{"and", "basic-logic-gates/And.hdl", []string{"a=1", "b=1"}, 0, map[string]int{"out": 1}},
+		{"xor", "basic-logic-gates/Xor.hdl", []string{"a=1", "b=1"}, 0, map[string]int{"out": 0}},
+		{"sub-bus", "gates-16-bits/Or8Way.hdl", []string{"in=128"}, 0, map[string]int{"out": 1}},
+		{"mux16", "gates-16-bits/Mux16.hdl", []string{"a=5", "b=9", "sel=1"}, 0, map[string]int{"out": 9}},
+		{"add16", "alu/Add16.hdl", []string{"a=65535", "b=3"}, 0, map[string]int{"out": 2}},
+		{"alu x+y", "alu/ALU.hdl", []string{"x=5", "y=3", "f=1"}, 0, map[string]int{"out": 8, "zr": 0, "ng": 0}},
+		{"alu -1", "alu/ALU.hdl", []string{"zx=1", "nx=1", "zy=1", "f=1"}, 0, map[string]int{"out": 0xFFFF, "zr": 0, "ng": 1}},
+		{"alu 0", "alu/ALU.hdl", []string{"x=5", "zx=1", "zy=1", "f=1"}, 0, map[string]int{"out": 0, "zr": 1, "ng": 0}},
+		{"register", "memory/Register.hdl", []string{"in=7", "load=1"}, 1, map[string]int{"out": 7}},
+		{"pc", "memory/PC.hdl", []string{"inc=1"}, 3, map[string]int{"out": 3}},
+		{"ram8", "memory/RAM8.hdl", []string{"in=9", "load=1", "address=3"}, 1, map[string]int{"out": 9}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			simulator, err := simulate(loader, filepath.Join(HARDWARE_DIRECTORY, test.chip), test.inputs, test.ticks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for pin, value := range test.expected {
+				if found, err := simulator.GetPin(pin); err != nil || found != value {
+					t.Errorf("expected %s=%d, found %d %v", pin, value, found, err)
+				}
+			}
+		})
+	}
+}
+
+// Runs every test script of hardware/ on a copy of its directory, so that the
+// .out files are written to the temporary directory. Any line of the output
+// which differs from the compare file fails the test.
+func TestHardwareScripts(t *testing.T) {
+	loader, err := NewChipLoader(HARDWARE_DIRECTORY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := filepath.Glob(filepath.Join(HARDWARE_DIRECTORY, "*", "*.tst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatalf("no test scripts found in %s", HARDWARE_DIRECTORY)
+	}
+	for _, script := range scripts {
+		if interactiveScripts[filepath.Base(script)] {
+			continue
+		}
+		name, _ := filepath.Rel(HARDWARE_DIRECTORY, script)
+		t.Run(name, func(t *testing.T) {
+			directory := copyDirectory(t, filepath.Dir(script))
+			if _, err := testscript.RunFile(filepath.Join(directory, filepath.Base(script)), &scriptBackend{loader: loader}, io.Discard); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Copies the files of the directory into a temporary directory
+func copyDirectory(t *testing.T, directory string) string {
+	t.Helper()
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temporary := t.TempDir()
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(temporary, entry.Name()), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return temporary
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"unicode"
+)
+
+// Pin of the chip interface, e.g. a[16]
+type PinDefinition struct {
+	Name  string
+	Width int
+}
+
+// Reference to a pin or its sub-bus, e.g. a, a[3] or a[0..7].
+// From and To are both -1 when the whole pin is referenced.
+type PinReference struct {
+	Name string
+	From int
+	To   int
+}
+
+// Single pin=signal assignment of a part
+type Connection struct {
+	Pin    PinReference
+	Signal PinReference
+	Line   int
+}
+
+// Single chip used in PARTS section
+type PartDefinition struct {
+	Chip        string
+	Connections []Connection
+	Line        int
+}
+
+// Chip as described by the HDL file:
+//
+//	CHIP Name {
+//	    IN a, b[16];
+//	    OUT out[16];
+//	    PARTS:
+//	    Part(pin=signal, pin[0..7]=signal[8..15]);
+//	}
+type ChipDefinition struct {
+	Name     string
+	FileName string
+	Inputs   []PinDefinition
+	Outputs  []PinDefinition
+	Parts    []PartDefinition
+}
+
+type hdlTokenType int
+
+const (
+	HDL_IDENTIFIER hdlTokenType = iota
+	HDL_NUMBER     hdlTokenType = iota
+	HDL_SYMBOL     hdlTokenType = iota
+	HDL_EOF        hdlTokenType = iota
+)
+
+type hdlToken struct {
+	tokenType hdlTokenType
+	text      string
+	line      int
+}
+
+// Reads the chip definition from the given .hdl file
+func ParseHDLFile(fileName string) (*ChipDefinition, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseHDL(fileName, file)
+}
+
+// Reads the chip definition, the file name is used only in error messages
+func ParseHDL(fileName string, reader io.Reader) (*ChipDefinition, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokenizeHDL(fileName, string(data))
+	if err != nil {
+		return nil, err
+	}
+	parser := &hdlParser{fileName: fileName, tokens: tokens}
+	return parser.parseChip()
+}
+
+func tokenizeHDL(fileName, text string) ([]hdlToken, error) {
+	var tokens []hdlToken
+	line := 1
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '/' && i+1 < len(text) && text[i+1] == '/':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(text) && text[i+1] == '*':
+			start := line
+			i += 2
+			for ; i+1 < len(text) && !(text[i] == '*' && text[i+1] == '/'); i++ {
+				if text[i] == '\n' {
+					line++
+				}
+			}
+			if i+1 >= len(text) {
+				return nil, fmt.Errorf("%s:%d: unterminated comment", fileName, start)
+			}
+			i += 2
+		case c == '.' && i+1 < len(text) && text[i+1] == '.':
+			tokens = append(tokens, hdlToken{HDL_SYMBOL, "..", line})
+			i += 2
+		case c == '{' || c == '}' || c == '(' || c == ')' || c == '[' || c == ']' ||
+			c == ';' || c == ',' || c == '=' || c == ':':
+			tokens = append(tokens, hdlToken{HDL_SYMBOL, string(c), line})
+			i++
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+				i++
+			}
+			tokens = append(tokens, hdlToken{HDL_NUMBER, text[start:i], line})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(text) && (text[i] == '_' || unicode.IsLetter(rune(text[i])) || unicode.IsDigit(rune(text[i]))) {
+				i++
+			}
+			tokens = append(tokens, hdlToken{HDL_IDENTIFIER, text[start:i], line})
+		default:
+			return nil, fmt.Errorf("%s:%d: unexpected character %q", fileName, line, c)
+		}
+	}
+	return append(tokens, hdlToken{HDL_EOF, "end of file", line}), nil
+}
+
+type hdlParser struct {
+	fileName string
+	tokens   []hdlToken
+	current  int
+}
+
+func (parser *hdlParser) parseChip() (*ChipDefinition, error) {
+	if err := parser.eatKeyword("CHIP"); err != nil {
+		return nil, err
+	}
+	name, err := parser.eatIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	chip := &ChipDefinition{Name: name, FileName: parser.fileName}
+	if err := parser.eatSymbol("{"); err != nil {
+		return nil, err
+	}
+	if parser.isKeyword("IN") {
+		parser.current++
+		if chip.Inputs, err = parser.parsePinList(); err != nil {
+			return nil, err
+		}
+	}
+	if parser.isKeyword("OUT") {
+		parser.current++
+		if chip.Outputs, err = parser.parsePinList(); err != nil {
+			return nil, err
+		}
+	}
+	if parser.isKeyword("BUILTIN") {
+		return nil, parser.errorf("BUILTIN chips are not supported, only Nand and DFF are primitive")
+	}
+	if err := parser.eatKeyword("PARTS"); err != nil {
+		return nil, err
+	}
+	if err := parser.eatSymbol(":"); err != nil {
+		return nil, err
+	}
+	for !parser.isSymbol("}") {
+		part, err := parser.parsePart()
+		if err != nil {
+			return nil, err
+		}
+		chip.Parts = append(chip.Parts, part)
+	}
+	parser.current++
+	if token := parser.peek(); token.tokenType != HDL_EOF {
+		return nil, parser.errorf("unexpected %q after the end of the chip", token.text)
+	}
+	return chip, nil
+}
+
+func (parser *hdlParser) parsePinList() ([]PinDefinition, error) {
+	var pins []PinDefinition
+	for {
+		name, err := parser.eatIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		pin := PinDefinition{Name: name, Width: 1}
+		if parser.isSymbol("[") {
+			parser.current++
+			if pin.Width, err = parser.eatNumber(); err != nil {
+				return nil, err
+			}
+			if pin.Width < 1 || pin.Width > 16 {
+				return nil, parser.errorf("width of pin %s must be between 1 and 16", name)
+			}
+			if err := parser.eatSymbol("]"); err != nil {
+				return nil, err
+			}
+		}
+		pins = append(pins, pin)
+		if parser.isSymbol(";") {
+			parser.current++
+			return pins, nil
+		}
+		if err := parser.eatSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (parser *hdlParser) parsePart() (PartDefinition, error) {
+	line := parser.peek().line
+	name, err := parser.eatIdentifier()
+	if err != nil {
+		return PartDefinition{}, err
+	}
+	part := PartDefinition{Chip: name, Line: line}
+	if err := parser.eatSymbol("("); err != nil {
+		return part, err
+	}
+	for {
+		connection := Connection{Line: parser.peek().line}
+		if connection.Pin, err = parser.parseReference(); err != nil {
+			return part, err
+		}
+		if err := parser.eatSymbol("="); err != nil {
+			return part, err
+		}
+		if connection.Signal, err = parser.parseReference(); err != nil {
+			return part, err
+		}
+		part.Connections = append(part.Connections, connection)
+		if parser.isSymbol(")") {
+			parser.current++
+			break
+		}
+		if err := parser.eatSymbol(","); err != nil {
+			return part, err
+		}
+	}
+	return part, parser.eatSymbol(";")
+}
+
+func (parser *hdlParser) parseReference() (PinReference, error) {
+	name, err := parser.eatIdentifier()
+	if err != nil {
+		return PinReference{}, err
+	}
+	reference := PinReference{Name: name, From: -1, To: -1}
+	if !parser.isSymbol("[") {
+		return reference, nil
+	}
+	parser.current++
+	if reference.From, err = parser.eatNumber(); err != nil {
+		return reference, err
+	}
+	reference.To = reference.From
+	if parser.isSymbol("..") {
+		parser.current++
+		if reference.To, err = parser.eatNumber(); err != nil {
+			return reference, err
+		}
+		if reference.To < reference.From {
+			return reference, parser.errorf("invalid sub bus %s[%d..%d]", name, reference.From, reference.To)
+		}
+	}
+	return reference, parser.eatSymbol("]")
+}
+
+func (parser *hdlParser) peek() hdlToken {
+	return parser.tokens[parser.current]
+}
+
+func (parser *hdlParser) isSymbol(symbol string) bool {
+	token := parser.peek()
+	return token.tokenType == HDL_SYMBOL && token.text == symbol
+}
+
+func (parser *hdlParser) isKeyword(keyword string) bool {
+	token := parser.peek()
+	return token.tokenType == HDL_IDENTIFIER && token.text == keyword
+}
+
+func (parser *hdlParser) eatSymbol(symbol string) error {
+	if !parser.isSymbol(symbol) {
+		return parser.errorf("expected %q, found %q", symbol, parser.peek().text)
+	}
+	parser.current++
+	return nil
+}
+
+func (parser *hdlParser) eatKeyword(keyword string) error {
+	if !parser.isKeyword(keyword) {
+		return parser.errorf("expected %s, found %q", keyword, parser.peek().text)
+	}
+	parser.current++
+	return nil
+}
+
+func (parser *hdlParser) eatIdentifier() (string, error) {
+	token := parser.peek()
+	if token.tokenType != HDL_IDENTIFIER {
+		return "", parser.errorf("expected identifier, found %q", token.text)
+	}
+	parser.current++
+	return token.text, nil
+}
+
+func (parser *hdlParser) eatNumber() (int, error) {
+	token := parser.peek()
+	if token.tokenType != HDL_NUMBER {
+		return 0, parser.errorf("expected number, found %q", token.text)
+	}
+	parser.current++
+	return strconv.Atoi(token.text)
+}
+
+func (parser *hdlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", parser.fileName, parser.peek().line, fmt.Sprintf(format, args...))
+}
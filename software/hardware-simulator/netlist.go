@@ -0,0 +1,254 @@
+package main
+
+const (
+	FALSE_NET = 0
+	TRUE_NET  = 1
+	// Parts nested deeper than this are not kept for inspection
+	MAX_RECORDED_DEPTH = 3
+)
+
+// Part of the simulated chip. Pins and internal pins hold the nets (wires)
+// of each bit; DFFs of the part and all its sub-parts are stored in the
+// simulator in the order of the HDL definitions between firstDFF and lastDFF.
+type Instance struct {
+	Chip      *Chip
+	Parts     []*Instance
+	pins      [][]int32
+	internals [][]int32
+	device    int
+	firstDFF  int
+	lastDFF   int
+}
+
+type deviceInstance struct {
+	chip    *Chip
+	device  Device
+	pins    [][]int32
+	inputs  []int
+	outputs []int
+}
+
+// Flattens the hierarchy of chips into the list of Nand gates, DFFs and
+// devices connected by nets. Nets connected directly together (one output
+// assigned to many pins) are merged using union-find.
+type netlistBuilder struct {
+	parent  []int32
+	nandA   []int32
+	nandB   []int32
+	nandOut []int32
+	dffIn   []int32
+	dffOut  []int32
+	devices []*deviceInstance
+}
+
+func newNetlistBuilder() *netlistBuilder {
+	return &netlistBuilder{parent: []int32{FALSE_NET, TRUE_NET}}
+}
+
+func (builder *netlistBuilder) newNet() int32 {
+	net := int32(len(builder.parent))
+	builder.parent = append(builder.parent, net)
+	return net
+}
+
+func (builder *netlistBuilder) newBus(width int) []int32 {
+	bus := make([]int32, width)
+	for i := range bus {
+		bus[i] = builder.newNet()
+	}
+	return bus
+}
+
+func (builder *netlistBuilder) find(net int32) int32 {
+	for builder.parent[net] != net {
+		builder.parent[net] = builder.parent[builder.parent[net]]
+		net = builder.parent[net]
+	}
+	return net
+}
+
+func (builder *netlistBuilder) union(a, b int32) {
+	a, b = builder.find(a), builder.find(b)
+	if a < b {
+		builder.parent[b] = a
+	} else if b < a {
+		builder.parent[a] = b
+	}
+}
+
+// Instantiates the chip with the given nets assigned to its pins.
+// Returns the instance if it is kept for inspection.
+func (builder *netlistBuilder) instantiate(chip *Chip, pins [][]int32, depth int) *Instance {
+	var instance *Instance
+	if depth <= MAX_RECORDED_DEPTH {
+		instance = &Instance{Chip: chip, pins: pins, device: -1, firstDFF: len(builder.dffIn)}
+	}
+	switch chip.primitive {
+	case NAND:
+		builder.nandA = append(builder.nandA, pins[0][0])
+		builder.nandB = append(builder.nandB, pins[1][0])
+		builder.nandOut = append(builder.nandOut, pins[2][0])
+	case DFF:
+		builder.dffIn = append(builder.dffIn, pins[0][0])
+		builder.dffOut = append(builder.dffOut, pins[1][0])
+	case DEVICE:
+		if instance != nil {
+			instance.device = len(builder.devices)
+		}
+		builder.devices = append(builder.devices, &deviceInstance{
+			chip:    chip,
+			device:  chip.newDevice(),
+			pins:    copyBuses(pins),
+			inputs:  make([]int, len(chip.Inputs)),
+			outputs: make([]int, len(chip.Outputs)),
+		})
+	case COMPOSITE:
+		internals := make([][]int32, len(chip.internals))
+		for i, internal := range chip.internals {
+			internals[i] = builder.newBus(internal.Width)
+		}
+		if instance != nil {
+			instance.internals = internals
+		}
+		for _, part := range chip.parts {
+			var child *Instance
+			if part.chip.primitive == NAND || part.chip.primitive == DFF {
+				builder.instantiatePrimitive(part, pins, internals)
+			} else {
+				child = builder.instantiate(part.chip, builder.connect(part, pins, internals), depth+1)
+			}
+			if instance != nil && child != nil {
+				instance.Parts = append(instance.Parts, child)
+			}
+		}
+	}
+	if instance != nil {
+		instance.lastDFF = len(builder.dffIn)
+	}
+	return instance
+}
+
+// Returns the nets of the part's pins
+func (builder *netlistBuilder) connect(part compiledPart, pins, internals [][]int32) [][]int32 {
+	chip := part.chip
+	partPins := make([][]int32, len(chip.Inputs)+len(chip.Outputs))
+	for i, pin := range chip.Inputs {
+		partPins[i] = make([]int32, pin.Width)
+	}
+	for i, pin := range chip.Outputs {
+		bus := make([]int32, pin.Width)
+		for bit := range bus {
+			bus[bit] = -1
+		}
+		partPins[len(chip.Inputs)+i] = bus
+	}
+	for _, connection := range part.connections {
+		isInput := chip.IsInput(connection.pin)
+		for bit := connection.pinFrom; bit <= connection.pinTo; bit++ {
+			net := resolveNet(connection, bit, pins, internals)
+			target := &partPins[connection.pin][bit]
+			if isInput || *target == -1 {
+				*target = net
+			} else {
+				builder.union(*target, net)
+			}
+		}
+	}
+	for _, bus := range partPins[len(chip.Inputs):] {
+		for bit := range bus {
+			if bus[bit] == -1 {
+				bus[bit] = builder.newNet()
+			}
+		}
+	}
+	return partPins
+}
+
+// Same as connect but without allocating the buses of the single bit pins
+// of Nand and DFF, which make up the vast majority of all parts
+func (builder *netlistBuilder) instantiatePrimitive(part compiledPart, pins, internals [][]int32) {
+	output := len(part.chip.Inputs)
+	nets := [3]int32{FALSE_NET, FALSE_NET, FALSE_NET}
+	nets[output] = -1
+	for _, connection := range part.connections {
+		net := resolveNet(connection, 0, pins, internals)
+		if connection.pin != output || nets[output] == -1 {
+			nets[connection.pin] = net
+		} else {
+			builder.union(nets[output], net)
+		}
+	}
+	if nets[output] == -1 {
+		nets[output] = builder.newNet()
+	}
+	if part.chip.primitive == NAND {
+		builder.nandA = append(builder.nandA, nets[0])
+		builder.nandB = append(builder.nandB, nets[1])
+		builder.nandOut = append(builder.nandOut, nets[2])
+	} else {
+		builder.dffIn = append(builder.dffIn, nets[0])
+		builder.dffOut = append(builder.dffOut, nets[1])
+	}
+}
+
+func resolveNet(connection compiledConnection, bit int, pins, internals [][]int32) int32 {
+	index := connection.signalFrom + bit - connection.pinFrom
+	switch connection.kind {
+	case SIGNAL_TRUE:
+		return TRUE_NET
+	case SIGNAL_PIN:
+		return pins[connection.signal][index]
+	case SIGNAL_INTERNAL:
+		return internals[connection.signal][index]
+	}
+	return FALSE_NET
+}
+
+// Replaces merged nets with consecutive numbers
+func (builder *netlistBuilder) compact() int {
+	dense := make([]int32, len(builder.parent))
+	count := int32(0)
+	for net := range builder.parent {
+		root := builder.find(int32(net))
+		if root == int32(net) {
+			dense[net] = count
+			count++
+		} else {
+			dense[net] = dense[root]
+		}
+	}
+	for _, nets := range [][]int32{builder.nandA, builder.nandB, builder.nandOut, builder.dffIn, builder.dffOut} {
+		for i, net := range nets {
+			nets[i] = dense[net]
+		}
+	}
+	for _, device := range builder.devices {
+		compactBuses(device.pins, dense)
+	}
+	builder.parent = dense
+	return int(count)
+}
+
+func (builder *netlistBuilder) compactInstance(instance *Instance) {
+	compactBuses(instance.pins, builder.parent)
+	compactBuses(instance.internals, builder.parent)
+	for _, part := range instance.Parts {
+		builder.compactInstance(part)
+	}
+}
+
+func compactBuses(buses [][]int32, dense []int32) {
+	for _, bus := range buses {
+		for i, net := range bus {
+			bus[i] = dense[net]
+		}
+	}
+}
+
+func copyBuses(buses [][]int32) [][]int32 {
+	result := make([][]int32, len(buses))
+	for i, bus := range buses {
+		result[i] = append([]int32(nil), bus...)
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/testscript"
+)
+
+// Runs the test scripts on the chips found by the loader. Variables are the
+// pins of the loaded chip, single bits of its pins like in[3] and the memory
+// of its parts like RAM16K[0], ARegister[] or PC[].
+type scriptBackend struct {
+	loader    *ChipLoader
+	simulator *Simulator
+}
+
+func (backend *scriptBackend) Load(path string) error {
+	chip, err := backend.loader.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	simulator, err := NewSimulator(chip)
+	if err != nil {
+		return err
+	}
+	backend.simulator = simulator
+	return nil
+}
+
+func (backend *scriptBackend) Get(name string) (int, error) {
+	if backend.simulator == nil {
+		return 0, fmt.Errorf("no chip loaded")
+	}
+	variable, err := testscript.ParseVariable(name)
+	if err != nil {
+		return 0, err
+	}
+	if !variable.Indexed {
+		return backend.simulator.GetPin(name)
+	}
+	if value, err := backend.simulator.GetPin(variable.Name); err == nil {
+		width, _ := backend.simulator.GetPinWidth(variable.Name)
+		if variable.Index == -1 || variable.Index >= width {
+			return 0, fmt.Errorf("bit %s out of range, width of %s is %d", name, variable.Name, width)
+		}
+		return (value >> uint(variable.Index)) & 1, nil
+	}
+	memory, address, err := backend.partMemory(variable)
+	if err != nil {
+		return 0, err
+	}
+	return memory.Read(address), nil
+}
+
+func (backend *scriptBackend) Set(name string, value int) error {
+	if backend.simulator == nil {
+		return fmt.Errorf("no chip loaded")
+	}
+	variable, err := testscript.ParseVariable(name)
+	if err != nil {
+		return err
+	}
+	if !variable.Indexed {
+		return backend.simulator.SetPin(name, value)
+	}
+	memory, address, err := backend.partMemory(variable)
+	if err != nil {
+		return err
+	}
+	memory.Write(address, value&0xFFFF)
+	return nil
+}
+
+func (backend *scriptBackend) Execute(command string) error {
+	if backend.simulator == nil {
+		return fmt.Errorf("no chip loaded")
+	}
+	switch command {
+	case "eval":
+		backend.simulator.Eval()
+	case "tick":
+		backend.simulator.Tick()
+	case "tock":
+		backend.simulator.Tock()
+	case "ticktock":
+		backend.simulator.Tick()
+		backend.simulator.Tock()
+	default:
+		return fmt.Errorf("%s is not supported by the hardware simulator", command)
+	}
+	return nil
+}
+
+// Supports loading the memory of a part from a file, e.g. ROM32K load Add.hack
+func (backend *scriptBackend) ExecutePart(part string, args []string, directory string) error {
+	if backend.simulator == nil {
+		return fmt.Errorf("no chip loaded")
+	}
+	if len(args) != 2 || args[0] != "load" {
+		return fmt.Errorf("unknown command %s %s", part, strings.Join(args, " "))
+	}
+	memory, err := backend.simulator.PartMemory(part)
+	if err != nil {
+		return err
+	}
+	return loadMemory(memory, filepath.Join(directory, args[1]))
+}
+
+func (backend *scriptBackend) partMemory(variable testscript.Variable) (Memory, int, error) {
+	memory, err := backend.simulator.PartMemory(variable.Name)
+	if err != nil {
+		return nil, 0, err
+	}
+	address := variable.Index
+	if address == -1 {
+		address = 0
+	}
+	if address >= memory.Size() {
+		return nil, 0, fmt.Errorf("address %d out of range of %s", address, variable.Name)
+	}
+	return memory, address, nil
+}
+
+// Fills the memory with the words of the file, one binary number per line,
+// the remaining words are cleared
+func loadMemory(memory Memory, fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	address := 0
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if address == memory.Size() {
+			return fmt.Errorf("%s:%d: file does not fit into the memory of %d words", fileName, line, memory.Size())
+		}
+		value, err := strconv.ParseUint(text, 2, 16)
+		if err != nil {
+			return fmt.Errorf("%s:%d: expected 16-bit binary number, found %q", fileName, line, text)
+		}
+		memory.Write(address, int(value))
+		address++
+	}
+	for ; address < memory.Size(); address++ {
+		memory.Write(address, 0)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,388 @@
+package main
+
+import "fmt"
+
+// Words stored by a part, accessible to the test scripts
+type Memory interface {
+	Size() int
+	Read(address int) int
+	Write(address int, value int)
+}
+
+// Simulates the chip flattened into Nand gates, DFFs and devices.
+// Combinational logic is evaluated in the topological order of the gates,
+// only the gates whose inputs have changed are recomputed. The DFFs sample
+// their inputs on tick and change their outputs on tock.
+type Simulator struct {
+	Chip     *Chip
+	Top      *Instance
+	Time     int
+	values   []bool
+	nandA    []int32
+	nandB    []int32
+	nandOut  []int32
+	dffIn    []int32
+	dffOut   []int32
+	dffState []bool
+	devices  []*deviceInstance
+	// Consumers of the net i are consumers[consumerStart[i]:consumerStart[i+1]],
+	// nodes are the Nand gates followed by the devices
+	consumerStart []int32
+	consumers     []int32
+	level         []int32
+	buckets       [][]int32
+	dirty         []bool
+}
+
+// Flattens the chip and prepares it for the simulation
+func NewSimulator(chip *Chip) (*Simulator, error) {
+	builder := newNetlistBuilder()
+	pins := make([][]int32, 0, len(chip.Inputs)+len(chip.Outputs))
+	for _, pin := range chip.pins() {
+		pins = append(pins, builder.newBus(pin.Width))
+	}
+	top := builder.instantiate(chip, pins, 0)
+	nets := builder.compact()
+	builder.compactInstance(top)
+
+	simulator := &Simulator{
+		Chip:     chip,
+		Top:      top,
+		values:   make([]bool, nets),
+		nandA:    builder.nandA,
+		nandB:    builder.nandB,
+		nandOut:  builder.nandOut,
+		dffIn:    builder.dffIn,
+		dffOut:   builder.dffOut,
+		dffState: make([]bool, len(builder.dffIn)),
+		devices:  builder.devices,
+	}
+	simulator.values[TRUE_NET] = true
+	simulator.findConsumers(nets)
+	if err := simulator.levelize(nets); err != nil {
+		return nil, fmt.Errorf("%s: %v", chip.Name, err)
+	}
+	for node := range simulator.level {
+		simulator.schedule(int32(node))
+	}
+	simulator.Eval()
+	return simulator, nil
+}
+
+// Returns the number of Nand gates and DFFs of the simulated chip
+func (simulator *Simulator) Size() (int, int) {
+	return len(simulator.nandOut), len(simulator.dffOut)
+}
+
+// Sets the value of the input pin
+func (simulator *Simulator) SetPin(name string, value int) error {
+	index := simulator.Chip.PinIndex(name)
+	if index == -1 {
+		return fmt.Errorf("chip %s has no pin %s", simulator.Chip.Name, name)
+	}
+	if !simulator.Chip.IsInput(index) {
+		return fmt.Errorf("%s is not an input pin", name)
+	}
+	simulator.setBus(simulator.Top.pins[index], value)
+	return nil
+}
+
+// Returns the value of the pin or the internal pin of the simulated chip
+func (simulator *Simulator) GetPin(name string) (int, error) {
+	if index := simulator.Chip.PinIndex(name); index != -1 {
+		return simulator.getBus(simulator.Top.pins[index]), nil
+	}
+	for i, internal := range simulator.Chip.internals {
+		if internal.Name == name {
+			return simulator.getBus(simulator.Top.internals[i]), nil
+		}
+	}
+	return 0, fmt.Errorf("chip %s has no pin %s", simulator.Chip.Name, name)
+}
+
+// Returns the width of the pin or the internal pin of the simulated chip
+func (simulator *Simulator) GetPinWidth(name string) (int, error) {
+	for _, pin := range append(simulator.Chip.pins(), simulator.Chip.internals...) {
+		if pin.Name == name {
+			return pin.Width, nil
+		}
+	}
+	return 0, fmt.Errorf("chip %s has no pin %s", simulator.Chip.Name, name)
+}
+
+// Returns the first recorded part with the given name,
+// the parts closer to the simulated chip are found first
+func (simulator *Simulator) FindPart(name string) *Instance {
+	queue := []*Instance{simulator.Top}
+	for len(queue) > 0 {
+		instance := queue[0]
+		queue = queue[1:]
+		if instance.Chip.Name == name {
+			return instance
+		}
+		queue = append(queue, instance.Parts...)
+	}
+	return nil
+}
+
+// Returns the memory of the part: the memory of the device or the DFFs of
+// the part seen as 16-bit words, e.g. RAM16K[5] or the single word PC[]
+func (simulator *Simulator) PartMemory(name string) (Memory, error) {
+	instance := simulator.FindPart(name)
+	if instance == nil {
+		return nil, fmt.Errorf("chip %s has no part %s", simulator.Chip.Name, name)
+	}
+	if instance.device != -1 {
+		if memory, ok := simulator.devices[instance.device].device.(MemoryDevice); ok {
+			return &deviceMemory{memory, simulator, int32(len(simulator.nandOut) + instance.device)}, nil
+		}
+		return nil, fmt.Errorf("part %s has no memory", name)
+	}
+	dffs := instance.lastDFF - instance.firstDFF
+	if dffs == 0 {
+		return nil, fmt.Errorf("part %s has no memory", name)
+	}
+	width := 16
+	if dffs < width {
+		width = dffs
+	}
+	return &dffMemory{simulator, instance.firstDFF, dffs / width, width}, nil
+}
+
+// Recomputes the combinational logic after the inputs have changed
+func (simulator *Simulator) Eval() {
+	for level := range simulator.buckets {
+		for i := 0; i < len(simulator.buckets[level]); i++ {
+			node := simulator.buckets[level][i]
+			simulator.dirty[node] = false
+			if int(node) < len(simulator.nandOut) {
+				value := !(simulator.values[simulator.nandA[node]] && simulator.values[simulator.nandB[node]])
+				simulator.setNet(simulator.nandOut[node], value)
+			} else {
+				simulator.evaluateDevice(simulator.devices[int(node)-len(simulator.nandOut)])
+			}
+		}
+		simulator.buckets[level] = simulator.buckets[level][:0]
+	}
+}
+
+// Rising edge of the clock: the clocked parts sample their inputs
+func (simulator *Simulator) Tick() {
+	simulator.Eval()
+	for i, net := range simulator.dffIn {
+		simulator.dffState[i] = simulator.values[net]
+	}
+	for _, device := range simulator.devices {
+		simulator.readInputs(device)
+		device.device.Tick(device.inputs)
+	}
+}
+
+// Falling edge of the clock: the clocked parts commit their new state
+func (simulator *Simulator) Tock() {
+	for i, net := range simulator.dffOut {
+		simulator.setNet(net, simulator.dffState[i])
+	}
+	for i, device := range simulator.devices {
+		device.device.Tock()
+		simulator.schedule(int32(len(simulator.nandOut) + i))
+	}
+	simulator.Eval()
+	simulator.Time++
+}
+
+func (simulator *Simulator) evaluateDevice(device *deviceInstance) {
+	simulator.readInputs(device)
+	device.device.Evaluate(device.inputs, device.outputs)
+	for i, value := range device.outputs {
+		simulator.setBus(device.pins[len(device.inputs)+i], value)
+	}
+}
+
+func (simulator *Simulator) readInputs(device *deviceInstance) {
+	for i := range device.inputs {
+		device.inputs[i] = simulator.getBus(device.pins[i])
+	}
+}
+
+func (simulator *Simulator) getBus(bus []int32) int {
+	value := 0
+	for bit, net := range bus {
+		if simulator.values[net] {
+			value |= 1 << uint(bit)
+		}
+	}
+	return value
+}
+
+func (simulator *Simulator) setBus(bus []int32, value int) {
+	for bit, net := range bus {
+		simulator.setNet(net, value&(1<<uint(bit)) != 0)
+	}
+}
+
+func (simulator *Simulator) setNet(net int32, value bool) {
+	if simulator.values[net] == value || net == FALSE_NET || net == TRUE_NET {
+		return
+	}
+	simulator.values[net] = value
+	for _, node := range simulator.consumers[simulator.consumerStart[net]:simulator.consumerStart[net+1]] {
+		simulator.schedule(node)
+	}
+}
+
+func (simulator *Simulator) schedule(node int32) {
+	if !simulator.dirty[node] {
+		simulator.dirty[node] = true
+		level := simulator.level[node]
+		simulator.buckets[level] = append(simulator.buckets[level], node)
+	}
+}
+
+// Computes the list of the nodes reading each net
+func (simulator *Simulator) findConsumers(nets int) {
+	simulator.consumerStart = make([]int32, nets+1)
+	simulator.forEachInput(func(node int32, net int32) {
+		simulator.consumerStart[net+1]++
+	})
+	for net := 0; net < nets; net++ {
+		simulator.consumerStart[net+1] += simulator.consumerStart[net]
+	}
+	simulator.consumers = make([]int32, simulator.consumerStart[nets])
+	next := append([]int32(nil), simulator.consumerStart[:nets]...)
+	simulator.forEachInput(func(node int32, net int32) {
+		simulator.consumers[next[net]] = node
+		next[net]++
+	})
+}
+
+// Calls the function for each combinational input of each node
+func (simulator *Simulator) forEachInput(function func(node int32, net int32)) {
+	for node := range simulator.nandOut {
+		function(int32(node), simulator.nandA[node])
+		function(int32(node), simulator.nandB[node])
+	}
+	for i, device := range simulator.devices {
+		node := int32(len(simulator.nandOut) + i)
+		for _, pin := range device.chip.combinational {
+			for _, net := range device.pins[pin] {
+				function(node, net)
+			}
+		}
+	}
+}
+
+// Assigns each node a level greater than the levels of the nodes driving its
+// inputs. Fails if the combinational logic contains a loop.
+func (simulator *Simulator) levelize(nets int) error {
+	nodes := len(simulator.nandOut) + len(simulator.devices)
+	driver := make([]int32, nets)
+	for net := range driver {
+		driver[net] = -1
+	}
+	for node, net := range simulator.nandOut {
+		driver[net] = int32(node)
+	}
+	for i, device := range simulator.devices {
+		for _, bus := range device.pins[len(device.chip.Inputs):] {
+			for _, net := range bus {
+				driver[net] = int32(len(simulator.nandOut) + i)
+			}
+		}
+	}
+
+	waiting := make([]int32, nodes)
+	simulator.forEachInput(func(node int32, net int32) {
+		if driver[net] != -1 {
+			waiting[node]++
+		}
+	})
+	simulator.level = make([]int32, nodes)
+	simulator.dirty = make([]bool, nodes)
+	var ready []int32
+	for node := range waiting {
+		if waiting[node] == 0 {
+			ready = append(ready, int32(node))
+		}
+	}
+	maxLevel := int32(0)
+	for processed := 0; processed < len(ready); processed++ {
+		node := ready[processed]
+		if simulator.level[node] > maxLevel {
+			maxLevel = simulator.level[node]
+		}
+		simulator.forEachOutput(node, func(net int32) {
+			for _, consumer := range simulator.consumers[simulator.consumerStart[net]:simulator.consumerStart[net+1]] {
+				if simulator.level[consumer] <= simulator.level[node] {
+					simulator.level[consumer] = simulator.level[node] + 1
+				}
+				waiting[consumer]--
+				if waiting[consumer] == 0 {
+					ready = append(ready, consumer)
+				}
+			}
+		})
+	}
+	if len(ready) < nodes {
+		return fmt.Errorf("the parts are connected in a combinational loop")
+	}
+	simulator.buckets = make([][]int32, maxLevel+1)
+	return nil
+}
+
+func (simulator *Simulator) forEachOutput(node int32, function func(net int32)) {
+	if int(node) < len(simulator.nandOut) {
+		function(simulator.nandOut[node])
+		return
+	}
+	device := simulator.devices[int(node)-len(simulator.nandOut)]
+	for _, bus := range device.pins[len(device.chip.Inputs):] {
+		for _, net := range bus {
+			function(net)
+		}
+	}
+}
+
+// Memory of a device which updates its outputs after a write
+type deviceMemory struct {
+	MemoryDevice
+	simulator *Simulator
+	node      int32
+}
+
+func (memory *deviceMemory) Write(address int, value int) {
+	memory.MemoryDevice.Write(address, value)
+	memory.simulator.schedule(memory.node)
+}
+
+// DFFs of a part grouped into words, the least significant bit first.
+// Reads return the state sampled on tick, just like the built-in registers
+// of the Java simulator which show the new value before tock.
+type dffMemory struct {
+	simulator *Simulator
+	first     int
+	words     int
+	width     int
+}
+
+func (memory *dffMemory) Size() int {
+	return memory.words
+}
+
+func (memory *dffMemory) Read(address int) int {
+	value := 0
+	for bit := 0; bit < memory.width; bit++ {
+		if memory.simulator.dffState[memory.first+address*memory.width+bit] {
+			value |= 1 << uint(bit)
+		}
+	}
+	return value
+}
+
+func (memory *dffMemory) Write(address int, value int) {
+	for bit := 0; bit < memory.width; bit++ {
+		dff := memory.first + address*memory.width + bit
+		memory.simulator.dffState[dff] = value&(1<<uint(bit)) != 0
+		memory.simulator.setNet(memory.simulator.dffOut[dff], memory.simulator.dffState[dff])
+	}
+}
@@ -0,0 +1,228 @@
+package jackc
+
+import (
+	"fmt"
+	"strconv"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Resolves the names of the syntax trees before the code generation. Every
+// variable has to be declared once in its scope and the calls of the
+// subroutines of the known classes need the declared number of arguments.
+type Analyzer struct {
+	diagnostics diagnostics.List
+	classes     map[string]*Class
+	symbolTable *SymbolTable
+	class       *Class
+	// Number of the loops around the analyzed statement
+	loops int
+	// Set if the compiled classes define Main.main
+	program bool
+	// Reports the calls of the classes which are not known, see SetCheckClasses
+	checkClasses bool
+}
+
+// Creates the analyzer of the classes compiled together. The library
+// classes, e.g. of the OS, are known only for the calls of their subroutines
+// and the compiled classes take precedence over them. Calls of the classes
+// which are not known are not checked, they may be compiled separately,
+// unless SetCheckClasses is set.
+func NewAnalyzer(classes, library []*Class) *Analyzer {
+	known := make(map[string]*Class)
+	for _, class := range library {
+		known[class.Name] = class
+	}
+	compiled := make(map[string]bool)
+	program := false
+	for _, class := range classes {
+		if !compiled[class.Name] {
+			compiled[class.Name] = true
+			known[class.Name] = class
+		}
+		if class.Name == "Main" && class.findSubroutine("main") != nil {
+			program = true
+		}
+	}
+	return &Analyzer{classes: known, program: program}
+}
+
+// Reports the calls of the classes which are neither compiled nor in the
+// library as undeclared if the compiled classes are a program, defining
+// Main.main. A library, like the OS, calls the classes of the programs using it.
+func (analyzer *Analyzer) SetCheckClasses(checkClasses bool) {
+	analyzer.checkClasses = checkClasses
+}
+
+// Resolves the names of the class and returns the errors sorted by their position
+func (analyzer *Analyzer) AnalyzeClass(class *Class) error {
+	analyzer.diagnostics = nil
+	analyzer.class = class
+	analyzer.symbolTable = NewSymbolTable()
+	if analyzer.classes[class.Name] != class {
+		analyzer.Errorf(class.Position, class.Name, "duplicate class %s, also declared in %s", class.Name, analyzer.classes[class.Name].Position.FileName)
+	}
+	for _, declaration := range class.Variables {
+		analyzer.define(declaration)
+	}
+	declared := make(map[string]bool)
+	for _, subroutine := range class.Subroutines {
+		if declared[subroutine.Name] {
+			analyzer.Errorf(subroutine.Position, subroutine.Name, "duplicate subroutine %s", subroutine.Name)
+		}
+		declared[subroutine.Name] = true
+		analyzer.analyzeSubroutine(subroutine)
+	}
+	sortDiagnostics(analyzer.diagnostics)
+	return analyzer.diagnostics.Err()
+}
+
+// Records the diagnostic of the token at the position
+func (analyzer *Analyzer) Errorf(position diagnostics.Position, token string, format string, args ...interface{}) {
+	analyzer.diagnostics.Add(diagnostics.Errorf(position, token, format, args...))
+}
+
+// Adds the variables of the declaration to the symbol table,
+// the names already declared in the same scope are reported
+func (analyzer *Analyzer) define(declaration *Declaration) {
+	for _, name := range declaration.Names {
+		if analyzer.symbolTable.HasVariableInScope(name.Text, declaration.Kind) {
+			analyzer.Errorf(name.Position, name.Text, "duplicate declaration of %s", name.Text)
+			continue
+		}
+		analyzer.symbolTable.Define(name.Text, declaration.Type, declaration.Kind)
+	}
+}
+
+func (analyzer *Analyzer) analyzeSubroutine(subroutine *Subroutine) {
+	analyzer.symbolTable.StartSubroutine()
+	if subroutine.Kind == METHOD {
+		analyzer.symbolTable.Define("this", analyzer.class.Name, ARG)
+	}
+	for _, declaration := range subroutine.Parameters {
+		analyzer.define(declaration)
+	}
+	for _, declaration := range subroutine.Locals {
+		analyzer.define(declaration)
+	}
+	analyzer.analyzeStatements(subroutine.Statements)
+}
+
+func (analyzer *Analyzer) analyzeStatements(statements []Statement) {
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *LetStatement:
+			analyzer.analyzeExpression(statement.Target)
+			analyzer.analyzeExpression(statement.Value)
+		case *IfStatement:
+			analyzer.analyzeExpression(statement.Condition)
+			analyzer.analyzeStatements(statement.Then)
+			analyzer.analyzeStatements(statement.Else)
+		case *WhileStatement:
+			analyzer.analyzeExpression(statement.Condition)
+			analyzer.analyzeLoop(statement.Body)
+		case *ForStatement:
+			analyzer.analyzeStatements(statement.Init)
+			if statement.Condition != nil {
+				analyzer.analyzeExpression(statement.Condition)
+			}
+			analyzer.analyzeStatements(statement.Step)
+			analyzer.analyzeLoop(statement.Body)
+		case *BreakStatement:
+			analyzer.checkLoop(statement.Position, "break")
+		case *ContinueStatement:
+			analyzer.checkLoop(statement.Position, "continue")
+		case *DoStatement:
+			analyzer.analyzeExpression(statement.Call)
+		case *ReturnStatement:
+			if statement.Value != nil {
+				analyzer.analyzeExpression(statement.Value)
+			}
+		}
+	}
+}
+
+func (analyzer *Analyzer) analyzeLoop(body []Statement) {
+	analyzer.loops++
+	analyzer.analyzeStatements(body)
+	analyzer.loops--
+}
+
+// Reports break and continue outside of the loops
+func (analyzer *Analyzer) checkLoop(position diagnostics.Position, keyword string) {
+	if analyzer.loops == 0 {
+		analyzer.Errorf(position, keyword, "%s outside of a loop", keyword)
+	}
+}
+
+func (analyzer *Analyzer) analyzeExpression(node *Expression) {
+	for _, operand := range node.Operands {
+		analyzer.analyzeExpression(operand)
+	}
+	switch node.Kind {
+	case VARIABLE_EXPRESSION, ARRAY_EXPRESSION:
+		if !analyzer.symbolTable.HasVariable(node.Name) {
+			analyzer.Errorf(node.Position, node.Name, "undeclared variable %s", node.Name)
+			return
+		}
+		node.segment, node.index = analyzer.symbolTable.GetVariableInfo(node.Name)
+		node.variableType = analyzer.symbolTable.GetVariableType(node.Name)
+	case CALL_EXPRESSION:
+		analyzer.analyzeCall(node)
+	}
+}
+
+// Resolves the called function and the object of the method. Subroutines
+// without the qualifier are methods of this, the qualifier is a variable
+// holding the object or the name of the class.
+func (analyzer *Analyzer) analyzeCall(call *Expression) {
+	className := analyzer.class.Name
+	if call.Qualifier == "" {
+		call.receiver = &Expression{Kind: KEYWORD_EXPRESSION, Position: call.Position, Keyword: THIS}
+	} else if analyzer.symbolTable.HasVariable(call.Qualifier) {
+		call.receiver = &Expression{Kind: VARIABLE_EXPRESSION, Position: call.Position, Name: call.Qualifier}
+		analyzer.analyzeExpression(call.receiver)
+		className = analyzer.symbolTable.GetVariableType(call.Qualifier)
+		if isPrimitive(className) {
+			analyzer.Errorf(call.Position, call.Qualifier, "method %s called on %s of type %s", call.Name, call.Qualifier, className)
+			return
+		}
+	} else {
+		className = call.Qualifier
+	}
+	call.function = className + "." + call.Name
+
+	class, known := analyzer.classes[className]
+	if !known {
+		if analyzer.checkClasses && analyzer.program {
+			analyzer.Errorf(call.Position, call.Qualifier, "undeclared class %s", className)
+		}
+		return
+	}
+	subroutine := class.findSubroutine(call.Name)
+	call.subroutine = subroutine
+	if subroutine == nil {
+		analyzer.Errorf(call.Position, call.function, "undeclared subroutine %s", call.function)
+	} else if len(call.Operands) != countNames(subroutine.Parameters) {
+		expected := fmt.Sprintf("%s in the call of %s", countArguments(countNames(subroutine.Parameters)), call.function)
+		analyzer.diagnostics.Add(diagnostics.Expected(call.Position, call.function, expected, strconv.Itoa(len(call.Operands))))
+	}
+}
+
+// Returns the subroutine of the class with the name, nil if there is none
+func (class *Class) findSubroutine(name string) *Subroutine {
+	for _, subroutine := range class.Subroutines {
+		if subroutine.Name == name {
+			return subroutine
+		}
+	}
+	return nil
+}
+
+// Describes the number of the arguments, e.g. 1 argument
+func countArguments(count int) string {
+	if count == 1 {
+		return "1 argument"
+	}
+	return strconv.Itoa(count) + " arguments"
+}
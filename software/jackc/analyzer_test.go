@@ -0,0 +1,161 @@
+package jackc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Compiles the class Main and returns the errors or, if there are none,
+// the warnings, one per line
+func compileMain(t *testing.T, code string, options Options) string {
+	t.Helper()
+	var warnings bytes.Buffer
+	options.Warnings = &warnings
+	if _, err := CompileClasses([]Source{{Name: "Main.jack", Reader: strings.NewReader(code)}}, options); err != nil {
+		return err.Error()
+	}
+	return strings.TrimSuffix(warnings.String(), "\n")
+}
+
+// Checks the diagnostics of the class Main, which is compiled with the options
+func checkDiagnostics(t *testing.T, tests []diagnosticsTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found := compileMain(t, test.code, test.options)
+			if expected := strings.Join(test.expected, "\n"); found != expected {
+				t.Errorf("expected\n%s\nfound\n%s", expected, found)
+			}
+		})
+	}
+}
+
+// Class Main compiled with the options and its expected diagnostics
+type diagnosticsTest struct {
+	name    string
+	code    string
+	options Options
+	// Lines of the errors or the warnings
+	expected []string
+}
+
+// Checks the names resolved by the semantic pass and the arguments of the calls
+func TestAnalyzer(t *testing.T) {
+	checkDiagnostics(t, []diagnosticsTest{
+		{"undeclared variable", `class Main {
+    function void main() {
+        let x = 1;
+        return;
+    }
+}`, Options{}, []string{"Main.jack:3:13: undeclared variable x"}},
+		{"undeclared subroutine", `class Main {
+    function void main() {
+        do Main.g();
+        return;
+    }
+}`, Options{}, []string{"Main.jack:3:12: undeclared subroutine Main.g"}},
+		{"arguments", `class Main {
+    function void main() {
+        do Main.f(1);
+        return;
+    }
+    function void f(int a, int b) {
+        return;
+    }
+}`, Options{}, []string{"Main.jack:3:12: expected 2 arguments in the call of Main.f, found 1"}},
+		{"duplicate declarations", `class Main {
+    field int x;
+    static int x;
+    function void main() {
+        var int y, y;
+        return;
+    }
+    function void main() {
+        return;
+    }
+}`, Options{}, []string{
+			"Main.jack:3:16: duplicate declaration of x",
+			"Main.jack:5:20: duplicate declaration of y",
+			"Main.jack:8:5: duplicate subroutine main",
+		}},
+		{"break", `class Main {
+    function void main() {
+        break;
+        return;
+    }
+}`, Options{Extended: true}, []string{"Main.jack:3:9: break outside of a loop"}},
+		{"undeclared class", `class Main {
+    function void main() {
+        var Foo f;
+        do Outptu.printInt(1);
+        let f = Foo.new();
+        do f.bar();
+        return;
+    }
+}`, Options{CheckClasses: true}, []string{
+			"Main.jack:4:12: undeclared class Outptu",
+			"Main.jack:5:17: undeclared class Foo",
+			"Main.jack:6:12: undeclared class Foo",
+		}},
+		{"class compiled separately", `class Main {
+    function void main() {
+        do Other.f();
+        return;
+    }
+}`, Options{}, nil},
+		{"library calling a program", `class Lib {
+    function void f() {
+        do Main.main();
+        return;
+    }
+}`, Options{CheckClasses: true}, nil},
+		{"method of a primitive type", `class Main {
+    function void main() {
+        var int x;
+        var char c;
+        let x = 1;
+        let c = 65;
+        do x.foo();
+        do Output.printInt(c.bar(1));
+        return;
+    }
+}`, Options{}, []string{
+			"Main.jack:7:12: method foo called on x of type int",
+			"Main.jack:8:28: method bar called on c of type char",
+		}},
+	})
+}
+
+// Checks the calls resolved across the classes and the duplicate classes
+func TestAnalyzerClasses(t *testing.T) {
+	sources := []Source{
+		{Name: "Main.jack", Reader: strings.NewReader(`class Main {
+    function void main() {
+        var Point p;
+        let p = Point.new(1);
+        do p.move();
+        do Point.new(1, 2);
+        return;
+    }
+}`)},
+		{Name: "Point.jack", Reader: strings.NewReader(`class Point {
+    field int x;
+    constructor Point new(int ax) {
+        let x = ax;
+        do Output.printInt(x);
+        return this;
+    }
+}`)},
+		{Name: "Other.jack", Reader: strings.NewReader("class Point { }")},
+	}
+	_, err := CompileClasses(sources, Options{})
+	expected := strings.Join([]string{
+		"Main.jack:5:12: undeclared subroutine Point.move",
+		"Main.jack:6:12: expected 1 argument in the call of Point.new, found 2",
+		"Other.jack:1:1: duplicate class Point, also declared in Point.jack",
+	}, "\n")
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected\n%s\nfound\n%v", expected, err)
+	}
+}
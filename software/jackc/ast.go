@@ -0,0 +1,121 @@
+package jackc
+
+import "nand2tetris/software/diagnostics"
+
+// Syntax tree of the Jack class built by the Parser. The Analyzer resolves
+// the names in the tree and the CompilationEngine writes its VM code.
+type Class struct {
+	Position    diagnostics.Position
+	Name        string
+	Variables   []*Declaration
+	Subroutines []*Subroutine
+}
+
+// Declaration of the variables of the same kind and type, e.g. var int i, j;
+// Kind is STATIC, FIELD, ARG or VAR, every parameter is declared separately.
+type Declaration struct {
+	Position diagnostics.Position
+	Kind     Keyword
+	Type     string
+	Names    []Name
+}
+
+// Identifier together with its position
+type Name struct {
+	Position diagnostics.Position
+	Text     string
+}
+
+// Kind is CONSTRUCTOR, METHOD or FUNCTION,
+// the return type is void for the subroutines returning nothing
+type Subroutine struct {
+	Position   diagnostics.Position
+	Kind       Keyword
+	ReturnType string
+	Name       string
+	Parameters []*Declaration
+	Locals     []*Declaration
+	Statements []Statement
+	// Position of the closing curly bracket
+	End diagnostics.Position
+}
+
+// LetStatement, IfStatement, WhileStatement, DoStatement or ReturnStatement,
+// the extended Jack adds ForStatement, BreakStatement and ContinueStatement
+type Statement interface {
+	GetPosition() diagnostics.Position
+}
+
+// Assignment of the value to the target, a VARIABLE_EXPRESSION
+// or an ARRAY_EXPRESSION. The compound assignments of the extended Jack,
+// e.g. i += 2 or i++, assign the binary expression, e.g. i + 2.
+type LetStatement struct {
+	Position diagnostics.Position
+	Target   *Expression
+	Value    *Expression
+}
+
+// The statements of Else are compiled only if HasElse is true
+type IfStatement struct {
+	Position  diagnostics.Position
+	Condition *Expression
+	Then      []Statement
+	HasElse   bool
+	Else      []Statement
+}
+
+type WhileStatement struct {
+	Position  diagnostics.Position
+	Condition *Expression
+	Body      []Statement
+}
+
+// Loop of the extended Jack, for (Init; Condition; Step) { Body }. Init and
+// Step hold at most one LetStatement, the condition is nil if it is omitted.
+type ForStatement struct {
+	Position  diagnostics.Position
+	Init      []Statement
+	Condition *Expression
+	Step      []Statement
+	Body      []Statement
+}
+
+// Leaves the innermost loop
+type BreakStatement struct {
+	Position diagnostics.Position
+}
+
+// Continues with the next iteration of the innermost loop
+type ContinueStatement struct {
+	Position diagnostics.Position
+}
+
+// The call is a CALL_EXPRESSION, its value is discarded
+type DoStatement struct {
+	Position diagnostics.Position
+	Call     *Expression
+}
+
+// The value is nil in the subroutines returning nothing
+type ReturnStatement struct {
+	Position diagnostics.Position
+	Value    *Expression
+}
+
+func (statement *LetStatement) GetPosition() diagnostics.Position      { return statement.Position }
+func (statement *IfStatement) GetPosition() diagnostics.Position       { return statement.Position }
+func (statement *WhileStatement) GetPosition() diagnostics.Position    { return statement.Position }
+func (statement *DoStatement) GetPosition() diagnostics.Position       { return statement.Position }
+func (statement *ReturnStatement) GetPosition() diagnostics.Position   { return statement.Position }
+func (statement *ForStatement) GetPosition() diagnostics.Position      { return statement.Position }
+func (statement *BreakStatement) GetPosition() diagnostics.Position    { return statement.Position }
+func (statement *ContinueStatement) GetPosition() diagnostics.Position { return statement.Position }
+
+// Returns the number of the variables declared by the declarations
+func countNames(declarations []*Declaration) int {
+	count := 0
+	for _, declaration := range declarations {
+		count += len(declaration.Names)
+	}
+	return count
+}
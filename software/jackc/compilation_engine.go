@@ -0,0 +1,211 @@
+package jackc
+
+import (
+	"io"
+	"strconv"
+)
+
+// Writes the VM code of the syntax tree resolved by the Analyzer
+type CompilationEngine struct {
+	vmWriter     *VMWriter
+	className    string
+	counterWhile int
+	counterIf    int
+	counterFor   int
+	// Labels of the loops around the compiled statement, innermost last
+	loops []loopLabels
+	// Level of the expression optimizations, see optimize
+	optimizationLevel int
+	// Pooled string constants of the class and their indexes, see poolStrings
+	stringPooling bool
+	strings       []string
+	stringIndexes map[string]int
+}
+
+// Targets of continue and break in the loop
+type loopLabels struct {
+	continueLabel string
+	breakLabel    string
+}
+
+// Creates new CompilationEngine writing the VM code to the writer
+func NewCompilationEngine(writer io.Writer) *CompilationEngine {
+	return &CompilationEngine{vmWriter: NewVMWriter(writer)}
+}
+
+// Writes the VM code of the class, which has to be resolved
+// by the Analyzer without errors
+func (compilationEngine *CompilationEngine) CompileClass(class *Class) {
+	compilationEngine.className = class.Name + "."
+	compilationEngine.strings = nil
+	compilationEngine.stringIndexes = make(map[string]int)
+	fieldCount, staticCount := 0, 0
+	for _, declaration := range class.Variables {
+		if declaration.Kind == FIELD {
+			fieldCount += len(declaration.Names)
+		} else {
+			staticCount += len(declaration.Names)
+		}
+	}
+	for _, subroutine := range class.Subroutines {
+		compilationEngine.compileSubroutine(subroutine, fieldCount)
+	}
+	compilationEngine.writeStringFunctions(staticCount)
+}
+
+func (compilationEngine *CompilationEngine) compileSubroutine(subroutine *Subroutine, fieldCount int) {
+	compilationEngine.counterIf = 0
+	compilationEngine.counterWhile = 0
+	compilationEngine.counterFor = 0
+	compilationEngine.vmWriter.WriteFunction(compilationEngine.className+subroutine.Name, countNames(subroutine.Locals))
+	if subroutine.Kind == CONSTRUCTOR {
+		compilationEngine.vmWriter.WritePush(CONST, fieldCount)
+		compilationEngine.vmWriter.WriteCall("Memory.alloc", 1)
+		compilationEngine.vmWriter.WritePop(POINTER, 0)
+	} else if subroutine.Kind == METHOD {
+		compilationEngine.vmWriter.WritePush(ARG, 0)
+		compilationEngine.vmWriter.WritePop(POINTER, 0)
+	}
+	compilationEngine.compileStatements(subroutine.Statements)
+}
+
+func (compilationEngine *CompilationEngine) compileStatements(statements []Statement) {
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *LetStatement:
+			compilationEngine.compileLet(statement)
+		case *IfStatement:
+			compilationEngine.compileIf(statement)
+		case *WhileStatement:
+			compilationEngine.compileWhile(statement)
+		case *ForStatement:
+			compilationEngine.compileFor(statement)
+		case *BreakStatement:
+			loop := compilationEngine.loops[len(compilationEngine.loops)-1]
+			compilationEngine.vmWriter.WriteGoto(loop.breakLabel)
+		case *ContinueStatement:
+			loop := compilationEngine.loops[len(compilationEngine.loops)-1]
+			compilationEngine.vmWriter.WriteGoto(loop.continueLabel)
+		case *DoStatement:
+			compilationEngine.compileExpression(statement.Call)
+			compilationEngine.vmWriter.WritePop(TEMP, 0)
+		case *ReturnStatement:
+			if statement.Value != nil {
+				compilationEngine.compileExpression(statement.Value)
+			} else {
+				compilationEngine.vmWriter.WritePush(CONST, 0)
+			}
+			compilationEngine.vmWriter.WriteReturn()
+		}
+	}
+}
+
+func (compilationEngine *CompilationEngine) compileLet(statement *LetStatement) {
+	target := statement.Target
+	if target.Kind == ARRAY_EXPRESSION {
+		compilationEngine.compileExpression(target.Operands[0])
+		compilationEngine.vmWriter.WritePush(target.segment, target.index)
+		compilationEngine.vmWriter.WriteArithmetic(PLUS)
+		compilationEngine.compileExpression(statement.Value)
+		compilationEngine.vmWriter.WritePop(TEMP, 0)
+		compilationEngine.vmWriter.WritePop(POINTER, 1)
+		compilationEngine.vmWriter.WritePush(TEMP, 0)
+		compilationEngine.vmWriter.WritePop(THAT, 0)
+	} else {
+		compilationEngine.compileExpression(statement.Value)
+		compilationEngine.vmWriter.WritePop(target.segment, target.index)
+	}
+}
+
+func (compilationEngine *CompilationEngine) compileIf(statement *IfStatement) {
+	compilationEngine.counterIf++
+	label := compilationEngine.getLabelIfTrue()
+	labelFalse := compilationEngine.getLabelIfFalse()
+	labelEnd := compilationEngine.getLabelIfEnd()
+	compilationEngine.compileExpression(statement.Condition)
+	compilationEngine.vmWriter.WriteIf(label)
+	compilationEngine.vmWriter.WriteGoto(labelFalse)
+	compilationEngine.vmWriter.WriteLabel(label)
+	compilationEngine.compileStatements(statement.Then)
+	if statement.HasElse {
+		compilationEngine.vmWriter.WriteGoto(labelEnd)
+		compilationEngine.vmWriter.WriteLabel(labelFalse)
+		compilationEngine.compileStatements(statement.Else)
+		compilationEngine.vmWriter.WriteLabel(labelEnd)
+	} else {
+		compilationEngine.vmWriter.WriteLabel(labelFalse)
+	}
+}
+
+func (compilationEngine *CompilationEngine) compileWhile(statement *WhileStatement) {
+	compilationEngine.counterWhile++
+	label := compilationEngine.getLabelWhile()
+	labelEnd := compilationEngine.getLabelWhileEnd()
+	compilationEngine.vmWriter.WriteLabel(label)
+	compilationEngine.compileExpression(statement.Condition)
+	compilationEngine.vmWriter.WriteArithmetic(NOT)
+	compilationEngine.vmWriter.WriteIf(labelEnd)
+	compilationEngine.compileLoop(statement.Body, label, labelEnd)
+	compilationEngine.vmWriter.WriteGoto(label)
+	compilationEngine.vmWriter.WriteLabel(labelEnd)
+}
+
+// Writes the for loop like the while loop, continue jumps to the step
+func (compilationEngine *CompilationEngine) compileFor(statement *ForStatement) {
+	compilationEngine.counterFor++
+	label := compilationEngine.getLabelFor("EXP")
+	labelStep := compilationEngine.getLabelFor("STEP")
+	labelEnd := compilationEngine.getLabelFor("END")
+	compilationEngine.compileStatements(statement.Init)
+	compilationEngine.vmWriter.WriteLabel(label)
+	if statement.Condition != nil {
+		compilationEngine.compileExpression(statement.Condition)
+		compilationEngine.vmWriter.WriteArithmetic(NOT)
+		compilationEngine.vmWriter.WriteIf(labelEnd)
+	}
+	compilationEngine.compileLoop(statement.Body, labelStep, labelEnd)
+	compilationEngine.vmWriter.WriteLabel(labelStep)
+	compilationEngine.compileStatements(statement.Step)
+	compilationEngine.vmWriter.WriteGoto(label)
+	compilationEngine.vmWriter.WriteLabel(labelEnd)
+}
+
+// Writes the body of the loop, break and continue in it jump to the labels
+func (compilationEngine *CompilationEngine) compileLoop(body []Statement, continueLabel, breakLabel string) {
+	compilationEngine.loops = append(compilationEngine.loops, loopLabels{continueLabel, breakLabel})
+	compilationEngine.compileStatements(body)
+	compilationEngine.loops = compilationEngine.loops[:len(compilationEngine.loops)-1]
+}
+
+// Optimizes the expression and writes its code
+func (compilationEngine *CompilationEngine) compileExpression(node *Expression) {
+	compilationEngine.vmWriter.writeExpression(compilationEngine.poolStrings(compilationEngine.optimize(node)))
+}
+
+func (compilationEngine *CompilationEngine) getLabelIfTrue() string {
+	return compilationEngine.getLabelIf("TRUE")
+}
+
+func (compilationEngine *CompilationEngine) getLabelIfFalse() string {
+	return compilationEngine.getLabelIf("FALSE")
+}
+
+func (compilationEngine *CompilationEngine) getLabelIfEnd() string {
+	return compilationEngine.getLabelIf("END")
+}
+
+func (compilationEngine *CompilationEngine) getLabelIf(suffix string) string {
+	return "IF_" + suffix + strconv.Itoa(compilationEngine.counterIf-1)
+}
+
+func (compilationEngine *CompilationEngine) getLabelWhile() string {
+	return "WHILE_EXP" + strconv.Itoa(compilationEngine.counterWhile-1)
+}
+
+func (compilationEngine *CompilationEngine) getLabelWhileEnd() string {
+	return "WHILE_END" + strconv.Itoa(compilationEngine.counterWhile-1)
+}
+
+func (compilationEngine *CompilationEngine) getLabelFor(suffix string) string {
+	return "FOR_" + suffix + strconv.Itoa(compilationEngine.counterFor-1)
+}
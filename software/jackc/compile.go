@@ -0,0 +1,178 @@
+package jackc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+
+	"nand2tetris/software/diagnostics"
+	jackos "nand2tetris/software/os"
+)
+
+// Input of the compiler, the name is used by the diagnostics
+type Source struct {
+	Name   string
+	Reader io.Reader
+}
+
+// Settings of the compilation
+type Options struct {
+	// NO_OPTIMIZATION keeps the code of the reference compiler,
+	// see the optimization levels in optimizer.go
+	OptimizationLevel int
+	// The .jack files of the OS classes, whose subroutines may be called
+	// by the compiled classes, the OS in software/os if nil
+	OS fs.FS
+	// Reports the warnings of the type checker as errors
+	Strict bool
+	// Accepts the extensions of the Jack language, see Parser.SetExtended
+	Extended bool
+	// Keeps each distinct string constant of a class in a static variable,
+	// see CompilationEngine.SetStringPooling
+	PoolStrings bool
+	// Reports the calls of the classes which are neither compiled nor in the OS
+	// if the compiled classes define Main.main, see Analyzer.SetCheckClasses
+	CheckClasses bool
+	// Evaluates the binary operators by their precedence, see Parser.SetPrecedence.
+	// Otherwise the expressions which it would change are reported as warnings.
+	Precedence bool
+	// Leaves out the subroutines which are not called, directly or indirectly,
+	// by Main.main or Sys.init if the compiled classes define Main.main
+	StripUnused bool
+	// Receives the warnings, one per line, when there are no errors.
+	// The warnings are discarded if nil.
+	Warnings io.Writer
+}
+
+// Classes of the OS in software/os, parsed once
+var embeddedOS struct {
+	once    sync.Once
+	classes []*Class
+	err     error
+}
+
+// Compiles the Jack class read from the reader into the VM code written to
+// the writer. All the errors found in the class are returned together,
+// nothing is written if there are any.
+func Compile(reader io.Reader, writer io.Writer) error {
+	return CompileWith(reader, writer, Options{})
+}
+
+// Compiles the Jack class like Compile with the given options
+func CompileWith(reader io.Reader, writer io.Writer, options Options) error {
+	code, err := CompileClasses([]Source{{Name: diagnostics.SourceName(reader), Reader: reader}}, options)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(code[0])
+	return err
+}
+
+// Compiles the classes together, so that the calls between them are checked,
+// and returns the VM code of each source. The names are resolved only in the
+// classes without syntax errors and the types are checked and the code is
+// linted only in the classes with resolved names. The subroutines which are
+// never called are reported if the classes are a program, defining Main.main.
+// All the errors found in the classes are returned together with
+// the warnings, no code is returned if there are any errors.
+func CompileClasses(sources []Source, options Options) ([][]byte, error) {
+	var errors diagnostics.List
+	classes := make([]*Class, len(sources))
+	var parsed []*Class
+	for i, source := range sources {
+		parser := NewParser(source.Reader, source.Name)
+		parser.SetExtended(options.Extended)
+		parser.SetPrecedence(options.Precedence)
+		class, err := parser.ParseClass()
+		if err == nil {
+			parsed = append(parsed, class)
+		}
+		classes[i] = class
+		errors.AddError(err)
+	}
+	library, err := loadLibrary(options.OS)
+	if err != nil {
+		return nil, err
+	}
+	analyzer := NewAnalyzer(classes, library)
+	analyzer.SetCheckClasses(options.CheckClasses)
+	typeChecker := NewTypeChecker(options.Strict)
+	linter := NewLinter()
+	for _, class := range parsed {
+		if err := analyzer.AnalyzeClass(class); err != nil {
+			errors.AddError(err)
+			continue
+		}
+		warnings := append(typeChecker.CheckClass(class), linter.LintClass(class)...)
+		sortDiagnostics(warnings)
+		errors.AddError(warnings.Err())
+	}
+	if errors.HasErrors() {
+		return nil, errors
+	}
+	// The calls are checked only in a whole program, a library is called by other classes
+	reachable := linter.ReachableFunctions()
+	if reachable != nil {
+		errors = append(errors, linter.LintProgram()...)
+	}
+	if len(errors) > 0 && options.Warnings != nil {
+		if _, err := fmt.Fprintln(options.Warnings, errors.Error()); err != nil {
+			return nil, err
+		}
+	}
+
+	code := make([][]byte, len(classes))
+	for i, class := range classes {
+		if options.StripUnused && reachable != nil {
+			stripUnused(class, reachable)
+		}
+		var buffer bytes.Buffer
+		compilationEngine := NewCompilationEngine(&buffer)
+		compilationEngine.SetOptimizationLevel(options.OptimizationLevel)
+		compilationEngine.SetStringPooling(options.PoolStrings)
+		compilationEngine.CompileClass(class)
+		code[i] = buffer.Bytes()
+	}
+	return code, nil
+}
+
+// Removes the subroutines of the class which are not reachable
+func stripUnused(class *Class, reachable map[string]bool) {
+	var subroutines []*Subroutine
+	for _, subroutine := range class.Subroutines {
+		if reachable[class.Name+"."+subroutine.Name] {
+			subroutines = append(subroutines, subroutine)
+		}
+	}
+	class.Subroutines = subroutines
+}
+
+// Parses the .jack files of the OS, only the declarations of the subroutines
+// are used, the syntax errors are reported when the classes are compiled
+func loadLibrary(fileSystem fs.FS) ([]*Class, error) {
+	if fileSystem == nil {
+		embeddedOS.once.Do(func() {
+			embeddedOS.classes, embeddedOS.err = parseLibrary(jackos.Files)
+		})
+		return embeddedOS.classes, embeddedOS.err
+	}
+	return parseLibrary(fileSystem)
+}
+
+func parseLibrary(fileSystem fs.FS) ([]*Class, error) {
+	fileNames, err := fs.Glob(fileSystem, "*.jack")
+	if err != nil {
+		return nil, err
+	}
+	classes := make([]*Class, len(fileNames))
+	for i, fileName := range fileNames {
+		code, err := fs.ReadFile(fileSystem, fileName)
+		if err != nil {
+			return nil, err
+		}
+		classes[i], _ = NewParser(bytes.NewReader(code), fileName).ParseClass()
+	}
+	return classes, nil
+}
@@ -0,0 +1,157 @@
+package jackc
+
+import "nand2tetris/software/diagnostics"
+
+type ExpressionKind int
+
+const (
+	// Integer constant, the optimizer folds the other expressions into it as well
+	CONSTANT_EXPRESSION ExpressionKind = iota
+	// true, false, null or this
+	KEYWORD_EXPRESSION  ExpressionKind = iota
+	STRING_EXPRESSION   ExpressionKind = iota
+	VARIABLE_EXPRESSION ExpressionKind = iota
+	// Entry of the array variable, the operand is the index
+	ARRAY_EXPRESSION ExpressionKind = iota
+	// Call of the subroutine of the class or variable given by the qualifier,
+	// or of the method of this without the qualifier. The operands are the arguments.
+	CALL_EXPRESSION  ExpressionKind = iota
+	UNARY_EXPRESSION ExpressionKind = iota
+	// Operator applied to the left and the right operand
+	BINARY_EXPRESSION ExpressionKind = iota
+	// Expression in parentheses
+	PARENTHESES_EXPRESSION ExpressionKind = iota
+	// The operand multiplied by two value times, written by the optimizer
+	DOUBLE_EXPRESSION ExpressionKind = iota
+)
+
+// Node of the syntax tree of the expression. The compilation engine writes
+// the code of the whole expression after the optimizer has rewritten it.
+type Expression struct {
+	Kind      ExpressionKind
+	Position  diagnostics.Position
+	Value     int
+	Keyword   Keyword
+	Text      string
+	Qualifier string
+	Name      string
+	Operator  Symbol
+	Operands  []*Expression
+	// Variable of VARIABLE_EXPRESSION and ARRAY_EXPRESSION, resolved by the Analyzer
+	segment      Keyword
+	index        int
+	variableType string
+	// Called VM function, its declaration if the class is known and
+	// the object of the called method, resolved by the Analyzer
+	function   string
+	subroutine *Subroutine
+	receiver   *Expression
+}
+
+// Precedence of the binary operators, Jack evaluates them from left to right
+// unless the parser is in the precedence mode, see Parser.SetPrecedence
+var precedences = map[Symbol]int{
+	OR:       1,
+	AND:      2,
+	LESS:     3,
+	GREATER:  3,
+	EQUAL:    3,
+	PLUS:     4,
+	MINUS:    4,
+	MULTIPLY: 5,
+	DIVIDE:   5,
+}
+
+func newConstant(value int) *Expression {
+	return &Expression{Kind: CONSTANT_EXPRESSION, Value: value}
+}
+
+func newUnary(operator Symbol, operand *Expression) *Expression {
+	return &Expression{Kind: UNARY_EXPRESSION, Operator: operator, Operands: []*Expression{operand}}
+}
+
+func newBinary(operator Symbol, left, right *Expression) *Expression {
+	return &Expression{Kind: BINARY_EXPRESSION, Operator: operator, Operands: []*Expression{left, right}}
+}
+
+// Returns true if the expression is the constant with the value
+func (node *Expression) isConstant(value int) bool {
+	return node.Kind == CONSTANT_EXPRESSION && node.Value == value
+}
+
+// Returns true if evaluating the expression has no side effects,
+// i.e. it neither calls a subroutine nor allocates a string
+func (node *Expression) isPure() bool {
+	if node.Kind == CALL_EXPRESSION || node.Kind == STRING_EXPRESSION {
+		return false
+	}
+	for _, operand := range node.Operands {
+		if !operand.isPure() {
+			return false
+		}
+	}
+	return true
+}
+
+// Writes the code pushing the value of the expression
+func (vmWriter *VMWriter) writeExpression(node *Expression) {
+	switch node.Kind {
+	case CONSTANT_EXPRESSION:
+		// Negative values are written like true, as not 0
+		if node.Value < 0 {
+			vmWriter.WritePush(CONST, ^node.Value)
+			vmWriter.WriteArithmetic(NOT)
+		} else {
+			vmWriter.WritePush(CONST, node.Value)
+		}
+	case KEYWORD_EXPRESSION:
+		if node.Keyword == THIS {
+			vmWriter.WritePush(POINTER, 0)
+		} else {
+			vmWriter.WritePush(CONST, 0)
+			if node.Keyword == TRUE {
+				vmWriter.WriteArithmetic(NOT)
+			}
+		}
+	case STRING_EXPRESSION:
+		vmWriter.WritePush(CONST, len(node.Text))
+		vmWriter.WriteCall("String.new", 1)
+		for i := 0; i < len(node.Text); i++ {
+			vmWriter.WritePush(CONST, int(node.Text[i]))
+			vmWriter.WriteCall("String.appendChar", 2)
+		}
+	case VARIABLE_EXPRESSION:
+		vmWriter.WritePush(node.segment, node.index)
+	case ARRAY_EXPRESSION:
+		vmWriter.writeExpression(node.Operands[0])
+		vmWriter.WritePush(node.segment, node.index)
+		vmWriter.WriteArithmetic(PLUS)
+		vmWriter.WritePop(POINTER, 1)
+		vmWriter.WritePush(THAT, 0)
+	case CALL_EXPRESSION:
+		count := len(node.Operands)
+		if node.receiver != nil {
+			vmWriter.writeExpression(node.receiver)
+			count++
+		}
+		for _, operand := range node.Operands {
+			vmWriter.writeExpression(operand)
+		}
+		vmWriter.WriteCall(node.function, count)
+	case PARENTHESES_EXPRESSION:
+		vmWriter.writeExpression(node.Operands[0])
+	case UNARY_EXPRESSION, BINARY_EXPRESSION:
+		for _, operand := range node.Operands {
+			vmWriter.writeExpression(operand)
+		}
+		vmWriter.WriteArithmetic(node.Operator)
+	case DOUBLE_EXPRESSION:
+		vmWriter.writeExpression(node.Operands[0])
+		for i := 0; i < node.Value; i++ {
+			vmWriter.WritePop(TEMP, 1)
+			vmWriter.WritePush(TEMP, 1)
+			vmWriter.WritePush(TEMP, 1)
+			vmWriter.WriteArithmetic(PLUS)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package jackc
+
+import (
+	"fmt"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Reports the code which is valid Jack but likely does not do what it was
+// meant to do: operators mixed without parentheses, unused variables and
+// unreachable statements. The classes have to be resolved by the Analyzer.
+// All the findings are warnings.
+type Linter struct {
+	diagnostics diagnostics.List
+	// Called functions by the calling function, recorded over all the
+	// linted classes for LintProgram, including the OS functions called
+	// by the code of the operators, the strings and the constructors
+	calls map[string][]string
+	// Linted subroutines by their function, in the linted order
+	subroutines map[string]*Subroutine
+	functions   []string
+	function    string
+	// Statics and fields of the class which are read
+	classReads map[variable]bool
+	// Locals of the subroutine which are read and assigned, by their index
+	localReads  map[int]bool
+	localWrites map[int]bool
+}
+
+// Variable of the class resolved by the Analyzer
+type variable struct {
+	segment Keyword
+	index   int
+}
+
+// Functions called by the bootstrap code and by Sys.init
+var entryFunctions = []string{"Sys.init", "Main.main"}
+
+// OS functions called by the code of the operators, see VMWriter.WriteArithmetic
+var operatorFunctions = map[Symbol]string{MULTIPLY: "Math.multiply", DIVIDE: "Math.divide"}
+
+// Creates the linter
+func NewLinter() *Linter {
+	return &Linter{calls: make(map[string][]string), subroutines: make(map[string]*Subroutine)}
+}
+
+// Lints the class and returns the warnings sorted by their position
+func (linter *Linter) LintClass(class *Class) diagnostics.List {
+	linter.diagnostics = nil
+	linter.classReads = make(map[variable]bool)
+	for _, subroutine := range class.Subroutines {
+		linter.function = class.Name + "." + subroutine.Name
+		linter.subroutines[linter.function] = subroutine
+		linter.functions = append(linter.functions, linter.function)
+		linter.calls[linter.function] = nil
+		linter.localReads = make(map[int]bool)
+		linter.localWrites = make(map[int]bool)
+		if subroutine.Kind == CONSTRUCTOR {
+			linter.call("Memory.alloc")
+		}
+		linter.lintStatements(subroutine.Statements)
+		linter.lintLocals(subroutine)
+	}
+	linter.lintClassVariables(class)
+	sortDiagnostics(linter.diagnostics)
+	return linter.diagnostics
+}
+
+// Reports the subroutines of the linted classes which are not called by
+// the other subroutines of the linted classes, except the entry functions,
+// in the order of the linted classes
+func (linter *Linter) LintProgram() diagnostics.List {
+	called := make(map[string]bool)
+	for caller, callees := range linter.calls {
+		for _, callee := range callees {
+			if callee != caller {
+				called[callee] = true
+			}
+		}
+	}
+	for _, function := range entryFunctions {
+		called[function] = true
+	}
+	var warnings diagnostics.List
+	for _, function := range linter.functions {
+		if subroutine := linter.subroutines[function]; !called[function] {
+			warnings.Add(diagnostics.Warningf(subroutine.Position, subroutine.Name, "%s %s is never called", getKeywordText(subroutine.Kind), function))
+		}
+	}
+	return warnings
+}
+
+// Returns the functions of the linted classes which are called, directly or
+// indirectly, by the entry functions. Returns nil if the linted classes
+// do not define Main.main, they are a library then, like the OS.
+func (linter *Linter) ReachableFunctions() map[string]bool {
+	if linter.subroutines["Main.main"] == nil {
+		return nil
+	}
+	reachable := make(map[string]bool)
+	var visit func(function string)
+	visit = func(function string) {
+		if reachable[function] {
+			return
+		}
+		reachable[function] = true
+		for _, callee := range linter.calls[function] {
+			visit(callee)
+		}
+	}
+	for _, function := range entryFunctions {
+		if linter.subroutines[function] != nil {
+			visit(function)
+		}
+	}
+	return reachable
+}
+
+// Reports the statics and the fields which are never read
+func (linter *Linter) lintClassVariables(class *Class) {
+	counters := make(map[Keyword]int)
+	for _, declaration := range class.Variables {
+		for _, name := range declaration.Names {
+			index := counters[declaration.Kind]
+			counters[declaration.Kind]++
+			if !linter.classReads[variable{declaration.Kind, index}] {
+				kind := "field"
+				if declaration.Kind == STATIC {
+					kind = "static variable"
+				}
+				linter.diagnostics.Add(diagnostics.Warningf(name.Position, name.Text, "%s %s is never read", kind, name.Text))
+			}
+		}
+	}
+}
+
+// Reports the local variables which are never used or only assigned
+func (linter *Linter) lintLocals(subroutine *Subroutine) {
+	index := 0
+	for _, declaration := range subroutine.Locals {
+		for _, name := range declaration.Names {
+			if !linter.localReads[index] {
+				message := fmt.Sprintf("local variable %s is never used", name.Text)
+				if linter.localWrites[index] {
+					message = fmt.Sprintf("local variable %s is assigned but never read", name.Text)
+				}
+				linter.diagnostics.Add(diagnostics.Warningf(name.Position, name.Text, "%s", message))
+			}
+			index++
+		}
+	}
+}
+
+// Lints the statements, the first statement following a return, break,
+// continue or an if whose both branches end with them is reported
+func (linter *Linter) lintStatements(statements []Statement) {
+	reported := false
+	for i, statement := range statements {
+		if i > 0 && leaves(statements[i-1]) && !reported {
+			linter.diagnostics.Add(diagnostics.Warningf(statement.GetPosition(), "", "unreachable statement"))
+			reported = true
+		}
+		switch statement := statement.(type) {
+		case *LetStatement:
+			if statement.Target.Kind == VARIABLE_EXPRESSION {
+				linter.write(statement.Target)
+			} else {
+				linter.lintExpression(statement.Target)
+			}
+			linter.lintExpression(statement.Value)
+		case *IfStatement:
+			linter.lintExpression(statement.Condition)
+			linter.lintStatements(statement.Then)
+			linter.lintStatements(statement.Else)
+		case *WhileStatement:
+			linter.lintExpression(statement.Condition)
+			linter.lintStatements(statement.Body)
+		case *ForStatement:
+			linter.lintStatements(statement.Init)
+			if statement.Condition != nil {
+				linter.lintExpression(statement.Condition)
+			}
+			linter.lintStatements(statement.Step)
+			linter.lintStatements(statement.Body)
+		case *DoStatement:
+			linter.lintExpression(statement.Call)
+		case *ReturnStatement:
+			if statement.Value != nil {
+				linter.lintExpression(statement.Value)
+			}
+		}
+	}
+}
+
+// Returns true if the statements following the statement are never executed
+func leaves(statement Statement) bool {
+	switch statement := statement.(type) {
+	case *ReturnStatement, *BreakStatement, *ContinueStatement:
+		return true
+	case *IfStatement:
+		return statement.HasElse && len(statement.Then) > 0 && len(statement.Else) > 0 &&
+			leaves(statement.Then[len(statement.Then)-1]) && leaves(statement.Else[len(statement.Else)-1])
+	}
+	return false
+}
+
+func (linter *Linter) lintExpression(node *Expression) {
+	switch node.Kind {
+	case BINARY_EXPRESSION:
+		linter.lintOperations(node)
+		return
+	case VARIABLE_EXPRESSION, ARRAY_EXPRESSION:
+		linter.read(node)
+	case STRING_EXPRESSION:
+		linter.call("String.new")
+		linter.call("String.appendChar")
+	case CALL_EXPRESSION:
+		linter.call(node.function)
+		if node.receiver != nil {
+			linter.lintExpression(node.receiver)
+		}
+	}
+	for _, operand := range node.Operands {
+		linter.lintExpression(operand)
+	}
+}
+
+// Records the call of the function by the linted subroutine
+func (linter *Linter) call(function string) {
+	linter.calls[linter.function] = append(linter.calls[linter.function], function)
+}
+
+// Records that the variable is read
+func (linter *Linter) read(node *Expression) {
+	if node.segment == VAR {
+		linter.localReads[node.index] = true
+	} else {
+		linter.classReads[variable{node.segment, node.index}] = true
+	}
+}
+
+// Records that the variable is assigned
+func (linter *Linter) write(node *Expression) {
+	if node.segment == VAR {
+		linter.localWrites[node.index] = true
+	}
+}
+
+// Warns about the operations evaluated from left to right which the
+// conventional precedence would evaluate differently, e.g. a + b * c,
+// which happens when an operator follows one of a lower precedence
+func (linter *Linter) lintOperations(node *Expression) {
+	position := node.Position
+	// The left operands hold the preceding operations, the last one is the top
+	var operators []Symbol
+	for ; node.Kind == BINARY_EXPRESSION; node = node.Operands[0] {
+		linter.lintExpression(node.Operands[1])
+		if function, ok := operatorFunctions[node.Operator]; ok {
+			linter.call(function)
+		}
+		operators = append(operators, node.Operator)
+	}
+	linter.lintExpression(node)
+	for i := len(operators) - 1; i > 0; i-- {
+		previous, next := operators[i], operators[i-1]
+		if precedences[next] > precedences[previous] {
+			linter.diagnostics.Add(diagnostics.Warningf(position, "", "%s is evaluated before %s, Jack has no operator precedence, use parentheses", getSymbolText(previous), getSymbolText(next)))
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package jackc
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	jackos "nand2tetris/software/os"
+)
+
+// Checks the warnings about the unused variables, the unreachable
+// statements and the subroutines which are never called
+func TestLinter(t *testing.T) {
+	checkDiagnostics(t, []diagnosticsTest{
+		{"variables", `class Main {
+    static int used, unused;
+    field int x;
+    function void main() {
+        var int a, b, c;
+        let a = 1;
+        let used = b;
+        do Output.printInt(used);
+        return;
+    }
+}`, Options{}, []string{
+			"Main.jack:2:22: warning: static variable unused is never read",
+			"Main.jack:3:15: warning: field x is never read",
+			"Main.jack:5:17: warning: local variable a is assigned but never read",
+			"Main.jack:5:23: warning: local variable c is never used",
+		}},
+		{"array and field reads", `class Main {
+    field Array values;
+    constructor Main new() {
+        let values = Array.new(2);
+        let values[0] = 1;
+        return this;
+    }
+    function void main() {
+        var Main m;
+        let m = Main.new();
+        return;
+    }
+}`, Options{}, []string{
+			"Main.jack:9:18: warning: local variable m is assigned but never read",
+		}},
+		{"unreachable statements", `class Main {
+    function void main() {
+        do Main.f(1);
+        return;
+        do Main.f(2);
+        do Main.f(3);
+        return;
+    }
+    function int f(int x) {
+        if (x > 0) {
+            return 1;
+        } else {
+            return 2;
+        }
+        return 3;
+    }
+}`, Options{}, []string{
+			"Main.jack:5:9: warning: unreachable statement",
+			"Main.jack:15:9: warning: unreachable statement",
+		}},
+		{"loop statements", `class Main {
+    function void main() {
+        var int i;
+        while (true) {
+            let i = i + 1;
+            if (i > 5) {
+                break;
+                let i = 0;
+            }
+            continue;
+            do Output.printInt(i);
+        }
+        return;
+    }
+}`, Options{Extended: true}, []string{
+			"Main.jack:8:17: warning: unreachable statement",
+			"Main.jack:11:13: warning: unreachable statement",
+		}},
+		{"subroutines never called", `class Main {
+    function void main() {
+        do Main.used();
+        return;
+    }
+    function void used() {
+        return;
+    }
+    function void recursive() {
+        do Main.recursive();
+        return;
+    }
+    method void unused() {
+        return;
+    }
+}`, Options{}, []string{
+			"Main.jack:9:5: warning: function Main.recursive is never called",
+			"Main.jack:13:5: warning: method Main.unused is never called",
+		}},
+		{"library", `class Main {
+    function void f() {
+        return;
+    }
+}`, Options{}, nil},
+	})
+}
+
+// Checks that stripping the program keeps the OS functions which the
+// compiler calls by itself for the operators, the strings and the constructors
+func TestStripImplicitCalls(t *testing.T) {
+	main := `class Main {
+    field int x;
+    constructor Main new() {
+        let x = 6 * 7;
+        return this;
+    }
+    function void main() {
+        var Main m;
+        let m = Main.new();
+        do Output.printString("x");
+        do Output.printInt(m.get() / 2);
+        return;
+    }
+    method int get() {
+        return x;
+    }
+}`
+	sources := []Source{{Name: "Main.jack", Reader: strings.NewReader(main)}}
+	fileNames, err := fs.Glob(jackos.Files, "*.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range fileNames {
+		file, err := jackos.Files.Open(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		sources = append(sources, Source{Name: fileName, Reader: file})
+	}
+	code, err := CompileClasses(sources, Options{StripUnused: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var program strings.Builder
+	for _, class := range code {
+		program.Write(class)
+	}
+	for _, function := range []string{"Math.multiply", "Math.divide", "String.new", "String.appendChar", "Memory.alloc", "Output.printString"} {
+		if !strings.Contains(program.String(), "function "+function+" ") {
+			t.Errorf("expected %s to be kept", function)
+		}
+	}
+	if strings.Contains(program.String(), "function Screen.drawCircle ") {
+		t.Error("expected Screen.drawCircle to be removed")
+	}
+}
+
+// Checks the warnings about the operations which the operator precedence
+// would evaluate differently
+func TestLinterPrecedence(t *testing.T) {
+	code := `class Main {
+    function void main() {
+        var int x;
+        let x = 2;
+        do Output.printInt(2 + x * 4);
+        do Output.printInt(x * 4 + 2);
+        do Output.printInt(2 + (x * 4));
+        if (x - 1 < 3 & x > 0) {
+            let x = 0;
+        }
+        return;
+    }
+}`
+	checkDiagnostics(t, []diagnosticsTest{
+		{"left to right", code, Options{}, []string{
+			"Main.jack:5:28: warning: + is evaluated before *, Jack has no operator precedence, use parentheses",
+			"Main.jack:8:13: warning: & is evaluated before >, Jack has no operator precedence, use parentheses",
+		}},
+		{"precedence", code, Options{Precedence: true}, nil},
+	})
+}
@@ -0,0 +1,202 @@
+package jackc
+
+// Optimization levels of the compilation engine
+const (
+	// Expressions are compiled like by the reference compiler
+	NO_OPTIMIZATION = 0
+	// Constant sub-expressions are folded and the identities such as x + 0,
+	// x * 1 and ~(~x) are removed
+	FOLD_CONSTANTS = 1
+	// Multiplications by powers of two are replaced by additions as well.
+	// The Hack platform has no shifts and dividing negative numbers by
+	// shifting would not round towards zero, so divisions are kept.
+	REDUCE_MULTIPLICATIONS = 2
+)
+
+// Sets the optimization level of the expressions, NO_OPTIMIZATION by default
+func (compilationEngine *CompilationEngine) SetOptimizationLevel(level int) {
+	compilationEngine.optimizationLevel = level
+}
+
+// Rewrites the expression tree according to the optimization level,
+// the operands are optimized before their operator
+func (compilationEngine *CompilationEngine) optimize(node *Expression) *Expression {
+	if compilationEngine.optimizationLevel < FOLD_CONSTANTS {
+		return node
+	}
+	for i, operand := range node.Operands {
+		node.Operands[i] = compilationEngine.optimize(operand)
+	}
+	switch node.Kind {
+	case KEYWORD_EXPRESSION:
+		switch node.Keyword {
+		case TRUE:
+			return newConstant(-1)
+		case FALSE, NULL:
+			return newConstant(0)
+		}
+	case PARENTHESES_EXPRESSION:
+		return node.Operands[0]
+	case UNARY_EXPRESSION:
+		return simplifyUnary(node)
+	case BINARY_EXPRESSION:
+		if folded, ok := foldBinary(node); ok {
+			return folded
+		}
+		simplified := simplifyBinary(node)
+		if compilationEngine.optimizationLevel >= REDUCE_MULTIPLICATIONS && simplified.Kind == BINARY_EXPRESSION && simplified.Operator == MULTIPLY {
+			return reduceMultiplication(simplified)
+		}
+		return simplified
+	}
+	return node
+}
+
+// Folds the constant operand, -(-x) and ~(~x) are replaced by x
+func simplifyUnary(node *Expression) *Expression {
+	operand := node.Operands[0]
+	if operand.Kind == CONSTANT_EXPRESSION {
+		if node.Operator == NEG {
+			return newConstant(wrap(-operand.Value))
+		}
+		return newConstant(^operand.Value)
+	}
+	if operand.Kind == UNARY_EXPRESSION && operand.Operator == node.Operator {
+		return operand.Operands[0]
+	}
+	return node
+}
+
+// Computes the operator of the constant operands with the 16-bit arithmetic
+// of the Hack platform, the comparisons give true (-1) or false (0)
+func foldBinary(node *Expression) (*Expression, bool) {
+	left, right := node.Operands[0], node.Operands[1]
+	if left.Kind != CONSTANT_EXPRESSION || right.Kind != CONSTANT_EXPRESSION {
+		return nil, false
+	}
+	x, y := left.Value, right.Value
+	switch node.Operator {
+	case PLUS:
+		return newConstant(wrap(x + y)), true
+	case MINUS:
+		return newConstant(wrap(x - y)), true
+	case MULTIPLY:
+		return newConstant(wrap(x * y)), true
+	case DIVIDE:
+		// Division by zero is left to Math.divide to report it
+		if y == 0 {
+			return nil, false
+		}
+		return newConstant(wrap(x / y)), true
+	case AND:
+		return newConstant(x & y), true
+	case OR:
+		return newConstant(x | y), true
+	case LESS:
+		return newConstant(toBoolean(x < y)), true
+	case GREATER:
+		return newConstant(toBoolean(x > y)), true
+	case EQUAL:
+		return newConstant(toBoolean(x == y)), true
+	}
+	return nil, false
+}
+
+// Removes the operations with the identity operand. Operands multiplied
+// by zero are removed only if they have no side effects.
+func simplifyBinary(node *Expression) *Expression {
+	left, right := node.Operands[0], node.Operands[1]
+	switch node.Operator {
+	case PLUS, OR:
+		if right.isConstant(0) {
+			return left
+		}
+		if left.isConstant(0) {
+			return right
+		}
+	case MINUS:
+		if right.isConstant(0) {
+			return left
+		}
+		if left.isConstant(0) {
+			return newUnary(NEG, right)
+		}
+	case AND:
+		if right.isConstant(-1) {
+			return left
+		}
+		if left.isConstant(-1) {
+			return right
+		}
+		if right.isConstant(0) && left.isPure() || left.isConstant(0) && right.isPure() {
+			return newConstant(0)
+		}
+	case MULTIPLY:
+		if right.isConstant(1) {
+			return left
+		}
+		if left.isConstant(1) {
+			return right
+		}
+		if right.isConstant(-1) {
+			return simplifyUnary(newUnary(NEG, left))
+		}
+		if left.isConstant(-1) {
+			return simplifyUnary(newUnary(NEG, right))
+		}
+		if right.isConstant(0) && left.isPure() || left.isConstant(0) && right.isPure() {
+			return newConstant(0)
+		}
+	case DIVIDE:
+		if right.isConstant(1) {
+			return left
+		}
+		if right.isConstant(-1) {
+			return simplifyUnary(newUnary(NEG, left))
+		}
+	}
+	return node
+}
+
+// Replaces the multiplication by the power of two with additions. A variable
+// multiplied by two is added to itself, other operands are doubled through
+// temp 1, which is not used by the compiled statements.
+func reduceMultiplication(node *Expression) *Expression {
+	left, right := node.Operands[0], node.Operands[1]
+	if left.Kind == CONSTANT_EXPRESSION {
+		left, right = right, left
+	}
+	exponent := getExponent(right)
+	if exponent <= 0 {
+		return node
+	}
+	if exponent == 1 && (left.Kind == VARIABLE_EXPRESSION || left.Kind == CONSTANT_EXPRESSION) {
+		return newBinary(PLUS, left, left)
+	}
+	return &Expression{Kind: DOUBLE_EXPRESSION, Value: exponent, Operands: []*Expression{left}}
+}
+
+// Returns the exponent of the positive constant power of two,
+// 0 for the other expressions
+func getExponent(node *Expression) int {
+	if node.Kind != CONSTANT_EXPRESSION || node.Value <= 0 || node.Value&(node.Value-1) != 0 {
+		return 0
+	}
+	exponent := 0
+	for value := node.Value; value > 1; value >>= 1 {
+		exponent++
+	}
+	return exponent
+}
+
+// Wraps the value around to the 16-bit range like the Hack ALU
+func wrap(value int) int {
+	return int(int16(value))
+}
+
+func toBoolean(value bool) int {
+	if value {
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package jackc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Checks the VM code of the expressions at each optimization level,
+// the commands are separated by commas
+func TestOptimizer(t *testing.T) {
+	reduced := "push argument 0, pop temp 1, " + strings.Repeat("push temp 1, push temp 1, add, pop temp 1, ", 2) + "push temp 1, push temp 1, add"
+	for _, test := range []struct {
+		name       string
+		expression string
+		level      int
+		expected   string
+	}{
+		{"constants", "2 * 3 + 1", NO_OPTIMIZATION, "push constant 2, push constant 3, call Math.multiply 2, push constant 1, add"},
+		{"constants folded", "2 * 3 + 1", FOLD_CONSTANTS, "push constant 7"},
+		{"constants reduced", "2 * 3 + 1", REDUCE_MULTIPLICATIONS, "push constant 7"},
+		{"overflow", "32767 + 1", NO_OPTIMIZATION, "push constant 32767, push constant 1, add"},
+		// -32768 has no constant, it is the complement of 32767
+		{"overflow folded", "32767 + 1", FOLD_CONSTANTS, "push constant 32767, not"},
+		{"comparison folded", "1 < 2", FOLD_CONSTANTS, "push constant 0, not"},
+		{"power of two", "x * 8", NO_OPTIMIZATION, "push argument 0, push constant 8, call Math.multiply 2"},
+		{"power of two folded", "x * 8", FOLD_CONSTANTS, "push argument 0, push constant 8, call Math.multiply 2"},
+		{"power of two reduced", "x * 8", REDUCE_MULTIPLICATIONS, reduced},
+		{"other factor reduced", "x * 6", REDUCE_MULTIPLICATIONS, "push argument 0, push constant 6, call Math.multiply 2"},
+		{"division reduced", "x / 4", REDUCE_MULTIPLICATIONS, "push argument 0, push constant 4, call Math.divide 2"},
+		{"identities folded", "~(~x) * 1 + 0", FOLD_CONSTANTS, "push argument 0"},
+		// The call may have side effects
+		{"call times zero folded", "Main.g() * 0", FOLD_CONSTANTS, "call Main.g 0, push constant 0, call Math.multiply 2"},
+		{"call times zero reduced", "Main.g() * 0", REDUCE_MULTIPLICATIONS, "call Main.g 0, push constant 0, call Math.multiply 2"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			expected := strings.Split(test.expected, ", ")
+			if found := compileExpression(t, test.expression, test.level); strings.Join(found, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("expected\n%s\nfound\n%s", strings.Join(expected, "\n"), strings.Join(found, "\n"))
+			}
+		})
+	}
+}
+
+// Compiles the expression returned by Main.f(x) and returns its VM commands
+func compileExpression(t *testing.T, expression string, level int) []string {
+	t.Helper()
+	code := `class Main {
+    function int f(int x) {
+        return ` + expression + `;
+    }
+    function int g() {
+        return 0;
+    }
+}`
+	var vm bytes.Buffer
+	if err := CompileWith(strings.NewReader(code), &vm, Options{OptimizationLevel: level}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(vm.String(), "\n")
+	if len(lines) < 3 || lines[0] != "function Main.f 0" {
+		t.Fatalf("unexpected VM code\n%s", vm.String())
+	}
+	for i, line := range lines {
+		if line == "return" {
+			return lines[1:i]
+		}
+	}
+	t.Fatalf("no return in\n%s", vm.String())
+	return nil
+}
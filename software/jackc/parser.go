@@ -0,0 +1,607 @@
+package jackc
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Parses the Jack class into its syntax tree. Syntax errors are recorded
+// in the diagnostics and the parsing continues with the next statement
+// or class member.
+type Parser struct {
+	diagnostics diagnostics.List
+	reportedEnd bool
+	tokenizer   *Tokenizer
+	// Accepts the statements of the extended Jack, see SetExtended
+	extended bool
+	// Parses the binary operators by their precedence, see SetPrecedence
+	precedence bool
+}
+
+// Raised by the eat functions to abandon the current statement or
+// declaration after a syntax error
+type syntaxError struct{}
+
+// Names of the token types used in the diagnostics
+var tokenTypeNames = map[TokenType]string{
+	KEYWORD:      "keyword",
+	SYMBOL:       "symbol",
+	IDENTIFIER:   "identifier",
+	INT_CONST:    "integer constant",
+	STRING_CONST: "string constant",
+}
+
+// Creates the parser of the Jack class read from the reader,
+// the file name is used by the diagnostics
+func NewParser(reader io.Reader, fileName string) *Parser {
+	return &Parser{tokenizer: NewTokenizer(reader, fileName)}
+}
+
+// Enables the extensions of the Jack language: for loops, else if chains,
+// break and continue, the compound assignments += and -= and the ++ and --
+// statements. let may be left out of the assignments, e.g. i += 2;
+func (parser *Parser) SetExtended(extended bool) {
+	parser.extended = extended
+	parser.tokenizer.extended = extended
+}
+
+// Enables the conventional precedence of the binary operators, from the
+// highest: * and /, + and -, the comparisons, & and |. The operators of the
+// same precedence are evaluated from left to right like all of them in Jack.
+func (parser *Parser) SetPrecedence(precedence bool) {
+	parser.precedence = precedence
+}
+
+// Parses the class and returns its syntax tree together with the errors
+// of the tokenizer and the parser sorted by their position. The tree of
+// a class with errors lacks the broken statements and declarations.
+func (parser *Parser) ParseClass() (*Class, error) {
+	class := &Class{}
+	parser.tokenizer.Advance()
+	parser.try(func() {
+		class.Position = parser.tokenizer.Position()
+		parser.eatKeyword(CLASS)
+		class.Name = parser.eatIdentifier()
+		parser.eatSymbol(LEFT_CURLY)
+		for parser.isKeyword(STATIC, FIELD) {
+			parser.try(func() {
+				class.Variables = append(class.Variables, parser.parseClassVariableDeclaration())
+			}, parser.isClassMemberStart)
+		}
+		for parser.isKeyword(CONSTRUCTOR, METHOD, FUNCTION) {
+			subroutine := &Subroutine{}
+			class.Subroutines = append(class.Subroutines, subroutine)
+			parser.try(func() { parser.parseSubroutine(subroutine) }, parser.isClassMemberStart)
+		}
+		parser.eatSymbol(RIGHT_CURLY)
+	}, nil)
+
+	errors := append(parser.tokenizer.Diagnostics, parser.diagnostics...)
+	sortDiagnostics(errors)
+	return class, errors.Err()
+}
+
+func (parser *Parser) parseClassVariableDeclaration() *Declaration {
+	declaration := &Declaration{Position: parser.tokenizer.Position()}
+	declaration.Kind = parser.eatKeyword(STATIC, FIELD)
+	declaration.Type = parser.eatType()
+	parser.parseNames(declaration)
+	parser.eatSymbol(SEMICOLON)
+	return declaration
+}
+
+// Parses the subroutine into the subroutine of the class, so that
+// the class keeps the declaration even if its body is broken
+func (parser *Parser) parseSubroutine(subroutine *Subroutine) {
+	subroutine.Position = parser.tokenizer.Position()
+	subroutine.Kind = parser.eatKeyword(CONSTRUCTOR, METHOD, FUNCTION)
+	if isType, returnType := parser.parseType(); isType {
+		subroutine.ReturnType = returnType
+	} else {
+		parser.eatKeyword(VOID)
+		subroutine.ReturnType = "void"
+	}
+	subroutine.Name = parser.eatIdentifier()
+	parser.eatSymbol(LEFT_PARANTHESIS)
+	subroutine.Parameters = parser.parseParameterList()
+	parser.eatSymbol(RIGHT_PARANTHESIS)
+	parser.eatSymbol(LEFT_CURLY)
+	for parser.isKeyword(VAR) {
+		subroutine.Locals = append(subroutine.Locals, parser.parseVariableDeclaration())
+	}
+	subroutine.Statements = parser.parseStatements()
+	subroutine.End = parser.tokenizer.Position()
+	parser.eatSymbol(RIGHT_CURLY)
+}
+
+func (parser *Parser) parseParameterList() []*Declaration {
+	var parameters []*Declaration
+	position := parser.tokenizer.Position()
+	if hasParameter, varType := parser.parseType(); hasParameter {
+		parameter := &Declaration{Position: position, Kind: ARG, Type: varType}
+		parser.parseName(parameter)
+		parameters = append(parameters, parameter)
+		for parser.isSymbol(COMMA) {
+			parser.eatSymbol(COMMA)
+			parameter := &Declaration{Position: parser.tokenizer.Position(), Kind: ARG}
+			parameter.Type = parser.eatType()
+			parser.parseName(parameter)
+			parameters = append(parameters, parameter)
+		}
+	}
+	return parameters
+}
+
+func (parser *Parser) parseVariableDeclaration() *Declaration {
+	declaration := &Declaration{Position: parser.tokenizer.Position(), Kind: VAR}
+	parser.eatKeyword(VAR)
+	declaration.Type = parser.eatType()
+	parser.parseNames(declaration)
+	parser.eatSymbol(SEMICOLON)
+	return declaration
+}
+
+// Parses the names of the declaration separated by commas
+func (parser *Parser) parseNames(declaration *Declaration) {
+	parser.parseName(declaration)
+	for parser.isSymbol(COMMA) {
+		parser.eatSymbol(COMMA)
+		parser.parseName(declaration)
+	}
+}
+
+func (parser *Parser) parseName(declaration *Declaration) {
+	position := parser.tokenizer.Position()
+	declaration.Names = append(declaration.Names, Name{Position: position, Text: parser.eatIdentifier()})
+}
+
+// Parses the statements, the broken ones are left out
+func (parser *Parser) parseStatements() []Statement {
+	var statements []Statement
+	for parser.isStatementStart() {
+		parser.try(func() {
+			statements = append(statements, parser.parseStatement())
+		}, parser.isStatementEnd)
+	}
+	return statements
+}
+
+func (parser *Parser) parseStatement() Statement {
+	switch parser.tokenizer.GetKeyword() {
+	case LET:
+		return parser.parseLet()
+	case IF:
+		return parser.parseIf()
+	case WHILE:
+		return parser.parseWhile()
+	case DO:
+		return parser.parseDo()
+	case RETURN:
+		return parser.parseReturn()
+	case FOR:
+		return parser.parseFor()
+	case BREAK, CONTINUE:
+		return parser.parseJump()
+	}
+	// Assignments of the extended Jack without let
+	return parser.parseLet()
+}
+
+func (parser *Parser) parseLet() *LetStatement {
+	statement := parser.parseAssignment()
+	parser.eatSymbol(SEMICOLON)
+	return statement
+}
+
+// Parses the let statement without the semicolon. The extended Jack allows
+// to leave out let and to use the compound assignments, which are turned into
+// the assignments of the binary expressions, so the index of an array
+// entry is evaluated twice, e.g. a[i] += 2 is let a[i] = a[i] + 2.
+func (parser *Parser) parseAssignment() *LetStatement {
+	statement := &LetStatement{Position: parser.tokenizer.Position()}
+	if !parser.extended || parser.isKeyword(LET) {
+		parser.eatKeyword(LET)
+	}
+	statement.Target = &Expression{Kind: VARIABLE_EXPRESSION, Position: parser.tokenizer.Position()}
+	statement.Target.Name = parser.eatIdentifier()
+	if parser.isSymbol(LEFT_BRACKET) {
+		parser.eatSymbol(LEFT_BRACKET)
+		statement.Target.Kind = ARRAY_EXPRESSION
+		statement.Target.Operands = []*Expression{parser.parseExpression()}
+		parser.eatSymbol(RIGHT_BRACKET)
+	}
+	if !parser.extended {
+		parser.eatSymbol(EQUAL)
+		statement.Value = parser.parseExpression()
+		return statement
+	}
+
+	position := parser.tokenizer.Position()
+	var operator Symbol
+	var value *Expression
+	switch parser.eatSymbol(EQUAL, PLUS_ASSIGN, MINUS_ASSIGN, INCREMENT, DECREMENT) {
+	case EQUAL:
+		statement.Value = parser.parseExpression()
+		return statement
+	case PLUS_ASSIGN:
+		operator, value = PLUS, parser.parseExpression()
+	case MINUS_ASSIGN:
+		operator, value = MINUS, parser.parseExpression()
+	case INCREMENT:
+		operator, value = PLUS, newConstant(1)
+		value.Position = position
+	case DECREMENT:
+		operator, value = MINUS, newConstant(1)
+		value.Position = position
+	}
+	target := *statement.Target
+	target.Operands = append([]*Expression(nil), target.Operands...)
+	statement.Value = newBinary(operator, &target, value)
+	statement.Value.Position = target.Position
+	return statement
+}
+
+func (parser *Parser) parseIf() *IfStatement {
+	statement := &IfStatement{Position: parser.tokenizer.Position()}
+	parser.eatKeyword(IF)
+	statement.Condition = parser.parseCondition()
+	statement.Then = parser.parseBlock()
+	if parser.isKeyword(ELSE) {
+		parser.eatKeyword(ELSE)
+		statement.HasElse = true
+		if parser.extended && parser.isKeyword(IF) {
+			statement.Else = []Statement{parser.parseIf()}
+		} else {
+			statement.Else = parser.parseBlock()
+		}
+	}
+	return statement
+}
+
+func (parser *Parser) parseWhile() *WhileStatement {
+	statement := &WhileStatement{Position: parser.tokenizer.Position()}
+	parser.eatKeyword(WHILE)
+	statement.Condition = parser.parseCondition()
+	statement.Body = parser.parseBlock()
+	return statement
+}
+
+// Parses the for loop of the extended Jack, each of its parts may be left out
+func (parser *Parser) parseFor() *ForStatement {
+	statement := &ForStatement{Position: parser.tokenizer.Position()}
+	parser.eatKeyword(FOR)
+	parser.eatSymbol(LEFT_PARANTHESIS)
+	if !parser.isSymbol(SEMICOLON) {
+		statement.Init = []Statement{parser.parseAssignment()}
+	}
+	parser.eatSymbol(SEMICOLON)
+	if !parser.isSymbol(SEMICOLON) {
+		statement.Condition = parser.parseExpression()
+	}
+	parser.eatSymbol(SEMICOLON)
+	if !parser.isSymbol(RIGHT_PARANTHESIS) {
+		statement.Step = []Statement{parser.parseAssignment()}
+	}
+	parser.eatSymbol(RIGHT_PARANTHESIS)
+	statement.Body = parser.parseBlock()
+	return statement
+}
+
+// Parses break or continue
+func (parser *Parser) parseJump() Statement {
+	position := parser.tokenizer.Position()
+	keyword := parser.eatKeyword(BREAK, CONTINUE)
+	parser.eatSymbol(SEMICOLON)
+	if keyword == BREAK {
+		return &BreakStatement{Position: position}
+	}
+	return &ContinueStatement{Position: position}
+}
+
+func (parser *Parser) parseDo() *DoStatement {
+	statement := &DoStatement{Position: parser.tokenizer.Position()}
+	parser.eatKeyword(DO)
+	position := parser.tokenizer.Position()
+	statement.Call = parser.parseSubroutineCall(position, parser.eatIdentifier())
+	parser.eatSymbol(SEMICOLON)
+	return statement
+}
+
+func (parser *Parser) parseReturn() *ReturnStatement {
+	statement := &ReturnStatement{Position: parser.tokenizer.Position()}
+	parser.eatKeyword(RETURN)
+	if !parser.isSymbol(SEMICOLON) {
+		statement.Value = parser.parseExpression()
+	}
+	parser.eatSymbol(SEMICOLON)
+	return statement
+}
+
+// Parses the expression in parentheses of if and while
+func (parser *Parser) parseCondition() *Expression {
+	parser.eatSymbol(LEFT_PARANTHESIS)
+	condition := parser.parseExpression()
+	parser.eatSymbol(RIGHT_PARANTHESIS)
+	return condition
+}
+
+// Parses the statements in curly brackets
+func (parser *Parser) parseBlock() []Statement {
+	parser.eatSymbol(LEFT_CURLY)
+	statements := parser.parseStatements()
+	parser.eatSymbol(RIGHT_CURLY)
+	return statements
+}
+
+func (parser *Parser) parseExpression() *Expression {
+	if parser.precedence {
+		return parser.parseOperations(1)
+	}
+	node := parser.parseTerm()
+	for parser.isSymbol(PLUS, MINUS, MULTIPLY, DIVIDE, AND, OR, LESS, GREATER, EQUAL) {
+		symbol := parser.eatSymbol(PLUS, MINUS, MULTIPLY, DIVIDE, AND, OR, LESS, GREATER, EQUAL)
+		position := node.Position
+		node = newBinary(symbol, node, parser.parseTerm())
+		node.Position = position
+	}
+	return node
+}
+
+// Parses the terms joined by the operators of at least the minimum precedence,
+// the right operands are the operations of the higher precedences
+func (parser *Parser) parseOperations(minimum int) *Expression {
+	node := parser.parseTerm()
+	for parser.isSymbol(PLUS, MINUS, MULTIPLY, DIVIDE, AND, OR, LESS, GREATER, EQUAL) && precedences[parser.tokenizer.GetSymbol()] >= minimum {
+		symbol := parser.eatSymbol(PLUS, MINUS, MULTIPLY, DIVIDE, AND, OR, LESS, GREATER, EQUAL)
+		position := node.Position
+		node = newBinary(symbol, node, parser.parseOperations(precedences[symbol]+1))
+		node.Position = position
+	}
+	return node
+}
+
+func (parser *Parser) parseTerm() *Expression {
+	position := parser.tokenizer.Position()
+	if parser.isKeyword(TRUE, FALSE, NULL, THIS) {
+		return &Expression{Kind: KEYWORD_EXPRESSION, Position: position, Keyword: parser.eatKeyword()}
+	} else if parser.isIdentifier() {
+		switch next := parser.tokenizer.Peek(1); {
+		case next.isSymbol(LEFT_BRACKET):
+			name := parser.eatIdentifier()
+			parser.eatSymbol(LEFT_BRACKET)
+			index := parser.parseExpression()
+			parser.eatSymbol(RIGHT_BRACKET)
+			return &Expression{Kind: ARRAY_EXPRESSION, Position: position, Name: name, Operands: []*Expression{index}}
+		case next.isSymbol(LEFT_PARANTHESIS, DOT):
+			return parser.parseSubroutineCall(position, parser.eatIdentifier())
+		}
+		return &Expression{Kind: VARIABLE_EXPRESSION, Position: position, Name: parser.eatIdentifier()}
+	} else if parser.tokenizer.GetTokenType() == STRING_CONST {
+		return &Expression{Kind: STRING_EXPRESSION, Position: position, Text: parser.eatString()}
+	} else if parser.tokenizer.GetTokenType() == INT_CONST {
+		node := newConstant(parser.eatInteger())
+		node.Position = position
+		return node
+	} else if parser.isSymbol(LEFT_PARANTHESIS) {
+		parser.eatSymbol(LEFT_PARANTHESIS)
+		node := &Expression{Kind: PARENTHESES_EXPRESSION, Position: position, Operands: []*Expression{parser.parseExpression()}}
+		parser.eatSymbol(RIGHT_PARANTHESIS)
+		return node
+	} else if parser.isSymbol(MINUS, NOT) {
+		symbol := parser.eatSymbol(MINUS, NOT)
+		if symbol == MINUS {
+			symbol = NEG
+		}
+		node := newUnary(symbol, parser.parseTerm())
+		node.Position = position
+		return node
+	}
+	parser.expected("expression")
+	return nil
+}
+
+// Parses the call following the name of the subroutine, class or variable
+func (parser *Parser) parseSubroutineCall(position diagnostics.Position, name string) *Expression {
+	call := &Expression{Kind: CALL_EXPRESSION, Position: position, Name: name}
+	if parser.isSymbol(DOT) {
+		parser.eatSymbol(DOT)
+		call.Qualifier = name
+		call.Name = parser.eatIdentifier()
+	}
+	parser.eatSymbol(LEFT_PARANTHESIS)
+	call.Operands = parser.parseExpressionList()
+	parser.eatSymbol(RIGHT_PARANTHESIS)
+	return call
+}
+
+func (parser *Parser) parseExpressionList() []*Expression {
+	var list []*Expression
+	if !parser.isSymbol() || parser.isSymbol(MINUS, NOT, LEFT_PARANTHESIS) {
+		list = append(list, parser.parseExpression())
+		for parser.isSymbol(COMMA) {
+			parser.eatSymbol(COMMA)
+			list = append(list, parser.parseExpression())
+		}
+	}
+	return list
+}
+
+func (parser *Parser) parseType() (bool, string) {
+	var typeVar string
+	if parser.isKeyword(INT, CHAR, BOOLEAN) {
+		typeVar = parser.tokenizer.GetText()
+		parser.eatKeyword(INT, CHAR, BOOLEAN)
+	} else if parser.isIdentifier() {
+		typeVar = parser.tokenizer.GetIdentifier()
+		parser.eatIdentifier()
+	} else {
+		return false, ""
+	}
+	return true, typeVar
+}
+
+func (parser *Parser) eatType() string {
+	isType, typeVar := parser.parseType()
+	if !isType {
+		parser.expected("type")
+	}
+	return typeVar
+}
+
+func (parser *Parser) eatKeyword(keywords ...Keyword) Keyword {
+	if !parser.isKeyword(keywords...) {
+		expected := make([]string, len(keywords))
+		for i, keyword := range keywords {
+			expected[i] = diagnostics.Quote(getKeywordText(keyword))
+		}
+		parser.expected(describeAlternatives("keyword", expected))
+	}
+	keyword := parser.tokenizer.GetKeyword()
+	parser.tokenizer.Advance()
+	return keyword
+}
+
+func (parser *Parser) isKeyword(keywords ...Keyword) bool {
+	if parser.tokenizer.GetTokenType() != KEYWORD {
+		return false
+	} else if len(keywords) == 0 {
+		return true
+	}
+	for _, keyword := range keywords {
+		if parser.tokenizer.GetKeyword() == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+func (parser *Parser) eatSymbol(symbols ...Symbol) Symbol {
+	if !parser.isSymbol(symbols...) {
+		expected := make([]string, len(symbols))
+		for i, symbol := range symbols {
+			expected[i] = diagnostics.Quote(getSymbolText(symbol))
+		}
+		parser.expected(describeAlternatives("symbol", expected))
+	}
+	symbol := parser.tokenizer.GetSymbol()
+	parser.tokenizer.Advance()
+	return symbol
+}
+
+func (parser *Parser) isSymbol(symbols ...Symbol) bool {
+	if parser.tokenizer.GetTokenType() != SYMBOL {
+		return false
+	}
+	for _, symbol := range symbols {
+		if parser.tokenizer.GetSymbol() == symbol {
+			return true
+		}
+	}
+	return len(symbols) == 0
+}
+
+func (parser *Parser) eatString() string {
+	if parser.tokenizer.GetTokenType() != STRING_CONST {
+		parser.expected("string constant")
+	}
+	stringConst := parser.tokenizer.GetStringValue()
+	parser.tokenizer.Advance()
+	return stringConst
+}
+
+func (parser *Parser) eatInteger() int {
+	if parser.tokenizer.GetTokenType() != INT_CONST {
+		parser.expected("integer constant")
+	}
+	value := parser.tokenizer.GetIntegerValue()
+	parser.tokenizer.Advance()
+	return value
+}
+
+func (parser *Parser) eatIdentifier() string {
+	if !parser.isIdentifier() {
+		parser.expected("identifier")
+	}
+	identifier := parser.tokenizer.GetIdentifier()
+	parser.tokenizer.Advance()
+	return identifier
+}
+
+func (parser *Parser) isIdentifier() bool {
+	return parser.tokenizer.GetTokenType() == IDENTIFIER
+}
+
+// Runs parse and recovers from the syntax errors raised by it. After an error
+// the tokens are skipped until isEnd returns true, all the remaining
+// tokens are skipped when isEnd is nil.
+func (parser *Parser) try(parse func(), isEnd func() bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(syntaxError); !ok {
+				panic(r)
+			}
+			for parser.tokenizer.GetTokenType() != END_OF_FILE && (isEnd == nil || !isEnd()) {
+				parser.tokenizer.Advance()
+			}
+		}
+	}()
+	parse()
+}
+
+// Returns true at the beginning of the next statement or at the end of the
+// statements, the semicolon ending the broken statement is skipped
+func (parser *Parser) isStatementEnd() bool {
+	if parser.isSymbol(SEMICOLON) {
+		parser.tokenizer.Advance()
+		return true
+	}
+	return parser.isSymbol(RIGHT_CURLY) || parser.isStatementStart()
+}
+
+// Returns true at the keywords starting the statements, the keywords of the
+// extended Jack are identifiers unless it is enabled. Assignments of the
+// extended Jack may start with the identifier.
+func (parser *Parser) isStatementStart() bool {
+	return parser.isKeyword(LET, IF, WHILE, DO, RETURN, FOR, BREAK, CONTINUE) || parser.extended && parser.isIdentifier()
+}
+
+func (parser *Parser) isClassMemberStart() bool {
+	return parser.isKeyword(STATIC, FIELD, CONSTRUCTOR, METHOD, FUNCTION)
+}
+
+// Records that the current token is not the expected one and abandons the
+// current statement. The end of the file is reported only once.
+func (parser *Parser) expected(expected string) {
+	tokenizer := parser.tokenizer
+	tokenType := tokenizer.GetTokenType()
+	found := "end of file"
+	if tokenType != END_OF_FILE {
+		found = tokenTypeNames[tokenType] + " " + diagnostics.Quote(tokenizer.GetText())
+	} else if parser.reportedEnd {
+		panic(syntaxError{})
+	}
+	parser.reportedEnd = tokenType == END_OF_FILE
+	parser.diagnostics.Add(diagnostics.Expected(tokenizer.Position(), tokenizer.GetText(), expected, found))
+	panic(syntaxError{})
+}
+
+// Describes the expected tokens, e.g. symbol ';' or ')'
+func describeAlternatives(kind string, alternatives []string) string {
+	if len(alternatives) == 0 {
+		return kind
+	}
+	return kind + " " + strings.Join(alternatives, " or ")
+}
+
+// Sorts the diagnostics of the class by their position
+func sortDiagnostics(list diagnostics.List) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Line != list[j].Line {
+			return list[i].Line < list[j].Line
+		}
+		return list[i].Column < list[j].Column
+	})
+}
@@ -0,0 +1,54 @@
+package jackc
+
+import "strconv"
+
+// Interns the string constants of the class, every distinct constant is kept
+// in a static variable following the statics of the class. The string is
+// created by the first call of its function, e.g. Main.$string0, and the
+// later calls return the same object, so it must be neither changed nor
+// disposed. Constants are not pooled by default.
+func (compilationEngine *CompilationEngine) SetStringPooling(pooling bool) {
+	compilationEngine.stringPooling = pooling
+}
+
+// Replaces the string constants of the expression by the calls
+// of the functions returning their pooled strings
+func (compilationEngine *CompilationEngine) poolStrings(node *Expression) *Expression {
+	if !compilationEngine.stringPooling {
+		return node
+	}
+	if node.Kind == STRING_EXPRESSION {
+		index, pooled := compilationEngine.stringIndexes[node.Text]
+		if !pooled {
+			index = len(compilationEngine.strings)
+			compilationEngine.stringIndexes[node.Text] = index
+			compilationEngine.strings = append(compilationEngine.strings, node.Text)
+		}
+		return &Expression{Kind: CALL_EXPRESSION, Position: node.Position, function: compilationEngine.getStringFunction(index)}
+	}
+	for i, operand := range node.Operands {
+		node.Operands[i] = compilationEngine.poolStrings(operand)
+	}
+	return node
+}
+
+// Writes the functions creating the pooled strings of the class on their first call
+func (compilationEngine *CompilationEngine) writeStringFunctions(staticCount int) {
+	for i, text := range compilationEngine.strings {
+		vmWriter := compilationEngine.vmWriter
+		vmWriter.WriteFunction(compilationEngine.getStringFunction(i), 0)
+		vmWriter.WritePush(STATIC, staticCount+i)
+		vmWriter.WriteIf("POOLED")
+		vmWriter.writeExpression(&Expression{Kind: STRING_EXPRESSION, Text: text})
+		vmWriter.WritePop(STATIC, staticCount+i)
+		vmWriter.WriteLabel("POOLED")
+		vmWriter.WritePush(STATIC, staticCount+i)
+		vmWriter.WriteReturn()
+	}
+}
+
+// Returns the name of the function of the pooled string, $ keeps it apart
+// from the subroutines of the class
+func (compilationEngine *CompilationEngine) getStringFunction(index int) string {
+	return compilationEngine.className + "$string" + strconv.Itoa(index)
+}
@@ -1,4 +1,4 @@
-package main
+package jackc
 
 type VariableInformation struct {
 	kind         Keyword
@@ -65,3 +65,14 @@ func (symbolTable *SymbolTable) HasVariable(name string) bool {
 	}
 	return has
 }
+
+// Returns true if the name is declared in the scope of the kind,
+// the class for STATIC and FIELD, the subroutine for ARG and VAR
+func (symbolTable *SymbolTable) HasVariableInScope(name string, kind Keyword) bool {
+	scope := symbolTable.subroutine
+	if kind == STATIC || kind == FIELD {
+		scope = symbolTable.class
+	}
+	_, has := scope[name]
+	return has
+}
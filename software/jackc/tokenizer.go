@@ -0,0 +1,600 @@
+package jackc
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Encapsulates access to the input code. Reads the Jack code character by
+// character and splits it into tokens, skipping the white space and the
+// comments. The tokens following the current one can be looked at with Peek.
+// Invalid tokens are recorded in Diagnostics and skipped.
+type Tokenizer struct {
+	Diagnostics diagnostics.List
+	reader      *bufio.Reader
+	token       Token
+	// Tokens read by Peek which follow the current token
+	lookahead []Token
+	// Position of the next character of the reader
+	position  diagnostics.Position
+	readError error
+	// Recognizes the keywords and symbols and the escape sequences of the extended Jack
+	extended bool
+}
+
+// Token of the Jack code, the text of the string constants includes the quotes
+type Token struct {
+	Type     TokenType
+	Text     string
+	Position diagnostics.Position
+}
+
+type TokenType int
+
+const (
+	KEYWORD      TokenType = iota
+	SYMBOL       TokenType = iota
+	IDENTIFIER   TokenType = iota
+	INT_CONST    TokenType = iota
+	STRING_CONST TokenType = iota
+	END_OF_FILE  TokenType = iota
+)
+
+type Keyword int
+
+const (
+	CLASS       Keyword = iota
+	METHOD      Keyword = iota
+	FUNCTION    Keyword = iota
+	CONSTRUCTOR Keyword = iota
+	INT         Keyword = iota
+	BOOLEAN     Keyword = iota
+	CHAR        Keyword = iota
+	VOID        Keyword = iota
+	VAR         Keyword = iota
+	STATIC      Keyword = iota
+	FIELD       Keyword = iota
+	LET         Keyword = iota
+	DO          Keyword = iota
+	IF          Keyword = iota
+	ELSE        Keyword = iota
+	WHILE       Keyword = iota
+	RETURN      Keyword = iota
+	TRUE        Keyword = iota
+	FALSE       Keyword = iota
+	NULL        Keyword = iota
+	THIS        Keyword = iota
+	FOR         Keyword = iota
+	BREAK       Keyword = iota
+	CONTINUE    Keyword = iota
+	ARG         Keyword = iota
+	CONST       Keyword = iota
+	THAT        Keyword = iota
+	LOCAL       Keyword = iota
+	POINTER     Keyword = iota
+	TEMP        Keyword = iota
+)
+
+var keywords = map[string]Keyword{
+	"class":       CLASS,
+	"constructor": CONSTRUCTOR,
+	"function":    FUNCTION,
+	"method":      METHOD,
+	"field":       FIELD,
+	"static":      STATIC,
+	"var":         VAR,
+	"int":         INT,
+	"char":        CHAR,
+	"boolean":     BOOLEAN,
+	"void":        VOID,
+	"true":        TRUE,
+	"false":       FALSE,
+	"null":        NULL,
+	"this":        THIS,
+	"let":         LET,
+	"do":          DO,
+	"if":          IF,
+	"else":        ELSE,
+	"while":       WHILE,
+	"return":      RETURN,
+}
+
+// Keywords of the extended Jack, identifiers in the standard one
+var extendedKeywords = map[string]Keyword{
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+}
+
+type Symbol int
+
+const (
+	LEFT_PARANTHESIS  Symbol = iota
+	RIGHT_PARANTHESIS Symbol = iota
+	LEFT_BRACKET      Symbol = iota
+	RIGHT_BRACKET     Symbol = iota
+	LEFT_CURLY        Symbol = iota
+	RIGHT_CURLY       Symbol = iota
+	DOT               Symbol = iota
+	COMMA             Symbol = iota
+	SEMICOLON         Symbol = iota
+	PLUS              Symbol = iota
+	MINUS             Symbol = iota
+	MULTIPLY          Symbol = iota
+	DIVIDE            Symbol = iota
+	AND               Symbol = iota
+	OR                Symbol = iota
+	LESS              Symbol = iota
+	GREATER           Symbol = iota
+	EQUAL             Symbol = iota
+	NOT               Symbol = iota
+	NEG               Symbol = iota
+	PLUS_ASSIGN       Symbol = iota
+	MINUS_ASSIGN      Symbol = iota
+	INCREMENT         Symbol = iota
+	DECREMENT         Symbol = iota
+)
+
+var symbols = map[byte]Symbol{
+	'(': LEFT_PARANTHESIS,
+	')': RIGHT_PARANTHESIS,
+	'[': LEFT_BRACKET,
+	']': RIGHT_BRACKET,
+	'{': LEFT_CURLY,
+	'}': RIGHT_CURLY,
+	'.': DOT,
+	',': COMMA,
+	';': SEMICOLON,
+	'+': PLUS,
+	'-': MINUS,
+	'*': MULTIPLY,
+	'/': DIVIDE,
+	'&': AND,
+	'|': OR,
+	'<': LESS,
+	'>': GREATER,
+	'=': EQUAL,
+	'~': NOT,
+}
+
+// Symbols of the extended Jack, two symbols in the standard one, e.g. a--b is a-(-b)
+var extendedSymbols = map[string]Symbol{
+	"+=": PLUS_ASSIGN,
+	"-=": MINUS_ASSIGN,
+	"++": INCREMENT,
+	"--": DECREMENT,
+}
+
+// Creates the tokenizer of the input, the file name is used by the diagnostics
+func NewTokenizer(reader io.Reader, fileName string) *Tokenizer {
+	start := diagnostics.Position{FileName: fileName, Line: 1, Column: 1}
+	return &Tokenizer{reader: bufio.NewReader(reader), position: start}
+}
+
+// Reads the next token from the input and makes it current
+// Returns true if there are more tokens in the input
+func (tokenizer *Tokenizer) Advance() bool {
+	if len(tokenizer.lookahead) > 0 {
+		tokenizer.token = tokenizer.lookahead[0]
+		tokenizer.lookahead = tokenizer.lookahead[1:]
+	} else {
+		tokenizer.token = tokenizer.scan()
+	}
+	return tokenizer.token.Type != END_OF_FILE
+}
+
+// Returns the n-th token following the current one without advancing,
+// Peek(1) is the next token. Past the end of the file it is END_OF_FILE.
+func (tokenizer *Tokenizer) Peek(n int) Token {
+	for len(tokenizer.lookahead) < n {
+		tokenizer.lookahead = append(tokenizer.lookahead, tokenizer.scan())
+	}
+	return tokenizer.lookahead[n-1]
+}
+
+// Returns the current token
+func (tokenizer *Tokenizer) Token() Token {
+	return tokenizer.token
+}
+
+// Returns the position of the current token
+func (tokenizer *Tokenizer) Position() diagnostics.Position {
+	return tokenizer.token.Position
+}
+
+// Returns the text of the current token, empty at the end of the file
+func (tokenizer *Tokenizer) GetText() string {
+	return tokenizer.token.Text
+}
+
+// Reads the next valid token, END_OF_FILE when the input is exhausted
+func (tokenizer *Tokenizer) scan() Token {
+	for {
+		tokenizer.skipSpaceAndComments()
+		position := tokenizer.position
+		c, ok := tokenizer.readByte()
+		if !ok {
+			return Token{Type: END_OF_FILE, Position: position}
+		}
+		var token Token
+		var valid bool
+		switch {
+		case isSymbolByte(c):
+			token, valid = tokenizer.scanSymbol(c), true
+		case c >= '0' && c <= '9':
+			token, valid = tokenizer.scanInteger(c, position)
+		case c == '"':
+			token, valid = tokenizer.scanString(position)
+		case c == '\'':
+			token, valid = tokenizer.scanCharacter(position)
+		case isIdentifierByte(c, 0):
+			token, valid = tokenizer.scanIdentifier(c), true
+		default:
+			tokenizer.Diagnostics.Add(diagnostics.Errorf(position, string(c), "invalid character %s", diagnostics.Quote(string(c))))
+		}
+		if valid {
+			token.Position = position
+			return token
+		}
+	}
+}
+
+// Skips the white space, the // comments up to the end of the line and the
+// /* */ and /** */ comments. Unterminated comments are reported.
+func (tokenizer *Tokenizer) skipSpaceAndComments() {
+	for {
+		c, ok := tokenizer.peekByte(0)
+		switch {
+		case !ok:
+			return
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			tokenizer.readByte()
+		case c == '/':
+			next, _ := tokenizer.peekByte(1)
+			if next == '/' {
+				for c, ok := tokenizer.readByte(); ok && c != '\n'; c, ok = tokenizer.readByte() {
+				}
+			} else if next == '*' {
+				position := tokenizer.position
+				tokenizer.readByte()
+				tokenizer.readByte()
+				if !tokenizer.skipBlockComment() {
+					tokenizer.Diagnostics.Add(diagnostics.Errorf(position, "/*", "unterminated comment"))
+				}
+			} else {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Skips the rest of the comment, returns false if the file ends before */
+func (tokenizer *Tokenizer) skipBlockComment() bool {
+	star := false
+	for {
+		c, ok := tokenizer.readByte()
+		if !ok {
+			return false
+		}
+		if star && c == '/' {
+			return true
+		}
+		star = c == '*'
+	}
+}
+
+// Reads the symbol starting with the byte, a single byte unless it starts
+// one of the symbols of the extended Jack
+func (tokenizer *Tokenizer) scanSymbol(first byte) Token {
+	if next, ok := tokenizer.peekByte(0); ok && tokenizer.extended {
+		if _, ok := extendedSymbols[string([]byte{first, next})]; ok {
+			tokenizer.readByte()
+			return Token{Type: SYMBOL, Text: string([]byte{first, next})}
+		}
+	}
+	return Token{Type: SYMBOL, Text: string(first)}
+}
+
+// Reads the integer constant starting with the digit, which has to be in the
+// range 0..32767. The constants starting with 0x are hexadecimal and with 0b
+// binary. Digits followed by letters are an invalid token.
+func (tokenizer *Tokenizer) scanInteger(first byte, position diagnostics.Position) (Token, bool) {
+	text := []byte{first}
+	base := 10
+	if c, ok := tokenizer.peekByte(0); ok && first == '0' && bases[c] != 0 {
+		base = bases[c]
+		text = append(text, tokenizer.mustReadByte())
+	}
+	for c, ok := tokenizer.peekByte(0); ok && isDigit(c, base); c, ok = tokenizer.peekByte(0) {
+		text = append(text, tokenizer.mustReadByte())
+	}
+	c, ok := tokenizer.peekByte(0)
+	if ok && isIdentifierByte(c, 1) || base != 10 && len(text) == 2 {
+		if ok && isIdentifierByte(c, 1) {
+			text = append(text, tokenizer.scanIdentifier(tokenizer.mustReadByte()).Text...)
+		}
+		tokenizer.Diagnostics.Add(diagnostics.Errorf(position, string(text), "invalid token %s", text))
+		return Token{}, false
+	}
+	if value, err := parseInteger(string(text), false); err != nil || value > 32767 {
+		tokenizer.Diagnostics.Add(diagnostics.Errorf(position, string(text), "integer constant %s out of range 0..32767", text))
+	}
+	return Token{Type: INT_CONST, Text: string(text)}, true
+}
+
+// Bases of the integer constants by the letter following their 0 prefix
+var bases = map[byte]int{'x': 16, 'X': 16, 'b': 2, 'B': 2}
+
+func isDigit(c byte, base int) bool {
+	switch base {
+	case 2:
+		return c == '0' || c == '1'
+	case 16:
+		return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+	}
+	return c >= '0' && c <= '9'
+}
+
+// Returns the value of the integer constant, which may be hexadecimal,
+// binary or a character constant as well, see unescape
+func parseInteger(text string, escaped bool) (int, error) {
+	if text[0] == '\'' {
+		return int(unescape(text, escaped)[0]), nil
+	}
+	if len(text) > 2 && bases[text[1]] != 0 {
+		value, err := strconv.ParseInt(text[2:], bases[text[1]], 32)
+		return int(value), err
+	}
+	return strconv.Atoi(text)
+}
+
+// Reads the string constant following the opening quote, which has to be
+// closed on the same line
+func (tokenizer *Tokenizer) scanString(position diagnostics.Position) (Token, bool) {
+	text, terminated := tokenizer.scanQuoted('"', position, "string constant")
+	return Token{Type: STRING_CONST, Text: text}, terminated
+}
+
+// Reads the character constant following the opening quote, e.g. 'A' or '\n',
+// which is an integer constant holding the code of the character
+func (tokenizer *Tokenizer) scanCharacter(position diagnostics.Position) (Token, bool) {
+	text, terminated := tokenizer.scanQuoted('\'', position, "character constant")
+	if terminated && len(unescape(text, tokenizer.extended)) != 1 {
+		tokenizer.Diagnostics.Add(diagnostics.Errorf(position, text, "character constant %s has to hold a single character", text))
+		return Token{}, false
+	}
+	return Token{Type: INT_CONST, Text: text}, terminated
+}
+
+// Characters of the escape sequences of the string and character constants,
+// \n and \b are the newline and the backspace of the Hack character set
+var escapes = map[byte]byte{
+	'n':  128,
+	'b':  129,
+	'"':  '"',
+	'\'': '\'',
+	'\\': '\\',
+}
+
+// Reads the string or character constant up to the closing quote, returns
+// its text including the quotes and false if it is not closed on the same
+// line. The characters have to be in the Hack character set, which the font
+// of Output covers, or be written as the escape sequences of the extended
+// Jack. In the standard Jack the backslash is an ordinary character.
+func (tokenizer *Tokenizer) scanQuoted(quote byte, position diagnostics.Position, kind string) (string, bool) {
+	text := []byte{quote}
+	reported := false
+	for {
+		characterPosition := tokenizer.position
+		c, ok := tokenizer.peekByte(0)
+		if !ok || c == '\n' {
+			tokenizer.Diagnostics.Add(diagnostics.Errorf(position, string(text), "unterminated %s", kind))
+			return string(text), false
+		}
+		text = append(text, tokenizer.mustReadByte())
+		switch {
+		case c == quote:
+			return string(text), true
+		case c == '\\' && tokenizer.extended:
+			// The backslash at the end of the line is reported as unterminated
+			if next, ok := tokenizer.peekByte(0); ok && next != '\n' {
+				text = append(text, tokenizer.mustReadByte())
+				if _, known := escapes[next]; !known {
+					sequence := string([]byte{c, next})
+					tokenizer.Diagnostics.Add(diagnostics.Errorf(characterPosition, sequence, "unknown escape sequence %s", sequence))
+				}
+			}
+		case (c < 32 || c > 126) && !reported:
+			// Only the first one is reported, not each byte of a UTF-8 character
+			tokenizer.Diagnostics.Add(diagnostics.Errorf(characterPosition, "", "character %d is not in the Hack character set", c))
+			reported = true
+		}
+	}
+}
+
+// Returns the characters of the string or character constant without the
+// quotes, with the escape sequences replaced by their characters if escaped
+func unescape(text string, escaped bool) string {
+	if !escaped {
+		return text[1 : len(text)-1]
+	}
+	var characters []byte
+	for i := 1; i < len(text)-1; i++ {
+		if text[i] == '\\' {
+			i++
+			characters = append(characters, escapes[text[i]])
+		} else {
+			characters = append(characters, text[i])
+		}
+	}
+	return string(characters)
+}
+
+// Reads the identifier or the keyword starting with the byte
+func (tokenizer *Tokenizer) scanIdentifier(first byte) Token {
+	text := tokenizer.readWhile(first, func(c byte) bool { return isIdentifierByte(c, 1) })
+	if _, ok := extendedKeywords[text]; isKeyword(text) || ok && tokenizer.extended {
+		return Token{Type: KEYWORD, Text: text}
+	}
+	return Token{Type: IDENTIFIER, Text: text}
+}
+
+// Returns the first byte followed by the bytes of the input for which accept returns true
+func (tokenizer *Tokenizer) readWhile(first byte, accept func(byte) bool) string {
+	text := []byte{first}
+	for c, ok := tokenizer.peekByte(0); ok && accept(c); c, ok = tokenizer.peekByte(0) {
+		text = append(text, tokenizer.mustReadByte())
+	}
+	return string(text)
+}
+
+// Reads the next byte of the input and moves the position past it,
+// returns false at the end of the input
+func (tokenizer *Tokenizer) readByte() (byte, bool) {
+	c, err := tokenizer.reader.ReadByte()
+	if err != nil {
+		tokenizer.addReadError(err)
+		return 0, false
+	}
+	if c == '\n' {
+		tokenizer.position.Line++
+		tokenizer.position.Column = 1
+	} else {
+		tokenizer.position.Column++
+	}
+	return c, true
+}
+
+// Reads the byte which has been peeked already
+func (tokenizer *Tokenizer) mustReadByte() byte {
+	c, _ := tokenizer.readByte()
+	return c
+}
+
+// Returns the i-th byte following the position without reading it
+func (tokenizer *Tokenizer) peekByte(i int) (byte, bool) {
+	data, err := tokenizer.reader.Peek(i + 1)
+	if len(data) <= i {
+		tokenizer.addReadError(err)
+		return 0, false
+	}
+	return data[i], true
+}
+
+// Records the first error of the reader, the end of the input is not an error
+func (tokenizer *Tokenizer) addReadError(err error) {
+	if err != nil && err != io.EOF && tokenizer.readError == nil {
+		tokenizer.readError = err
+		tokenizer.Diagnostics.Add(&diagnostics.Diagnostic{Position: tokenizer.position, Message: err.Error()})
+	}
+}
+
+// Returns true if the token is one of the symbols
+func (token Token) isSymbol(symbols ...Symbol) bool {
+	if token.Type != SYMBOL {
+		return false
+	}
+	for _, symbol := range symbols {
+		if getSymbolText(symbol) == token.Text {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the type of current token as it is named in the XML files
+func (tokenizer *Tokenizer) GetTokenString() string {
+	return tokenTypeStrings[tokenizer.token.Type]
+}
+
+var tokenTypeStrings = map[TokenType]string{
+	KEYWORD:      "keyword",
+	SYMBOL:       "symbol",
+	IDENTIFIER:   "identifier",
+	INT_CONST:    "integerConstant",
+	STRING_CONST: "stringConstant",
+	END_OF_FILE:  "end of file",
+}
+
+// Returns the type of current token
+func (tokenizer *Tokenizer) GetTokenType() TokenType {
+	return tokenizer.token.Type
+}
+
+func (tokenizer *Tokenizer) GetKeyword() Keyword {
+	if keyword, ok := extendedKeywords[tokenizer.token.Text]; ok {
+		return keyword
+	}
+	return keywords[tokenizer.token.Text]
+}
+
+func (tokenizer *Tokenizer) GetSymbol() Symbol {
+	if len(tokenizer.token.Text) > 1 {
+		return extendedSymbols[tokenizer.token.Text]
+	}
+	return symbols[tokenizer.token.Text[0]]
+}
+
+func (tokenizer *Tokenizer) GetIdentifier() string {
+	return tokenizer.token.Text
+}
+
+func (tokenizer *Tokenizer) GetIntegerValue() int {
+	num, _ := parseInteger(tokenizer.token.Text, tokenizer.extended)
+	return num
+}
+
+// Returns the characters of the string constant, see unescape
+func (tokenizer *Tokenizer) GetStringValue() string {
+	return unescape(tokenizer.token.Text, tokenizer.extended)
+}
+
+// Returns the text of the keyword, used by the diagnostics
+func getKeywordText(keyword Keyword) string {
+	for text, k := range keywords {
+		if k == keyword {
+			return text
+		}
+	}
+	for text, k := range extendedKeywords {
+		if k == keyword {
+			return text
+		}
+	}
+	return ""
+}
+
+// Returns the text of the symbol, used by the diagnostics
+func getSymbolText(symbol Symbol) string {
+	for c, s := range symbols {
+		if s == symbol {
+			return string(c)
+		}
+	}
+	for text, s := range extendedSymbols {
+		if s == symbol {
+			return text
+		}
+	}
+	return ""
+}
+
+func isKeyword(text string) bool {
+	_, ok := keywords[text]
+	return ok
+}
+
+func isSymbolByte(c byte) bool {
+	_, ok := symbols[c]
+	return ok
+}
+
+// Letters and underscores, digits as well except at the start of the identifier
+func isIdentifierByte(c byte, index int) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || index > 0 && c >= '0' && c <= '9'
+}
@@ -0,0 +1,94 @@
+package jackc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Returns the tokens of the code as "type value", the values of the integer and
+// string constants are the results of GetIntegerValue and GetStringValue
+func tokenize(code string, extended bool) ([]string, string) {
+	tokenizer := NewTokenizer(strings.NewReader(code), "Main.jack")
+	tokenizer.extended = extended
+	var tokens []string
+	for tokenizer.Advance() {
+		value := tokenizer.GetText()
+		switch tokenizer.GetTokenType() {
+		case INT_CONST:
+			value = fmt.Sprint(tokenizer.GetIntegerValue())
+		case STRING_CONST:
+			value = fmt.Sprintf("%q", tokenizer.GetStringValue())
+		}
+		tokens = append(tokens, tokenizer.GetTokenString()+" "+value)
+	}
+	return tokens, tokenizer.Diagnostics.Error()
+}
+
+// Checks the tokens and the values of the constants, the escape sequences
+// and the symbols of the extended Jack are read only with the extension
+func TestTokenizer(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		code     string
+		extended bool
+		expected []string
+	}{
+		{"statement", "let x=a[1]+y;", false, []string{
+			"keyword let", "identifier x", "symbol =", "identifier a", "symbol [",
+			"integerConstant 1", "symbol ]", "symbol +", "identifier y", "symbol ;",
+		}},
+		{"comments", "/** doc\n * comment */ x // line\n/* a */y", false, []string{"identifier x", "identifier y"}},
+		{"constants", `0x7FFF 0b101 'A' "a b"`, false, []string{
+			"integerConstant 32767", "integerConstant 5", "integerConstant 65", `stringConstant "a b"`,
+		}},
+		{"backslash", `"a\nb" '\'`, false, []string{`stringConstant "a\\nb"`, "integerConstant 92"}},
+		{"escapes", `"a\nb\"\\" '\'' '\b'`, true, []string{
+			`stringConstant "a\x80b\"\\"`, "integerConstant 39", "integerConstant 129",
+		}},
+		{"standard symbols", "i+=1 i--", false, []string{
+			"identifier i", "symbol +", "symbol =", "integerConstant 1", "identifier i", "symbol -", "symbol -",
+		}},
+		{"extended symbols", "i+=1 i--", true, []string{
+			"identifier i", "symbol +=", "integerConstant 1", "identifier i", "symbol --",
+		}},
+		{"extended keywords", "for break", false, []string{"identifier for", "identifier break"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, errors := tokenize(test.code, test.extended)
+			if errors != "" {
+				t.Fatal(errors)
+			}
+			if strings.Join(tokens, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected\n%s\nfound\n%s", strings.Join(test.expected, "\n"), strings.Join(tokens, "\n"))
+			}
+		})
+	}
+}
+
+// Checks the invalid tokens, reported at their position
+func TestTokenizerErrors(t *testing.T) {
+	for _, test := range []struct {
+		code     string
+		extended bool
+		expected string
+	}{
+		{"x = 32768;", false, "Main.jack:1:5: integer constant 32768 out of range 0..32767"},
+		{"0x10000", false, "Main.jack:1:1: integer constant 0x10000 out of range 0..32767"},
+		{"0x", false, "Main.jack:1:1: invalid token 0x"},
+		{"12ab", false, "Main.jack:1:1: invalid token 12ab"},
+		{"x # y", false, "Main.jack:1:3: invalid character '#'"},
+		{`"abc`, false, "Main.jack:1:1: unterminated string constant"},
+		{"'ab'", false, "Main.jack:1:1: character constant 'ab' has to hold a single character"},
+		{`'\n'`, false, `Main.jack:1:1: character constant '\n' has to hold a single character`},
+		{`"a\qb"`, true, `Main.jack:1:3: unknown escape sequence \q`},
+		{"x /* y", false, "Main.jack:1:3: unterminated comment"},
+		{"\"café\"", false, "Main.jack:1:5: character 195 is not in the Hack character set"},
+	} {
+		t.Run(test.code, func(t *testing.T) {
+			if _, errors := tokenize(test.code, test.extended); errors != test.expected {
+				t.Errorf("expected %s, found %s", test.expected, errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package jackc
+
+import (
+	"fmt"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Checks the types of the class resolved by the Analyzer. The conversions
+// which Jack programs commonly rely on, such as int to boolean or an address
+// to an object, are warnings unless strict, mixing the classes is an error.
+type TypeChecker struct {
+	diagnostics diagnostics.List
+	strict      bool
+	class       *Class
+	subroutine  *Subroutine
+}
+
+type compatibility int
+
+const (
+	COMPATIBLE   compatibility = iota
+	CONVERTIBLE  compatibility = iota
+	INCOMPATIBLE compatibility = iota
+)
+
+// Types of the expressions besides the types of the variables
+const (
+	// Type of the expressions which are not known, e.g. array entries
+	UNKNOWN_TYPE = ""
+	NULL_TYPE    = "null"
+	VOID_TYPE    = "void"
+)
+
+// Creates the type checker, the strict one reports the warnings as errors
+func NewTypeChecker(strict bool) *TypeChecker {
+	return &TypeChecker{strict: strict}
+}
+
+// Checks the class and returns the errors and the warnings sorted by their position
+func (typeChecker *TypeChecker) CheckClass(class *Class) diagnostics.List {
+	typeChecker.diagnostics = nil
+	typeChecker.class = class
+	for _, subroutine := range class.Subroutines {
+		typeChecker.subroutine = subroutine
+		typeChecker.checkStatements(subroutine.Statements)
+		if !terminates(subroutine.Statements) {
+			message := fmt.Sprintf("missing return at the end of %s", subroutine.Name)
+			if subroutine.ReturnType == VOID_TYPE {
+				typeChecker.diagnostics.Add(typeChecker.warning(diagnostics.Errorf(subroutine.End, "}", message)))
+			} else {
+				typeChecker.diagnostics.Add(diagnostics.Errorf(subroutine.End, "}", message))
+			}
+		}
+	}
+	sortDiagnostics(typeChecker.diagnostics)
+	return typeChecker.diagnostics
+}
+
+// Returns true if the last statement returns in every branch or is a loop
+// which is never left, e.g. while (true) without break
+func terminates(statements []Statement) bool {
+	if len(statements) == 0 {
+		return false
+	}
+	switch statement := statements[len(statements)-1].(type) {
+	case *ReturnStatement:
+		return true
+	case *IfStatement:
+		return statement.HasElse && terminates(statement.Then) && terminates(statement.Else)
+	case *WhileStatement:
+		return isAlwaysTrue(statement.Condition) && !breaks(statement.Body)
+	case *ForStatement:
+		return (statement.Condition == nil || isAlwaysTrue(statement.Condition)) && !breaks(statement.Body)
+	}
+	return false
+}
+
+// Returns true if the condition is true or a constant other than 0
+func isAlwaysTrue(condition *Expression) bool {
+	switch condition.Kind {
+	case KEYWORD_EXPRESSION:
+		return condition.Keyword == TRUE
+	case CONSTANT_EXPRESSION:
+		return condition.Value != 0
+	case PARENTHESES_EXPRESSION:
+		return isAlwaysTrue(condition.Operands[0])
+	}
+	return false
+}
+
+// Returns true if the statements break the loop containing them,
+// the breaks of the nested loops leave only those loops
+func breaks(statements []Statement) bool {
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *BreakStatement:
+			return true
+		case *IfStatement:
+			if breaks(statement.Then) || breaks(statement.Else) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (typeChecker *TypeChecker) checkStatements(statements []Statement) {
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *LetStatement:
+			targetType := typeChecker.getType(statement.Target)
+			valueType := typeChecker.getType(statement.Value)
+			typeChecker.checkConversion(statement.Value, valueType, targetType, "the assignment to "+statement.Target.Name)
+		case *IfStatement:
+			typeChecker.getType(statement.Condition)
+			typeChecker.checkStatements(statement.Then)
+			typeChecker.checkStatements(statement.Else)
+		case *WhileStatement:
+			typeChecker.getType(statement.Condition)
+			typeChecker.checkStatements(statement.Body)
+		case *ForStatement:
+			typeChecker.checkStatements(statement.Init)
+			if statement.Condition != nil {
+				typeChecker.getType(statement.Condition)
+			}
+			typeChecker.checkStatements(statement.Step)
+			typeChecker.checkStatements(statement.Body)
+		case *DoStatement:
+			typeChecker.getType(statement.Call)
+		case *ReturnStatement:
+			typeChecker.checkReturn(statement)
+		}
+	}
+}
+
+func (typeChecker *TypeChecker) checkReturn(statement *ReturnStatement) {
+	subroutine := typeChecker.subroutine
+	if statement.Value == nil {
+		if subroutine.ReturnType != VOID_TYPE {
+			typeChecker.diagnostics.Add(diagnostics.Errorf(statement.Position, "return", "%s has to return %s", subroutine.Name, subroutine.ReturnType))
+		}
+		return
+	}
+	valueType := typeChecker.getType(statement.Value)
+	if subroutine.ReturnType == VOID_TYPE {
+		typeChecker.diagnostics.Add(diagnostics.Errorf(statement.Value.Position, "return", "void %s returns a value", subroutine.Name))
+		return
+	}
+	typeChecker.checkConversion(statement.Value, valueType, subroutine.ReturnType, "the return value of "+subroutine.Name)
+}
+
+// Returns the type of the expression and checks the calls and the variables in it
+func (typeChecker *TypeChecker) getType(node *Expression) string {
+	operandTypes := make([]string, len(node.Operands))
+	for i, operand := range node.Operands {
+		operandTypes[i] = typeChecker.getType(operand)
+	}
+	switch node.Kind {
+	case CONSTANT_EXPRESSION:
+		return "int"
+	case KEYWORD_EXPRESSION:
+		switch node.Keyword {
+		case TRUE, FALSE:
+			return "boolean"
+		case NULL:
+			return NULL_TYPE
+		}
+		if typeChecker.subroutine.Kind == FUNCTION {
+			typeChecker.diagnostics.Add(diagnostics.Errorf(node.Position, "this", "this used in function %s", typeChecker.subroutine.Name))
+		}
+		return typeChecker.class.Name
+	case STRING_EXPRESSION:
+		return "String"
+	case VARIABLE_EXPRESSION, ARRAY_EXPRESSION:
+		if node.segment == FIELD && typeChecker.subroutine.Kind == FUNCTION {
+			typeChecker.diagnostics.Add(diagnostics.Errorf(node.Position, node.Name, "field %s used in function %s", node.Name, typeChecker.subroutine.Name))
+		}
+		if node.Kind == ARRAY_EXPRESSION {
+			return UNKNOWN_TYPE
+		}
+		return node.variableType
+	case CALL_EXPRESSION:
+		return typeChecker.checkCall(node, operandTypes)
+	case UNARY_EXPRESSION:
+		if node.Operator == NOT && operandTypes[0] == "boolean" {
+			return "boolean"
+		}
+		return "int"
+	case BINARY_EXPRESSION:
+		switch node.Operator {
+		case LESS, GREATER, EQUAL:
+			return "boolean"
+		case AND, OR:
+			if operandTypes[0] == "boolean" && operandTypes[1] == "boolean" {
+				return "boolean"
+			}
+		}
+		return "int"
+	case PARENTHESES_EXPRESSION:
+		return operandTypes[0]
+	}
+	return UNKNOWN_TYPE
+}
+
+// Checks that the methods are called on objects and the functions and
+// constructors through their class, and the types of the arguments.
+// Returns the type of the returned value.
+func (typeChecker *TypeChecker) checkCall(call *Expression, argumentTypes []string) string {
+	if call.receiver != nil && call.receiver.Kind == VARIABLE_EXPRESSION {
+		typeChecker.getType(call.receiver)
+	}
+	subroutine := call.subroutine
+	if subroutine == nil {
+		return UNKNOWN_TYPE
+	}
+	switch {
+	case call.receiver == nil && subroutine.Kind == METHOD:
+		typeChecker.diagnostics.Add(diagnostics.Errorf(call.Position, call.function, "method %s called without an object", call.function))
+	case call.receiver != nil && subroutine.Kind != METHOD:
+		typeChecker.diagnostics.Add(diagnostics.Errorf(call.Position, call.function, "%s %s called as a method, call it through its class", getKeywordText(subroutine.Kind), call.function))
+	case call.Qualifier == "" && typeChecker.subroutine.Kind == FUNCTION:
+		typeChecker.diagnostics.Add(diagnostics.Errorf(call.Position, call.function, "method %s called from function %s without an object", call.function, typeChecker.subroutine.Name))
+	}
+	i := 0
+	for _, declaration := range subroutine.Parameters {
+		for range declaration.Names {
+			if i < len(argumentTypes) {
+				context := fmt.Sprintf("argument %d of %s", i+1, call.function)
+				typeChecker.checkConversion(call.Operands[i], argumentTypes[i], declaration.Type, context)
+			}
+			i++
+		}
+	}
+	return subroutine.ReturnType
+}
+
+// Reports the value which cannot be converted to the target type
+func (typeChecker *TypeChecker) checkConversion(value *Expression, valueType, targetType, context string) {
+	compatibility := getCompatibility(valueType, targetType)
+	if compatibility == COMPATIBLE {
+		return
+	}
+	found := valueType
+	if valueType == VOID_TYPE {
+		found = "no value"
+	}
+	diagnostic := diagnostics.Expected(value.Position, "", targetType+" in "+context, found)
+	if compatibility == CONVERTIBLE {
+		diagnostic = typeChecker.warning(diagnostic)
+	}
+	typeChecker.diagnostics.Add(diagnostic)
+}
+
+// Turns the diagnostic into a warning unless the checker is strict
+func (typeChecker *TypeChecker) warning(diagnostic *diagnostics.Diagnostic) *diagnostics.Diagnostic {
+	diagnostic.Warning = !typeChecker.strict
+	return diagnostic
+}
+
+// The primitive types are convertible to each other and to the objects,
+// with an int holding an address, except int and char, which are compatible.
+// Arrays are the addresses of the memory, so they are compatible with int
+// and with all the objects, and so is null.
+func getCompatibility(valueType, targetType string) compatibility {
+	if valueType == VOID_TYPE {
+		return INCOMPATIBLE
+	}
+	if valueType == targetType || valueType == UNKNOWN_TYPE || targetType == UNKNOWN_TYPE || valueType == NULL_TYPE {
+		return COMPATIBLE
+	}
+	if valueType == "Array" && targetType == "int" || valueType == "int" && targetType == "Array" {
+		return COMPATIBLE
+	}
+	isValuePrimitive, isTargetPrimitive := isPrimitive(valueType), isPrimitive(targetType)
+	if isValuePrimitive && isTargetPrimitive {
+		if valueType == "boolean" || targetType == "boolean" {
+			return CONVERTIBLE
+		}
+		return COMPATIBLE
+	}
+	if isValuePrimitive || isTargetPrimitive {
+		return CONVERTIBLE
+	}
+	if valueType == "Array" || targetType == "Array" {
+		return COMPATIBLE
+	}
+	return INCOMPATIBLE
+}
+
+func isPrimitive(typeName string) bool {
+	return typeName == "int" || typeName == "char" || typeName == "boolean"
+}
@@ -0,0 +1,139 @@
+package jackc
+
+import (
+	"testing"
+)
+
+// Checks the returns, the types and the uses of the objects
+func TestTypeChecker(t *testing.T) {
+	checkDiagnostics(t, []diagnosticsTest{
+		{"return", `class Main {
+    function void main() {
+        do Main.f(1);
+        return;
+    }
+    function int f(int x) {
+        if (x > 0) {
+            return x;
+        } else {
+            return 0;
+        }
+    }
+}`, Options{}, nil},
+		{"missing return", `class Main {
+    function void main() {
+        do Main.f(1);
+        return;
+    }
+    function int f(int x) {
+        if (x > 0) {
+            return x;
+        }
+    }
+}`, Options{}, []string{"Main.jack:10:5: missing return at the end of f"}},
+		{"missing void return", `class Main {
+    function void main() {
+        do Output.printInt(1);
+    }
+}`, Options{}, []string{"Main.jack:4:5: warning: missing return at the end of main"}},
+		{"missing void return strict", `class Main {
+    function void main() {
+        do Output.printInt(1);
+    }
+}`, Options{Strict: true}, []string{"Main.jack:4:5: missing return at the end of main"}},
+		{"endless while", `class Main {
+    function void main() {
+        do Main.f();
+        return;
+    }
+    function int f() {
+        while (true) {
+            return 1;
+        }
+    }
+}`, Options{}, nil},
+		{"while with break", `class Main {
+    function void main() {
+        do Main.f();
+        return;
+    }
+    function int f() {
+        while (true) {
+            if (Keyboard.keyPressed() = 0) {
+                break;
+            }
+        }
+    }
+}`, Options{Extended: true}, []string{"Main.jack:12:5: missing return at the end of f"}},
+		{"endless for", `class Main {
+    function void main() {
+        do Main.f();
+        return;
+    }
+    function int f() {
+        for (;;) {
+            while (true) {
+                break;
+            }
+        }
+    }
+}`, Options{Extended: true}, nil},
+		{"conditional while", `class Main {
+    function void main() {
+        do Main.f(1);
+        return;
+    }
+    function int f(int x) {
+        while (x > 0) {
+            return x;
+        }
+    }
+}`, Options{}, []string{"Main.jack:10:5: missing return at the end of f"}},
+		{"return types", `class Main {
+    function void main() {
+        do Main.f();
+        return 1;
+    }
+    function int f() {
+        return;
+    }
+}`, Options{}, []string{
+			"Main.jack:4:16: void main returns a value",
+			"Main.jack:7:9: f has to return int",
+		}},
+		{"argument types", `class Main {
+    function void main() {
+        var String s;
+        let s = Main.f(true, s);
+        return;
+    }
+    function String f(int x, int y) {
+        return y;
+    }
+}`, Options{}, []string{
+			"Main.jack:4:24: warning: expected int in argument 1 of Main.f, found boolean",
+			"Main.jack:4:30: warning: expected int in argument 2 of Main.f, found String",
+			"Main.jack:8:16: warning: expected String in the return value of f, found int",
+		}},
+		{"methods and fields", `class Main {
+    field int x;
+    function void main() {
+        let x = 1;
+        do Main.m();
+        do Memory.deAlloc(this);
+        return;
+    }
+    method void m() {
+        do Main.main();
+        do main();
+        return;
+    }
+}`, Options{}, []string{
+			"Main.jack:2:15: warning: field x is never read",
+			"Main.jack:4:13: field x used in function main",
+			"Main.jack:5:12: method Main.m called without an object",
+			"Main.jack:6:27: this used in function main",
+			"Main.jack:11:12: function Main.main called as a method, call it through its class",
+		}},
+	})
+}
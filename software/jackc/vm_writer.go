@@ -1,26 +1,16 @@
-package main
+package jackc
 
 import (
-	"fmt"
-	"os"
+	"io"
 	"strconv"
 )
 
 type VMWriter struct {
-	file *os.File
+	writer io.Writer
 }
 
-func NewVMWriter(fileName string) *VMWriter {
-	file, err := os.Create(fileName)
-	if err != nil {
-		fmt.Println("Could not save file", fileName)
-		os.Exit(1)
-	}
-	return &VMWriter{file: file}
-}
-
-func (vmWriter *VMWriter) Close() error {
-	return vmWriter.file.Close()
+func NewVMWriter(writer io.Writer) *VMWriter {
+	return &VMWriter{writer: writer}
 }
 
 func (vmWriter *VMWriter) WritePush(keyword Keyword, index int) {
@@ -32,10 +22,8 @@ func (vmWriter *VMWriter) WritePop(keyword Keyword, index int) {
 }
 
 func (vmWriter *VMWriter) WriteArithmetic(symbol Symbol) {
-	if symbol == DIVIDE {
-		vmWriter.WriteCall("Math.divide", 2)
-	} else if symbol == MULTIPLY {
-		vmWriter.WriteCall("Math.multiply", 2)
+	if function, ok := operatorFunctions[symbol]; ok {
+		vmWriter.WriteCall(function, 2)
 	} else {
 		vmWriter.writeln(sym[symbol])
 	}
@@ -66,7 +54,7 @@ func (vmWriter *VMWriter) WriteReturn() {
 }
 
 func (vmWriter *VMWriter) writeln(value string) {
-	vmWriter.file.WriteString(value + "\n")
+	io.WriteString(vmWriter.writer, value+"\n")
 }
 
 var segment = map[Keyword]string{
@@ -0,0 +1,297 @@
+package jackc
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writes the tokens and the parse trees in the XML format of the course,
+// Xxx.xml and XxxT.xml, which the reference outputs can be compared with
+type xmlWriter struct {
+	buffer bytes.Buffer
+	depth  int
+}
+
+var xmlEscaper = strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", "\"", "&quot;")
+
+// Writes the tokens of the Jack class read from the reader like XxxT.xml.
+// The errors of the tokenizer are returned, nothing is written if there are any.
+func WriteTokens(reader io.Reader, fileName string, writer io.Writer) error {
+	tokenizer := NewTokenizer(reader, fileName)
+	xmlWriter := &xmlWriter{}
+	xmlWriter.line("<tokens>")
+	for tokenizer.Advance() {
+		text := tokenizer.GetText()
+		if tokenizer.GetTokenType() == STRING_CONST {
+			// The escape sequences are written as they are in the code
+			text = text[1 : len(text)-1]
+		}
+		xmlWriter.token(tokenizer.GetTokenString(), text)
+	}
+	xmlWriter.line("</tokens>")
+	if err := tokenizer.Diagnostics.Err(); err != nil {
+		return err
+	}
+	_, err := writer.Write(xmlWriter.buffer.Bytes())
+	return err
+}
+
+// Writes the parse tree of the class like Xxx.xml. The class has to be
+// parsed without errors, the tree of a broken class lacks its broken parts.
+func WriteTree(class *Class, writer io.Writer) error {
+	xmlWriter := &xmlWriter{}
+	xmlWriter.writeClass(class)
+	_, err := writer.Write(xmlWriter.buffer.Bytes())
+	return err
+}
+
+func (xmlWriter *xmlWriter) writeClass(class *Class) {
+	xmlWriter.open("class")
+	xmlWriter.keyword(CLASS)
+	xmlWriter.identifier(class.Name)
+	xmlWriter.symbol(LEFT_CURLY)
+	for _, declaration := range class.Variables {
+		xmlWriter.open("classVarDec")
+		xmlWriter.keyword(declaration.Kind)
+		xmlWriter.writeDeclaration(declaration)
+		xmlWriter.close("classVarDec")
+	}
+	for _, subroutine := range class.Subroutines {
+		xmlWriter.writeSubroutine(subroutine)
+	}
+	xmlWriter.symbol(RIGHT_CURLY)
+	xmlWriter.close("class")
+}
+
+// Writes the type and the names separated by commas and ended by semicolon
+func (xmlWriter *xmlWriter) writeDeclaration(declaration *Declaration) {
+	xmlWriter.writeType(declaration.Type)
+	for i, name := range declaration.Names {
+		if i > 0 {
+			xmlWriter.symbol(COMMA)
+		}
+		xmlWriter.identifier(name.Text)
+	}
+	xmlWriter.symbol(SEMICOLON)
+}
+
+func (xmlWriter *xmlWriter) writeSubroutine(subroutine *Subroutine) {
+	xmlWriter.open("subroutineDec")
+	xmlWriter.keyword(subroutine.Kind)
+	xmlWriter.writeType(subroutine.ReturnType)
+	xmlWriter.identifier(subroutine.Name)
+	xmlWriter.symbol(LEFT_PARANTHESIS)
+	xmlWriter.open("parameterList")
+	for i, parameter := range subroutine.Parameters {
+		if i > 0 {
+			xmlWriter.symbol(COMMA)
+		}
+		xmlWriter.writeType(parameter.Type)
+		xmlWriter.identifier(parameter.Names[0].Text)
+	}
+	xmlWriter.close("parameterList")
+	xmlWriter.symbol(RIGHT_PARANTHESIS)
+	xmlWriter.open("subroutineBody")
+	xmlWriter.symbol(LEFT_CURLY)
+	for _, declaration := range subroutine.Locals {
+		xmlWriter.open("varDec")
+		xmlWriter.keyword(VAR)
+		xmlWriter.writeDeclaration(declaration)
+		xmlWriter.close("varDec")
+	}
+	xmlWriter.writeStatements(subroutine.Statements)
+	xmlWriter.symbol(RIGHT_CURLY)
+	xmlWriter.close("subroutineBody")
+	xmlWriter.close("subroutineDec")
+}
+
+func (xmlWriter *xmlWriter) writeStatements(statements []Statement) {
+	xmlWriter.open("statements")
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *LetStatement:
+			xmlWriter.open("letStatement")
+			xmlWriter.keyword(LET)
+			xmlWriter.identifier(statement.Target.Name)
+			if statement.Target.Kind == ARRAY_EXPRESSION {
+				xmlWriter.symbol(LEFT_BRACKET)
+				xmlWriter.writeExpression(statement.Target.Operands[0])
+				xmlWriter.symbol(RIGHT_BRACKET)
+			}
+			xmlWriter.symbol(EQUAL)
+			xmlWriter.writeExpression(statement.Value)
+			xmlWriter.symbol(SEMICOLON)
+			xmlWriter.close("letStatement")
+		case *IfStatement:
+			xmlWriter.open("ifStatement")
+			xmlWriter.keyword(IF)
+			xmlWriter.writeCondition(statement.Condition)
+			xmlWriter.writeBlock(statement.Then)
+			if statement.HasElse {
+				xmlWriter.keyword(ELSE)
+				xmlWriter.writeBlock(statement.Else)
+			}
+			xmlWriter.close("ifStatement")
+		case *WhileStatement:
+			xmlWriter.open("whileStatement")
+			xmlWriter.keyword(WHILE)
+			xmlWriter.writeCondition(statement.Condition)
+			xmlWriter.writeBlock(statement.Body)
+			xmlWriter.close("whileStatement")
+		case *DoStatement:
+			xmlWriter.open("doStatement")
+			xmlWriter.keyword(DO)
+			xmlWriter.writeCall(statement.Call)
+			xmlWriter.symbol(SEMICOLON)
+			xmlWriter.close("doStatement")
+		case *ReturnStatement:
+			xmlWriter.open("returnStatement")
+			xmlWriter.keyword(RETURN)
+			if statement.Value != nil {
+				xmlWriter.writeExpression(statement.Value)
+			}
+			xmlWriter.symbol(SEMICOLON)
+			xmlWriter.close("returnStatement")
+		}
+	}
+	xmlWriter.close("statements")
+}
+
+func (xmlWriter *xmlWriter) writeCondition(condition *Expression) {
+	xmlWriter.symbol(LEFT_PARANTHESIS)
+	xmlWriter.writeExpression(condition)
+	xmlWriter.symbol(RIGHT_PARANTHESIS)
+}
+
+func (xmlWriter *xmlWriter) writeBlock(statements []Statement) {
+	xmlWriter.symbol(LEFT_CURLY)
+	xmlWriter.writeStatements(statements)
+	xmlWriter.symbol(RIGHT_CURLY)
+}
+
+func (xmlWriter *xmlWriter) writeExpression(node *Expression) {
+	xmlWriter.open("expression")
+	xmlWriter.writeOperations(node)
+	xmlWriter.close("expression")
+}
+
+// Writes the terms of the expression separated by the binary operators,
+// the left operands of the binary expressions contain the preceding terms
+func (xmlWriter *xmlWriter) writeOperations(node *Expression) {
+	if node.Kind != BINARY_EXPRESSION {
+		xmlWriter.writeTerm(node)
+		return
+	}
+	xmlWriter.writeOperations(node.Operands[0])
+	xmlWriter.symbol(node.Operator)
+	xmlWriter.writeTerm(node.Operands[1])
+}
+
+func (xmlWriter *xmlWriter) writeTerm(node *Expression) {
+	xmlWriter.open("term")
+	switch node.Kind {
+	case CONSTANT_EXPRESSION:
+		xmlWriter.token("integerConstant", strconv.Itoa(node.Value))
+	case KEYWORD_EXPRESSION:
+		xmlWriter.keyword(node.Keyword)
+	case STRING_EXPRESSION:
+		xmlWriter.token("stringConstant", escape(node.Text))
+	case VARIABLE_EXPRESSION:
+		xmlWriter.identifier(node.Name)
+	case ARRAY_EXPRESSION:
+		xmlWriter.identifier(node.Name)
+		xmlWriter.symbol(LEFT_BRACKET)
+		xmlWriter.writeExpression(node.Operands[0])
+		xmlWriter.symbol(RIGHT_BRACKET)
+	case CALL_EXPRESSION:
+		xmlWriter.writeCall(node)
+	case PARENTHESES_EXPRESSION:
+		xmlWriter.writeCondition(node.Operands[0])
+	case UNARY_EXPRESSION:
+		if node.Operator == NEG {
+			xmlWriter.symbol(MINUS)
+		} else {
+			xmlWriter.symbol(node.Operator)
+		}
+		xmlWriter.writeTerm(node.Operands[0])
+	}
+	xmlWriter.close("term")
+}
+
+func (xmlWriter *xmlWriter) writeCall(call *Expression) {
+	if call.Qualifier != "" {
+		xmlWriter.identifier(call.Qualifier)
+		xmlWriter.symbol(DOT)
+	}
+	xmlWriter.identifier(call.Name)
+	xmlWriter.symbol(LEFT_PARANTHESIS)
+	xmlWriter.open("expressionList")
+	for i, argument := range call.Operands {
+		if i > 0 {
+			xmlWriter.symbol(COMMA)
+		}
+		xmlWriter.writeExpression(argument)
+	}
+	xmlWriter.close("expressionList")
+	xmlWriter.symbol(RIGHT_PARANTHESIS)
+}
+
+// Returns the characters of the string constant written as in the code,
+// with the escape sequences
+func escape(characters string) string {
+	var text []byte
+	for i := 0; i < len(characters); i++ {
+		c := characters[i]
+		for sequence, character := range escapes {
+			if c == character && sequence != '\'' {
+				text = append(text, '\\')
+				c = sequence
+				break
+			}
+		}
+		text = append(text, c)
+	}
+	return string(text)
+}
+
+// Types are keywords except the class names
+func (xmlWriter *xmlWriter) writeType(typeName string) {
+	if isPrimitive(typeName) || typeName == VOID_TYPE {
+		xmlWriter.token("keyword", typeName)
+	} else {
+		xmlWriter.identifier(typeName)
+	}
+}
+
+func (xmlWriter *xmlWriter) keyword(keyword Keyword) {
+	xmlWriter.token("keyword", getKeywordText(keyword))
+}
+
+func (xmlWriter *xmlWriter) symbol(symbol Symbol) {
+	xmlWriter.token("symbol", getSymbolText(symbol))
+}
+
+func (xmlWriter *xmlWriter) identifier(name string) {
+	xmlWriter.token("identifier", name)
+}
+
+// Writes the terminal element, e.g. <symbol> &lt; </symbol>
+func (xmlWriter *xmlWriter) token(tag, text string) {
+	xmlWriter.line("<" + tag + "> " + xmlEscaper.Replace(text) + " </" + tag + ">")
+}
+
+func (xmlWriter *xmlWriter) open(tag string) {
+	xmlWriter.line("<" + tag + ">")
+	xmlWriter.depth++
+}
+
+func (xmlWriter *xmlWriter) close(tag string) {
+	xmlWriter.depth--
+	xmlWriter.line("</" + tag + ">")
+}
+
+func (xmlWriter *xmlWriter) line(text string) {
+	xmlWriter.buffer.WriteString(strings.Repeat("  ", xmlWriter.depth) + text + "\n")
+}
@@ -0,0 +1,199 @@
+package jackc
+
+import (
+	"strings"
+	"testing"
+)
+
+const XML_CLASS = `class Main {
+    field int x;
+    method void f(int a, Array b) {
+        var char c;
+        let b[x] = -a < 5;
+        if (~(x = 0)) {
+            do Output.printString("a&\"b\"");
+        } else {
+            do f(1, null);
+        }
+        return;
+    }
+}
+`
+
+// Checks the tokens written like XxxT.xml
+func TestWriteTokens(t *testing.T) {
+	var output strings.Builder
+	if err := WriteTokens(strings.NewReader(`let s[i] = "<a>" & 1;`), "Main.jack", &output); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<tokens>
+<keyword> let </keyword>
+<identifier> s </identifier>
+<symbol> [ </symbol>
+<identifier> i </identifier>
+<symbol> ] </symbol>
+<symbol> = </symbol>
+<stringConstant> &lt;a&gt; </stringConstant>
+<symbol> &amp; </symbol>
+<integerConstant> 1 </integerConstant>
+<symbol> ; </symbol>
+</tokens>
+`
+	if output.String() != expected {
+		t.Errorf("expected\n%s\nfound\n%s", expected, output.String())
+	}
+}
+
+// Checks the parse tree written like Xxx.xml, the string constants
+// keep their escape sequences
+func TestWriteTree(t *testing.T) {
+	parser := NewParser(strings.NewReader(XML_CLASS), "Main.jack")
+	parser.SetExtended(true)
+	class, err := parser.ParseClass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	if err := WriteTree(class, &output); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<class>
+  <keyword> class </keyword>
+  <identifier> Main </identifier>
+  <symbol> { </symbol>
+  <classVarDec>
+    <keyword> field </keyword>
+    <keyword> int </keyword>
+    <identifier> x </identifier>
+    <symbol> ; </symbol>
+  </classVarDec>
+  <subroutineDec>
+    <keyword> method </keyword>
+    <keyword> void </keyword>
+    <identifier> f </identifier>
+    <symbol> ( </symbol>
+    <parameterList>
+      <keyword> int </keyword>
+      <identifier> a </identifier>
+      <symbol> , </symbol>
+      <identifier> Array </identifier>
+      <identifier> b </identifier>
+    </parameterList>
+    <symbol> ) </symbol>
+    <subroutineBody>
+      <symbol> { </symbol>
+      <varDec>
+        <keyword> var </keyword>
+        <keyword> char </keyword>
+        <identifier> c </identifier>
+        <symbol> ; </symbol>
+      </varDec>
+      <statements>
+        <letStatement>
+          <keyword> let </keyword>
+          <identifier> b </identifier>
+          <symbol> [ </symbol>
+          <expression>
+            <term>
+              <identifier> x </identifier>
+            </term>
+          </expression>
+          <symbol> ] </symbol>
+          <symbol> = </symbol>
+          <expression>
+            <term>
+              <symbol> - </symbol>
+              <term>
+                <identifier> a </identifier>
+              </term>
+            </term>
+            <symbol> &lt; </symbol>
+            <term>
+              <integerConstant> 5 </integerConstant>
+            </term>
+          </expression>
+          <symbol> ; </symbol>
+        </letStatement>
+        <ifStatement>
+          <keyword> if </keyword>
+          <symbol> ( </symbol>
+          <expression>
+            <term>
+              <symbol> ~ </symbol>
+              <term>
+                <symbol> ( </symbol>
+                <expression>
+                  <term>
+                    <identifier> x </identifier>
+                  </term>
+                  <symbol> = </symbol>
+                  <term>
+                    <integerConstant> 0 </integerConstant>
+                  </term>
+                </expression>
+                <symbol> ) </symbol>
+              </term>
+            </term>
+          </expression>
+          <symbol> ) </symbol>
+          <symbol> { </symbol>
+          <statements>
+            <doStatement>
+              <keyword> do </keyword>
+              <identifier> Output </identifier>
+              <symbol> . </symbol>
+              <identifier> printString </identifier>
+              <symbol> ( </symbol>
+              <expressionList>
+                <expression>
+                  <term>
+                    <stringConstant> a&amp;\&quot;b\&quot; </stringConstant>
+                  </term>
+                </expression>
+              </expressionList>
+              <symbol> ) </symbol>
+              <symbol> ; </symbol>
+            </doStatement>
+          </statements>
+          <symbol> } </symbol>
+          <keyword> else </keyword>
+          <symbol> { </symbol>
+          <statements>
+            <doStatement>
+              <keyword> do </keyword>
+              <identifier> f </identifier>
+              <symbol> ( </symbol>
+              <expressionList>
+                <expression>
+                  <term>
+                    <integerConstant> 1 </integerConstant>
+                  </term>
+                </expression>
+                <symbol> , </symbol>
+                <expression>
+                  <term>
+                    <keyword> null </keyword>
+                  </term>
+                </expression>
+              </expressionList>
+              <symbol> ) </symbol>
+              <symbol> ; </symbol>
+            </doStatement>
+          </statements>
+          <symbol> } </symbol>
+        </ifStatement>
+        <returnStatement>
+          <keyword> return </keyword>
+          <symbol> ; </symbol>
+        </returnStatement>
+      </statements>
+      <symbol> } </symbol>
+    </subroutineBody>
+  </subroutineDec>
+  <symbol> } </symbol>
+</class>
+`
+	if output.String() != expected {
+		t.Errorf("expected\n%s\nfound\n%s", expected, output.String())
+	}
+}
@@ -0,0 +1,11 @@
+// Package jackos provides the sources of the Jack operating system,
+// so that the tools can include them without knowing where the
+// repository is checked out.
+package jackos
+
+import "embed"
+
+// The .jack files of the OS classes, e.g. Math.jack
+//
+//go:embed *.jack
+var Files embed.FS
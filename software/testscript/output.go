@@ -0,0 +1,102 @@
+package testscript
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column of the output table given as name%F<left>.<width>.<right>, where F is
+// B (binary), D (decimal), X (hexadecimal) or S (string). The value is padded
+// to width characters and surrounded by left and right spaces.
+type column struct {
+	name   string
+	format byte
+	left   int
+	width  int
+	right  int
+}
+
+func parseColumn(spec string) (column, error) {
+	idx := strings.LastIndex(spec, "%")
+	if idx <= 0 || idx+1 == len(spec) {
+		return column{}, fmt.Errorf("invalid output column %s, expected name%%F<left>.<width>.<right>", spec)
+	}
+	result := column{name: spec[:idx], format: spec[idx+1]}
+	if strings.IndexByte("BDXS", result.format) == -1 {
+		return column{}, fmt.Errorf("invalid format %%%c of output column %s", result.format, result.name)
+	}
+	sizes := strings.Split(spec[idx+2:], ".")
+	if len(sizes) != 3 {
+		return column{}, fmt.Errorf("invalid output column %s, expected name%%F<left>.<width>.<right>", spec)
+	}
+	for i, size := range []*int{&result.left, &result.width, &result.right} {
+		value, err := strconv.Atoi(sizes[i])
+		if err != nil || value < 0 {
+			return column{}, fmt.Errorf("invalid size %s of output column %s", sizes[i], result.name)
+		}
+		*size = value
+	}
+	if result.width == 0 {
+		return column{}, fmt.Errorf("output column %s has no width", result.name)
+	}
+	return result, nil
+}
+
+// Total number of characters of the column, without the separator
+func (column column) size() int {
+	return column.left + column.width + column.right
+}
+
+// Name of the column centered in the column, truncated if it is too long
+func (column column) header() string {
+	size := column.size()
+	if len(column.name) >= size {
+		return column.name[:size]
+	}
+	left := (size - len(column.name)) / 2
+	return strings.Repeat(" ", left) + column.name + strings.Repeat(" ", size-left-len(column.name))
+}
+
+// Formats the value of a 16-bit variable, strings are aligned to the left
+// and numbers to the right
+func (column column) formatValue(value int) string {
+	var text string
+	switch column.format {
+	case 'B':
+		text = strconv.FormatInt(int64(value&0xFFFF), 2)
+		text = strings.Repeat("0", max(column.width-len(text), 0)) + text
+	case 'X':
+		text = strings.ToUpper(strconv.FormatInt(int64(value&0xFFFF), 16))
+		text = strings.Repeat("0", max(column.width-len(text), 0)) + text
+	default:
+		text = strconv.Itoa(int(int16(value)))
+	}
+	return column.formatText(text)
+}
+
+func (column column) formatText(text string) string {
+	if len(text) > column.width {
+		text = text[len(text)-column.width:]
+	}
+	padding := strings.Repeat(" ", column.width-len(text))
+	if column.format == 'S' {
+		text += padding
+	} else {
+		text = padding + text
+	}
+	return strings.Repeat(" ", column.left) + text + strings.Repeat(" ", column.right)
+}
+
+// Returns the index of the column containing the given character of the
+// output line, the columns are separated by |
+func findColumn(columns []column, position int) int {
+	offset := 1
+	for i, column := range columns {
+		offset += column.size() + 1
+		if position < offset {
+			return i
+		}
+	}
+	return len(columns) - 1
+}
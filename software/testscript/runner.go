@@ -0,0 +1,386 @@
+package testscript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// While loops waiting for a condition which never happens, e.g. for a key
+// pressed by the user, are stopped after this number of iterations
+const MAX_LOOP_ITERATIONS = 100000
+
+// Simulator driven by the test script: the hardware simulator,
+// the CPU emulator or the VM emulator
+type Backend interface {
+	// Loads the file given by the load command, or the directory of the
+	// script if the command has no argument
+	Load(path string) error
+	// Returns the value of the variable, e.g. a pin, a register or RAM[5]
+	Get(variable string) (int, error)
+	// Sets the value of the variable
+	Set(variable string, value int) error
+	// Executes a simulation command: eval, tick, tock, ticktock or vmstep
+	Execute(command string) error
+	// Executes a command addressed to a built-in part, e.g. ROM32K load Add.hack,
+	// file names are relative to the given directory
+	ExecutePart(part string, args []string, directory string) error
+}
+
+// Executes the test script, writes the output table
+// and compares it line by line with the compare file
+type Runner struct {
+	script    *Script
+	backend   Backend
+	directory string
+	echo      io.Writer
+	file      *os.File
+	output    *bufio.Writer
+	columns   []column
+	lines     int
+	compare   []string
+	compareTo string
+	time      int
+	halfCycle bool
+}
+
+// Compare file contains a different line than the one printed by the script,
+// Column is empty if the compare file has fewer lines
+type ComparisonError struct {
+	FileName string
+	Line     int
+	Column   string
+	Expected string
+	Found    string
+	Position int
+}
+
+func (err *ComparisonError) Error() string {
+	if err.Column == "" {
+		return fmt.Sprintf("comparison failure at line %d of %s: the file has only %d lines", err.Line, err.FileName, err.Line-1)
+	}
+	return fmt.Sprintf("comparison failure at line %d of %s, column %s\n  expected: %s\n     found: %s\n            %s^",
+		err.Line, err.FileName, err.Column, err.Expected, err.Found, strings.Repeat(" ", err.Position))
+}
+
+// Creates the runner of the parsed script, messages of the echo command
+// are written to the given writer
+func NewRunner(script *Script, backend Backend, echo io.Writer) *Runner {
+	return &Runner{
+		script:    script,
+		backend:   backend,
+		directory: filepath.Dir(script.FileName),
+		echo:      echo,
+	}
+}
+
+// Executes all the commands of the script. Stops at the first error,
+// including the first line which differs from the compare file.
+func (runner *Runner) Run() error {
+	err := runner.execute(runner.script.Commands)
+	if closeErr := runner.closeOutput(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Parses and executes the given .tst file, returns the summary of the result
+func RunFile(fileName string, backend Backend, echo io.Writer) (string, error) {
+	script, err := ParseScriptFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	runner := NewRunner(script, backend, echo)
+	if err := runner.Run(); err != nil {
+		return "", err
+	}
+	if runner.compareTo == "" {
+		return "end of script", nil
+	}
+	return "comparison ended successfully", nil
+}
+
+func (runner *Runner) execute(commands []Command) error {
+	for _, command := range commands {
+		var err error
+		switch command.Name {
+		case "repeat":
+			err = runner.repeat(command)
+		case "while":
+			err = runner.while(command)
+		default:
+			if err = runner.executeCommand(command); err != nil {
+				err = fmt.Errorf("%s:%d: %w", runner.script.FileName, command.Line, err)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (runner *Runner) repeat(command Command) error {
+	if len(command.Args) != 1 {
+		return runner.errorf(command, "expected repeat <count> {")
+	}
+	count, err := strconv.Atoi(command.Args[0])
+	if err != nil || count < 0 {
+		return runner.errorf(command, "invalid count %s", command.Args[0])
+	}
+	for i := 0; i < count; i++ {
+		if err := runner.execute(command.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (runner *Runner) while(command Command) error {
+	if len(command.Args) != 3 {
+		return runner.errorf(command, "expected while <variable> <operator> <value> {")
+	}
+	for i := 0; ; i++ {
+		holds, err := runner.condition(command.Args[0], command.Args[1], command.Args[2])
+		if err != nil {
+			return runner.errorf(command, "%v", err)
+		}
+		if !holds {
+			return nil
+		}
+		if i == MAX_LOOP_ITERATIONS {
+			return runner.errorf(command, "loop did not end after %d iterations", MAX_LOOP_ITERATIONS)
+		}
+		if err := runner.execute(command.Body); err != nil {
+			return err
+		}
+	}
+}
+
+func (runner *Runner) condition(variable, operator, text string) (bool, error) {
+	value, err := runner.backend.Get(variable)
+	if err != nil {
+		return false, err
+	}
+	expected, err := ParseValue(text)
+	if err != nil {
+		return false, err
+	}
+	left, right := int16(value), int16(expected)
+	switch operator {
+	case "=":
+		return left == right, nil
+	case "<>":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "<=":
+		return left <= right, nil
+	case ">=":
+		return left >= right, nil
+	}
+	return false, fmt.Errorf("invalid operator %s", operator)
+}
+
+func (runner *Runner) executeCommand(command Command) error {
+	args := command.Args
+	switch command.Name {
+	case "load":
+		if len(args) > 1 {
+			return fmt.Errorf("expected load [file name]")
+		}
+		path := runner.directory
+		if len(args) == 1 {
+			path = filepath.Join(runner.directory, args[0])
+		}
+		runner.time, runner.halfCycle = 0, false
+		return runner.backend.Load(path)
+	case "output-file":
+		if len(args) != 1 {
+			return fmt.Errorf("expected output-file <file name>")
+		}
+		return runner.openOutput(args[0])
+	case "compare-to":
+		if len(args) != 1 {
+			return fmt.Errorf("expected compare-to <file name>")
+		}
+		return runner.readCompareFile(args[0])
+	case "output-list":
+		if len(args) == 0 {
+			return fmt.Errorf("expected output-list <columns>")
+		}
+		runner.columns = runner.columns[:0]
+		for _, spec := range args {
+			column, err := parseColumn(spec)
+			if err != nil {
+				return err
+			}
+			runner.columns = append(runner.columns, column)
+		}
+		header := "|"
+		for _, column := range runner.columns {
+			header += column.header() + "|"
+		}
+		return runner.writeLine(header)
+	case "output":
+		if len(args) != 0 {
+			return fmt.Errorf("output has no arguments")
+		}
+		return runner.writeValues()
+	case "set":
+		if len(args) != 2 {
+			return fmt.Errorf("expected set <variable> <value>")
+		}
+		value, err := ParseValue(args[1])
+		if err != nil {
+			return err
+		}
+		return runner.backend.Set(args[0], value)
+	case "eval", "tick", "tock", "ticktock", "vmstep":
+		if len(args) != 0 {
+			return fmt.Errorf("%s has no arguments", command.Name)
+		}
+		if err := runner.backend.Execute(command.Name); err != nil {
+			return err
+		}
+		runner.advanceTime(command.Name)
+		return nil
+	case "echo":
+		_, err := fmt.Fprintln(runner.echo, strings.Join(args, " "))
+		return err
+	case "clear-echo", "breakpoint", "clear-breakpoints":
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("unknown command %s", command.Name)
+	}
+	return runner.backend.ExecutePart(command.Name, args, runner.directory)
+}
+
+// The clock of the hardware simulator, shown in the time column
+// as 0, 0+, 1, 1+, ... where + marks the time after tick
+func (runner *Runner) advanceTime(command string) {
+	switch command {
+	case "tick":
+		runner.halfCycle = true
+	case "tock":
+		runner.time++
+		runner.halfCycle = false
+	case "ticktock":
+		runner.time++
+	}
+}
+
+func (runner *Runner) writeValues() error {
+	if len(runner.columns) == 0 {
+		return fmt.Errorf("output-list not specified")
+	}
+	line := "|"
+	for _, column := range runner.columns {
+		if column.name == "time" {
+			time := strconv.Itoa(runner.time)
+			if runner.halfCycle {
+				time += "+"
+			}
+			line += column.formatText(time) + "|"
+			continue
+		}
+		value, err := runner.backend.Get(column.name)
+		if err != nil {
+			return err
+		}
+		line += column.formatValue(value) + "|"
+	}
+	return runner.writeLine(line)
+}
+
+// Writes the line to the output file and compares it with the compare file
+func (runner *Runner) writeLine(line string) error {
+	if runner.output == nil {
+		return fmt.Errorf("output-file not specified")
+	}
+	if _, err := runner.output.WriteString(line + "\n"); err != nil {
+		return err
+	}
+	runner.lines++
+	if runner.compare == nil {
+		return nil
+	}
+	if runner.lines > len(runner.compare) {
+		return &ComparisonError{FileName: runner.compareTo, Line: runner.lines, Found: line}
+	}
+	expected := runner.compare[runner.lines-1]
+	if position := firstDifference(expected, line); position != -1 {
+		return &ComparisonError{
+			FileName: runner.compareTo,
+			Line:     runner.lines,
+			Column:   runner.columns[findColumn(runner.columns, position)].name,
+			Expected: expected,
+			Found:    line,
+			Position: position,
+		}
+	}
+	return nil
+}
+
+// Returns the position of the first character which differs or -1 if the
+// lines are equal. Character * of the compare file matches any character.
+func firstDifference(expected, found string) int {
+	for i := 0; i < len(expected) || i < len(found); i++ {
+		if i >= len(expected) || i >= len(found) || (expected[i] != found[i] && expected[i] != '*') {
+			return i
+		}
+	}
+	return -1
+}
+
+func (runner *Runner) openOutput(fileName string) error {
+	if err := runner.closeOutput(); err != nil {
+		return err
+	}
+	file, err := os.Create(filepath.Join(runner.directory, fileName))
+	if err != nil {
+		return err
+	}
+	runner.file = file
+	runner.output = bufio.NewWriter(file)
+	runner.lines = 0
+	return nil
+}
+
+func (runner *Runner) closeOutput() error {
+	if runner.file == nil {
+		return nil
+	}
+	err := runner.output.Flush()
+	if closeErr := runner.file.Close(); err == nil {
+		err = closeErr
+	}
+	runner.file, runner.output = nil, nil
+	return err
+}
+
+func (runner *Runner) readCompareFile(fileName string) error {
+	file, err := os.Open(filepath.Join(runner.directory, fileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	runner.compare = []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		runner.compare = append(runner.compare, strings.TrimRight(scanner.Text(), " \t\r"))
+	}
+	runner.compareTo = fileName
+	return scanner.Err()
+}
+
+func (runner *Runner) errorf(command Command, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", runner.script.FileName, command.Line, fmt.Sprintf(format, args...))
+}
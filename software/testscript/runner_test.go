@@ -0,0 +1,131 @@
+package testscript
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Adder with a counter: eval sets out to a+b, the clock adds out to the counter
+type adderBackend struct {
+	variables map[string]int
+}
+
+func (backend *adderBackend) Load(path string) error {
+	backend.variables = map[string]int{"a": 0, "b": 0, "out": 0, "counter": 0}
+	return nil
+}
+
+func (backend *adderBackend) Get(variable string) (int, error) {
+	value, ok := backend.variables[variable]
+	if !ok {
+		return 0, fmt.Errorf("unknown variable %s", variable)
+	}
+	return value, nil
+}
+
+func (backend *adderBackend) Set(variable string, value int) error {
+	if _, ok := backend.variables[variable]; !ok {
+		return fmt.Errorf("unknown variable %s", variable)
+	}
+	backend.variables[variable] = value
+	return nil
+}
+
+func (backend *adderBackend) Execute(command string) error {
+	switch command {
+	case "eval":
+		backend.variables["out"] = int(int16(backend.variables["a"] + backend.variables["b"]))
+	case "tock", "ticktock":
+		backend.variables["counter"] += backend.variables["out"]
+	}
+	return nil
+}
+
+func (backend *adderBackend) ExecutePart(part string, args []string, directory string) error {
+	return fmt.Errorf("unknown command %s", part)
+}
+
+const ADDER_SCRIPT = `load,
+output-file Adder.out,
+compare-to Adder.cmp,
+output-list time%S1.4.1 a%D1.6.1 b%X1.4.1 out%B1.16.1 counter%D1.3.1;
+set a 1, set b %B10, eval, output;
+tick, output;
+tock, output;
+set a -5, eval, output;
+while counter > 0 {
+    ticktock;
+}
+output;
+`
+
+var adderOutput = []string{
+	"| time |   a    |  b   |       out        |count|",
+	"| 0    |      1 | 0002 | 0000000000000011 |   0 |",
+	"| 0+   |      1 | 0002 | 0000000000000011 |   0 |",
+	"| 1    |      1 | 0002 | 0000000000000011 |   3 |",
+	"| 1    |     -5 | 0002 | 1111111111111101 |   3 |",
+	"| 2    |     -5 | 0002 | 1111111111111101 |   0 |",
+}
+
+// Runs the script with the compare file in a temporary directory
+func runAdderScript(t *testing.T, compare []string) error {
+	t.Helper()
+	directory := t.TempDir()
+	files := map[string]string{"Adder.tst": ADDER_SCRIPT, "Adder.cmp": strings.Join(compare, "\n") + "\n"}
+	for fileName, text := range files {
+		if err := os.WriteFile(filepath.Join(directory, fileName), []byte(text), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := RunFile(filepath.Join(directory, "Adder.tst"), &adderBackend{}, io.Discard)
+	if err == nil && result != "comparison ended successfully" {
+		t.Errorf("unexpected result %s", result)
+	}
+	output, readErr := os.ReadFile(filepath.Join(directory, "Adder.out"))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if err == nil && string(output) != files["Adder.cmp"] {
+		t.Errorf("expected the output\n%s\nfound\n%s", files["Adder.cmp"], output)
+	}
+	return err
+}
+
+// Checks the output of the script, which the compare file has to match
+func TestRunner(t *testing.T) {
+	if err := runAdderScript(t, adderOutput); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Checks the comparison failures, reported at the first difference
+func TestRunnerComparison(t *testing.T) {
+	changed := append([]string{}, adderOutput...)
+	changed[3] = "| 1    |      1 | 0002 | 0000000000000011 |   4 |"
+	for _, test := range []struct {
+		name     string
+		compare  []string
+		expected ComparisonError
+	}{
+		{"different value", changed, ComparisonError{Line: 4, Column: "counter", Expected: changed[3], Found: adderOutput[3], Position: 46}},
+		{"shorter compare file", adderOutput[:2], ComparisonError{Line: 3, Found: adderOutput[2]}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := runAdderScript(t, test.compare)
+			var comparison *ComparisonError
+			if !errors.As(err, &comparison) {
+				t.Fatalf("expected a comparison failure, found %v", err)
+			}
+			comparison.FileName = ""
+			if *comparison != test.expected {
+				t.Errorf("expected %+v, found %+v", test.expected, *comparison)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package testscript
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Single command of the test script. Commands repeat and while
+// hold the commands of their block in Body.
+type Command struct {
+	Name string
+	Args []string
+	Body []Command
+	Line int
+}
+
+// Parsed test script
+type Script struct {
+	FileName string
+	Commands []Command
+}
+
+// Variable of the simulator referenced by the script, e.g. out, RAM[5] or PC[].
+// Index is -1 if the brackets are empty.
+type Variable struct {
+	Name    string
+	Index   int
+	Indexed bool
+}
+
+type scriptToken struct {
+	text   string
+	line   int
+	quoted bool
+}
+
+type scriptParser struct {
+	fileName string
+	tokens   []scriptToken
+	position int
+}
+
+// Reads and parses the given .tst file
+func ParseScriptFile(fileName string) (*Script, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseScript(fileName, file)
+}
+
+// Parses the test script, the file name is used in the error messages
+func ParseScript(fileName string, reader io.Reader) (*Script, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokenizeScript(fileName, string(content))
+	if err != nil {
+		return nil, err
+	}
+	parser := &scriptParser{fileName: fileName, tokens: tokens}
+	commands, err := parser.parseCommands(0)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{FileName: fileName, Commands: commands}, nil
+}
+
+// Splits the script into words, quoted strings and the symbols , ; { }
+func tokenizeScript(fileName string, content string) ([]scriptToken, error) {
+	var tokens []scriptToken
+	line := 1
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(content[i:], "//"):
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end == -1 {
+				return nil, fmt.Errorf("%s:%d: unterminated comment", fileName, line)
+			}
+			line += strings.Count(content[i:i+end+4], "\n")
+			i += end + 4
+		case c == '"':
+			end := strings.IndexAny(content[i+1:], "\"\n")
+			if end == -1 || content[i+1+end] != '"' {
+				return nil, fmt.Errorf("%s:%d: unterminated string", fileName, line)
+			}
+			tokens = append(tokens, scriptToken{content[i+1 : i+1+end], line, true})
+			i += end + 2
+		case strings.IndexByte(",;{}", c) != -1:
+			tokens = append(tokens, scriptToken{string(c), line, false})
+			i++
+		default:
+			start := i
+			for i < len(content) && strings.IndexByte(" \t\r\n,;{}\"", content[i]) == -1 &&
+				!strings.HasPrefix(content[i:], "//") && !strings.HasPrefix(content[i:], "/*") {
+				i++
+			}
+			tokens = append(tokens, scriptToken{content[start:i], line, false})
+		}
+	}
+	return tokens, nil
+}
+
+// Parses the commands up to the end of the script or, inside a block,
+// up to the closing bracket. Commands end with a comma or a semicolon.
+func (parser *scriptParser) parseCommands(blockLine int) ([]Command, error) {
+	var commands []Command
+	for parser.position < len(parser.tokens) {
+		token := parser.tokens[parser.position]
+		parser.position++
+		if parser.isSymbol(token, "}") {
+			if blockLine == 0 {
+				return nil, fmt.Errorf("%s:%d: unexpected }", parser.fileName, token.line)
+			}
+			return commands, nil
+		}
+		if parser.isSymbol(token, ",") || parser.isSymbol(token, ";") {
+			continue
+		}
+		if parser.isSymbol(token, "{") || token.quoted {
+			return nil, fmt.Errorf("%s:%d: expected command, found %s", parser.fileName, token.line, token.text)
+		}
+
+		command := Command{Name: token.text, Line: token.line}
+		for parser.position < len(parser.tokens) {
+			token := parser.tokens[parser.position]
+			if parser.isDelimiter(token) {
+				break
+			}
+			command.Args = append(command.Args, token.text)
+			parser.position++
+		}
+		if command.Name == "repeat" || command.Name == "while" {
+			if parser.position == len(parser.tokens) || !parser.isSymbol(parser.tokens[parser.position], "{") {
+				return nil, fmt.Errorf("%s:%d: expected { after %s", parser.fileName, command.Line, command.Name)
+			}
+			parser.position++
+			body, err := parser.parseCommands(command.Line)
+			if err != nil {
+				return nil, err
+			}
+			command.Body = body
+		}
+		commands = append(commands, command)
+	}
+	if blockLine != 0 {
+		return nil, fmt.Errorf("%s:%d: block is not closed with }", parser.fileName, blockLine)
+	}
+	return commands, nil
+}
+
+func (parser *scriptParser) isSymbol(token scriptToken, symbol string) bool {
+	return !token.quoted && token.text == symbol
+}
+
+func (parser *scriptParser) isDelimiter(token scriptToken) bool {
+	return !token.quoted && len(token.text) == 1 && strings.Contains(",;{}", token.text)
+}
+
+// Parses the variable name with an optional index in brackets
+func ParseVariable(text string) (Variable, error) {
+	open := strings.Index(text, "[")
+	if open == -1 {
+		return Variable{Name: text, Index: -1}, nil
+	}
+	if open == 0 || !strings.HasSuffix(text, "]") {
+		return Variable{}, fmt.Errorf("invalid variable %s", text)
+	}
+	variable := Variable{Name: text[:open], Index: -1, Indexed: true}
+	if index := text[open+1 : len(text)-1]; index != "" {
+		value, err := strconv.Atoi(index)
+		if err != nil || value < 0 {
+			return Variable{}, fmt.Errorf("invalid index of %s", text)
+		}
+		variable.Index = value
+	}
+	return variable, nil
+}
+
+// Parses the value given in the script: decimal number or a number
+// prefixed with %B (binary), %X (hexadecimal) or %D (decimal)
+func ParseValue(text string) (int, error) {
+	base := 10
+	digits := text
+	if len(text) > 2 && text[0] == '%' {
+		switch text[1] {
+		case 'B':
+			base = 2
+		case 'X':
+			base = 16
+		case 'D':
+		default:
+			return 0, fmt.Errorf("invalid value %s", text)
+		}
+		digits = text[2:]
+	}
+	value, err := strconv.ParseInt(digits, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %s", text)
+	}
+	return int(value), nil
+}
+
+// Parses the range of addresses given as first-last or a single address,
+// e.g. the RAM range printed by the emulators
+func ParseRange(text string) (int, int, error) {
+	bounds := strings.SplitN(text, "-", 2)
+	first, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q", text)
+	}
+	last := first
+	if len(bounds) == 2 {
+		if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+			return 0, 0, fmt.Errorf("invalid range %q", text)
+		}
+	}
+	return first, last, nil
+}
@@ -0,0 +1,122 @@
+package testscript
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Checks the commands, their arguments and lines and the blocks of the loops
+func TestParseScript(t *testing.T) {
+	script, err := ParseScript("Test.tst", strings.NewReader(`// comment
+load Add.hdl,
+output-list a%B1.16.1 out%D1.6.1;
+/* block
+   comment */
+repeat 2 {
+    set a %X7FFF, eval, output;
+}
+while out <> 0 {
+    tick; tock;
+}
+echo "two words";
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Command{
+		{Name: "load", Args: []string{"Add.hdl"}, Line: 2},
+		{Name: "output-list", Args: []string{"a%B1.16.1", "out%D1.6.1"}, Line: 3},
+		{Name: "repeat", Args: []string{"2"}, Line: 6, Body: []Command{
+			{Name: "set", Args: []string{"a", "%X7FFF"}, Line: 7},
+			{Name: "eval", Args: []string{}, Line: 7},
+			{Name: "output", Args: []string{}, Line: 7},
+		}},
+		{Name: "while", Args: []string{"out", "<>", "0"}, Line: 9, Body: []Command{
+			{Name: "tick", Args: []string{}, Line: 10},
+			{Name: "tock", Args: []string{}, Line: 10},
+		}},
+		{Name: "echo", Args: []string{"two words"}, Line: 12},
+	}
+	// Compared as text, the empty arguments and bodies may be nil
+	if fmt.Sprintf("%+v", script.Commands) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("expected\n%+v\nfound\n%+v", expected, script.Commands)
+	}
+}
+
+// Checks the variables with and without an index
+func TestParseVariable(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		expected Variable
+		err      string
+	}{
+		{"out", Variable{Name: "out", Index: -1}, ""},
+		{"RAM[5]", Variable{Name: "RAM", Index: 5, Indexed: true}, ""},
+		{"PC[]", Variable{Name: "PC", Index: -1, Indexed: true}, ""},
+		{"[5]", Variable{}, "invalid variable [5]"},
+		{"RAM[5", Variable{}, "invalid variable RAM[5"},
+		{"RAM[-1]", Variable{}, "invalid index of RAM[-1]"},
+		{"RAM[x]", Variable{}, "invalid index of RAM[x]"},
+	} {
+		t.Run(test.text, func(t *testing.T) {
+			variable, err := ParseVariable(test.text)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("expected %s, found %v", test.err, err)
+				}
+			} else if err != nil || variable != test.expected {
+				t.Errorf("expected %+v, found %+v %v", test.expected, variable, err)
+			}
+		})
+	}
+}
+
+// Checks the values in the formats of the scripts
+func TestParseValue(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		expected int
+		err      bool
+	}{
+		{"42", 42, false},
+		{"-1", -1, false},
+		{"%B101", 5, false},
+		{"%XFFFF", 0xFFFF, false},
+		{"%D-7", -7, false},
+		{"%Q1", 0, true},
+		{"%B102", 0, true},
+		{"x", 0, true},
+	} {
+		t.Run(test.text, func(t *testing.T) {
+			value, err := ParseValue(test.text)
+			if (err != nil) != test.err || value != test.expected {
+				t.Errorf("expected %d (error %v), found %d %v", test.expected, test.err, value, err)
+			}
+		})
+	}
+}
+
+// Checks the RAM ranges given to the emulators
+func TestParseRange(t *testing.T) {
+	for _, test := range []struct {
+		text        string
+		first, last int
+		err         bool
+	}{
+		{"0-15", 0, 15, false},
+		{"256", 256, 256, false},
+		{"8000-8000", 8000, 8000, false},
+		{"15-0", 0, 0, true},
+		{"a-5", 0, 0, true},
+		{"5-", 0, 0, true},
+		{"", 0, 0, true},
+	} {
+		t.Run(test.text, func(t *testing.T) {
+			first, last, err := ParseRange(test.text)
+			if (err != nil) != test.err || first != test.first || last != test.last {
+				t.Errorf("expected %d-%d (error %v), found %d-%d %v", test.first, test.last, test.err, first, last, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"nand2tetris/software/testscript"
+	"nand2tetris/software/vmtranslator"
+)
+
+// Segments accessible to the test scripts as local[2], temp[0], ...
+var scriptSegments = map[string]vmtranslator.Segment{
+	"local":    vmtranslator.LOCAL,
+	"argument": vmtranslator.ARGUMENT,
+	"this":     vmtranslator.THIS,
+	"that":     vmtranslator.THAT,
+	"pointer":  vmtranslator.POINTER,
+	"temp":     vmtranslator.TEMP,
+}
+
+// Segment pointers accessible to the test scripts, e.g. set sp 256
+var scriptPointers = map[string]int{
+	"sp":       SP_ADDRESS,
+	"local":    LCL_ADDRESS,
+	"argument": ARG_ADDRESS,
+	"this":     THIS_ADDRESS,
+	"that":     THAT_ADDRESS,
+}
+
+// Runs the test scripts of the VM emulator, e.g. FibonacciElementVME.tst.
+// Like the Java VM emulator, the loaded program starts at Sys.init without
+// the bootstrap code, the script sets the stack and the segment pointers.
+type scriptBackend struct {
+	emulator *VMEmulator
+	useOS    bool
+}
+
+func (backend *scriptBackend) Load(path string) error {
+	emulator := NewVMEmulator()
+	if backend.useOS {
+		emulator.EnableOS()
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = emulator.LoadDirectory(path)
+	} else {
+		err = emulator.LoadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := emulator.Start(); err != nil {
+		return err
+	}
+	backend.emulator = emulator
+	return nil
+}
+
+func (backend *scriptBackend) Get(name string) (int, error) {
+	address, err := backend.getAddress(name)
+	if err != nil {
+		return 0, err
+	}
+	value, err := backend.emulator.Read(address)
+	return int(value), err
+}
+
+func (backend *scriptBackend) Set(name string, value int) error {
+	address, err := backend.getAddress(name)
+	if err != nil {
+		return err
+	}
+	return backend.emulator.Write(address, int16(value))
+}
+
+// Executes the next command unless the program has already finished.
+// Labels are not counted as commands, just like in the Java VM emulator.
+func (backend *scriptBackend) Execute(command string) error {
+	if backend.emulator == nil {
+		return fmt.Errorf("no program loaded")
+	}
+	if command != "vmstep" {
+		return fmt.Errorf("%s is not supported by the VM emulator", command)
+	}
+	emulator := backend.emulator
+	for first := true; !emulator.halted && emulator.pc < len(emulator.program); first = false {
+		if !first && emulator.program[emulator.pc].commandType != vmtranslator.LABEL {
+			break
+		}
+		if err := emulator.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (backend *scriptBackend) ExecutePart(part string, args []string, directory string) error {
+	return fmt.Errorf("unknown command %s", part)
+}
+
+// Returns the RAM address of the variable: RAM[address],
+// a segment pointer or an entry of a segment
+func (backend *scriptBackend) getAddress(name string) (int, error) {
+	if backend.emulator == nil {
+		return 0, fmt.Errorf("no program loaded")
+	}
+	variable, err := testscript.ParseVariable(name)
+	if err != nil {
+		return 0, err
+	}
+	if !variable.Indexed {
+		if address, has := scriptPointers[name]; has {
+			return address, nil
+		}
+	} else if variable.Index != -1 {
+		if variable.Name == "RAM" {
+			return variable.Index, nil
+		}
+		if segment, has := scriptSegments[variable.Name]; has {
+			return backend.emulator.getSegmentAddress(vmCommand{segment: segment, number: variable.Index})
+		}
+	}
+	return 0, fmt.Errorf("unknown variable %s", name)
+}
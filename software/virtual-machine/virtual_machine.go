@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"nand2tetris/software/testscript"
+)
+
+func main() {
+	useOS := flag.Bool("os", false, "provide native Jack OS functions missing from the directory")
+	steps := flag.Int("steps", 1000000, "maximal number of executed commands")
+	dump := flag.String("dump", "0-15", "range of RAM addresses printed after the execution, e.g. 256-270")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [options] name of the directory containg .vm files or of the .tst test script")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	directoryName := flag.Arg(0)
+	if strings.HasSuffix(directoryName, ".tst") {
+		result, err := testscript.RunFile(directoryName, &scriptBackend{useOS: *useOS}, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %s\n", directoryName, result)
+		return
+	}
+	emulate(directoryName, *useOS, *steps, *dump)
+}
+
+// Runs the program from the directory and prints the final state of the RAM
+func emulate(directoryName string, useOS bool, steps int, dump string) {
+	first, last, err := testscript.ParseRange(dump)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	emulator := NewVMEmulator()
+	if useOS {
+		emulator.EnableOS()
+	}
+	if err := emulator.LoadDirectory(directoryName); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := emulator.Bootstrap(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	halted, err := emulator.Run(steps)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if halted {
+		fmt.Printf("Halted after %d steps\n", emulator.Steps)
+	} else {
+		fmt.Printf("Stopped after %d steps\n", emulator.Steps)
+	}
+	for address := first; address <= last; address++ {
+		value, err := emulator.Read(address)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("RAM[%d]=%d\n", address, value)
+	}
+}
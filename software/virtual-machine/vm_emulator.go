@@ -0,0 +1,466 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/diagnostics"
+	"nand2tetris/software/vmtranslator"
+)
+
+const (
+	SP_ADDRESS     = 0
+	LCL_ADDRESS    = 1
+	ARG_ADDRESS    = 2
+	THIS_ADDRESS   = 3
+	THAT_ADDRESS   = 4
+	TEMP_ADDRESS   = 5
+	STATIC_ADDRESS = 16
+	STACK_ADDRESS  = 256
+	HEAP_ADDRESS   = 2048
+	SCREEN_ADDRESS = 0x4000
+	KBD_ADDRESS    = 0x6000
+)
+
+// Single command of the loaded program
+type vmCommand struct {
+	commandType vmtranslator.CommandType
+	arithmetic  vmtranslator.ArithmeticCommand
+	segment     vmtranslator.Segment
+	argument    string
+	number      int
+	address     int
+	function    string
+}
+
+// Function implemented natively by the emulator instead of the VM code.
+// Receives the popped arguments and returns the value pushed onto the stack.
+type builtinFunction func(emulator *VMEmulator, args []int16) (int16, error)
+
+// Executes Virtual Machine commands directly, without translating them into
+// the assembly. The stack, the segment pointers and the rest of the memory are
+// modelled in the RAM exactly as the code produced by the CodeWriter uses them,
+// so the state of the RAM can be compared with the results of translated code.
+type VMEmulator struct {
+	RAM       [KBD_ADDRESS + 1]int16
+	Steps     int
+	Output    io.Writer
+	Input     io.Reader
+	program   []vmCommand
+	functions map[string]int
+	labels    map[string]int
+	statics   map[string]int
+	builtins  map[string]builtinFunction
+	heap      *heap
+	pc        int
+	halted    bool
+	color     bool
+}
+
+// Creates new emulator without any program
+func NewVMEmulator() *VMEmulator {
+	return &VMEmulator{
+		Output:    os.Stdout,
+		Input:     os.Stdin,
+		functions: make(map[string]int),
+		labels:    make(map[string]int),
+		statics:   make(map[string]int),
+		builtins:  make(map[string]builtinFunction),
+	}
+}
+
+// Enables the native implementation of the Jack OS functions which are not
+// defined by the loaded program
+func (emulator *VMEmulator) EnableOS() {
+	for name, function := range osFunctions {
+		emulator.builtins[name] = function
+	}
+	emulator.heap = newHeap(HEAP_ADDRESS, SCREEN_ADDRESS)
+	emulator.color = true
+}
+
+// Appends commands from the given .vm file to the program
+func (emulator *VMEmulator) LoadFile(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	parser := vmtranslator.NewParser(file, fileName)
+	className := strings.TrimSuffix(filepath.Base(fileName), ".vm")
+	function := ""
+
+	for parser.Advance() {
+		command := vmCommand{commandType: parser.GetCommandType(), function: function}
+		switch command.commandType {
+		case vmtranslator.ARITHMETIC:
+			command.arithmetic = parser.GetArithmeticCommand()
+		case vmtranslator.PUSH, vmtranslator.POP:
+			command.segment = parser.GetSegment()
+			command.number = parser.GetSecondArgumentAsInt()
+			if command.segment == vmtranslator.STATIC {
+				command.address = emulator.getStaticAddress(className, command.number)
+			}
+		case vmtranslator.LABEL:
+			command.argument = parser.GetFirstArgument()
+			label := function + "$" + command.argument
+			if _, has := emulator.labels[label]; has {
+				parser.Diagnostics.Add(diagnostics.Errorf(parser.Position(1), command.argument, "label %s defined twice in %s", command.argument, function))
+				continue
+			}
+			emulator.labels[label] = len(emulator.program)
+		case vmtranslator.GOTO, vmtranslator.IF:
+			command.argument = parser.GetFirstArgument()
+		case vmtranslator.FUNCTION:
+			command.argument = parser.GetFirstArgument()
+			command.number = parser.GetSecondArgumentAsInt()
+			if _, has := emulator.functions[command.argument]; has {
+				parser.Diagnostics.Add(diagnostics.Errorf(parser.Position(1), command.argument, "function %s defined twice", command.argument))
+			}
+			function = command.argument
+			command.function = function
+			emulator.functions[function] = len(emulator.program)
+		case vmtranslator.CALL:
+			command.argument = parser.GetFirstArgument()
+			command.number = parser.GetSecondArgumentAsInt()
+		}
+		emulator.program = append(emulator.program, command)
+	}
+	return parser.Diagnostics.Err()
+}
+
+// Loads all the .vm files from the given directory
+func (emulator *VMEmulator) LoadDirectory(directoryName string) error {
+	files, err := filepath.Glob(filepath.Join(directoryName, "*.vm"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no .vm files in %s", directoryName)
+	}
+	for _, file := range files {
+		if err := emulator.LoadFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Starts the execution from Sys.init, or from Main.main if Sys.init is
+// provided by the OS, without pushing any frame onto the stack
+func (emulator *VMEmulator) Start() error {
+	if address, has := emulator.functions["Sys.init"]; has {
+		emulator.pc = address
+	} else if address, has := emulator.functions["Main.main"]; has && emulator.heap != nil {
+		emulator.pc = address
+	} else if len(emulator.program) == 0 {
+		return fmt.Errorf("no program loaded")
+	} else {
+		emulator.pc = 0
+	}
+	return nil
+}
+
+// Mimics the bootstrap code written by the CodeWriter: sets the SP to 256
+// and calls Sys.init (or Main.main when Sys.init is provided by the OS)
+func (emulator *VMEmulator) Bootstrap() error {
+	if err := emulator.Start(); err != nil {
+		return err
+	}
+	emulator.RAM[SP_ADDRESS] = STACK_ADDRESS
+	return emulator.pushFrame(-1, 0)
+}
+
+// True if the program has stopped, either by calling Sys.halt
+// or by entering an infinite loop like "label END, goto END"
+func (emulator *VMEmulator) IsHalted() bool {
+	if emulator.halted || emulator.pc >= len(emulator.program) {
+		return true
+	}
+	command := emulator.program[emulator.pc]
+	if command.commandType != vmtranslator.GOTO {
+		return false
+	}
+	target, has := emulator.labels[command.function+"$"+command.argument]
+	if !has || target > emulator.pc {
+		return false
+	}
+	for ; target < emulator.pc; target++ {
+		if emulator.program[target].commandType != vmtranslator.LABEL {
+			return false
+		}
+	}
+	return true
+}
+
+// Executes at most the given number of commands, stopping earlier when
+// the program halts. Returns true if the program halted.
+func (emulator *VMEmulator) Run(steps int) (bool, error) {
+	for i := 0; i < steps; i++ {
+		if emulator.IsHalted() {
+			return true, nil
+		}
+		if err := emulator.Step(); err != nil {
+			return false, err
+		}
+	}
+	return emulator.IsHalted(), nil
+}
+
+// Executes the current command
+func (emulator *VMEmulator) Step() error {
+	if emulator.pc < 0 || emulator.pc >= len(emulator.program) {
+		return fmt.Errorf("no command at %d", emulator.pc)
+	}
+	command := emulator.program[emulator.pc]
+	emulator.Steps++
+	emulator.pc++
+	var err error
+	switch command.commandType {
+	case vmtranslator.ARITHMETIC:
+		err = emulator.executeArithmetic(command.arithmetic)
+	case vmtranslator.PUSH:
+		var value int16
+		if value, err = emulator.readSegment(command); err == nil {
+			err = emulator.push(value)
+		}
+	case vmtranslator.POP:
+		var value int16
+		if value, err = emulator.pop(); err == nil {
+			err = emulator.writeSegment(command, value)
+		}
+	case vmtranslator.LABEL:
+	case vmtranslator.GOTO:
+		err = emulator.jump(command)
+	case vmtranslator.IF:
+		var value int16
+		if value, err = emulator.pop(); err == nil && value != 0 {
+			err = emulator.jump(command)
+		}
+	case vmtranslator.FUNCTION:
+		for i := 0; i < command.number && err == nil; i++ {
+			err = emulator.push(0)
+		}
+	case vmtranslator.CALL:
+		err = emulator.call(command.argument, command.number)
+	case vmtranslator.RETURN:
+		err = emulator.executeReturn()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", command.function, err)
+	}
+	return nil
+}
+
+// Returns the value stored at the given address
+func (emulator *VMEmulator) Read(address int) (int16, error) {
+	if address < 0 || address >= len(emulator.RAM) {
+		return 0, fmt.Errorf("invalid memory address %d", address)
+	}
+	return emulator.RAM[address], nil
+}
+
+// Stores the value at the given address
+func (emulator *VMEmulator) Write(address int, value int16) error {
+	if address < 0 || address >= len(emulator.RAM) {
+		return fmt.Errorf("invalid memory address %d", address)
+	}
+	emulator.RAM[address] = value
+	return nil
+}
+
+func (emulator *VMEmulator) executeArithmetic(command vmtranslator.ArithmeticCommand) error {
+	a, err := emulator.pop()
+	if err != nil {
+		return err
+	}
+	if command == vmtranslator.NEG {
+		return emulator.push(-a)
+	} else if command == vmtranslator.NOT {
+		return emulator.push(^a)
+	}
+	b, err := emulator.pop()
+	if err != nil {
+		return err
+	}
+	switch command {
+	case vmtranslator.ADD:
+		return emulator.push(b + a)
+	case vmtranslator.SUB:
+		return emulator.push(b - a)
+	case vmtranslator.AND:
+		return emulator.push(b & a)
+	case vmtranslator.OR:
+		return emulator.push(b | a)
+	case vmtranslator.EQ:
+		return emulator.push(boolean(b == a))
+	case vmtranslator.GT:
+		return emulator.push(boolean(b > a))
+	}
+	return emulator.push(boolean(b < a))
+}
+
+func (emulator *VMEmulator) jump(command vmCommand) error {
+	address, has := emulator.labels[command.function+"$"+command.argument]
+	if !has {
+		return fmt.Errorf("unknown label %s", command.argument)
+	}
+	emulator.pc = address
+	return nil
+}
+
+func (emulator *VMEmulator) call(functionName string, argNumber int) error {
+	if address, has := emulator.functions[functionName]; has {
+		if err := emulator.pushFrame(emulator.pc, argNumber); err != nil {
+			return err
+		}
+		emulator.pc = address
+		return nil
+	}
+	builtin, has := emulator.builtins[functionName]
+	if !has {
+		return fmt.Errorf("unknown function %s", functionName)
+	}
+	args := make([]int16, argNumber)
+	for i := argNumber - 1; i >= 0; i-- {
+		value, err := emulator.pop()
+		if err != nil {
+			return err
+		}
+		args[i] = value
+	}
+	result, err := builtin(emulator, args)
+	if err != nil {
+		return fmt.Errorf("%s: %v", functionName, err)
+	}
+	return emulator.push(result)
+}
+
+func (emulator *VMEmulator) pushFrame(returnAddress, argNumber int) error {
+	if err := emulator.push(int16(returnAddress)); err != nil {
+		return err
+	}
+	for _, pointer := range []int{LCL_ADDRESS, ARG_ADDRESS, THIS_ADDRESS, THAT_ADDRESS} {
+		if err := emulator.push(emulator.RAM[pointer]); err != nil {
+			return err
+		}
+	}
+	sp := emulator.RAM[SP_ADDRESS]
+	emulator.RAM[ARG_ADDRESS] = sp - 5 - int16(argNumber)
+	emulator.RAM[LCL_ADDRESS] = sp
+	return nil
+}
+
+func (emulator *VMEmulator) executeReturn() error {
+	frame := int(emulator.RAM[LCL_ADDRESS])
+	returnAddress, err := emulator.Read(frame - 5)
+	if err != nil {
+		return err
+	}
+	value, err := emulator.pop()
+	if err != nil {
+		return err
+	}
+	arg := int(emulator.RAM[ARG_ADDRESS])
+	if err := emulator.Write(arg, value); err != nil {
+		return err
+	}
+	emulator.RAM[SP_ADDRESS] = int16(arg + 1)
+	for i, pointer := range []int{THAT_ADDRESS, THIS_ADDRESS, ARG_ADDRESS, LCL_ADDRESS} {
+		if emulator.RAM[pointer], err = emulator.Read(frame - 1 - i); err != nil {
+			return err
+		}
+	}
+	if returnAddress < 0 || int(returnAddress) >= len(emulator.program) {
+		emulator.halted = true
+	}
+	emulator.pc = int(returnAddress)
+	return nil
+}
+
+func (emulator *VMEmulator) readSegment(command vmCommand) (int16, error) {
+	if command.segment == vmtranslator.CONSTANT {
+		if command.number < 0 || command.number > 0x7FFF {
+			return 0, fmt.Errorf("constant %d out of range", command.number)
+		}
+		return int16(command.number), nil
+	}
+	address, err := emulator.getSegmentAddress(command)
+	if err != nil {
+		return 0, err
+	}
+	return emulator.Read(address)
+}
+
+func (emulator *VMEmulator) writeSegment(command vmCommand, value int16) error {
+	if command.segment == vmtranslator.CONSTANT {
+		return fmt.Errorf("cannot pop to constant segment")
+	}
+	address, err := emulator.getSegmentAddress(command)
+	if err != nil {
+		return err
+	}
+	return emulator.Write(address, value)
+}
+
+func (emulator *VMEmulator) getSegmentAddress(command vmCommand) (int, error) {
+	index := command.number
+	switch command.segment {
+	case vmtranslator.ARGUMENT:
+		return int(emulator.RAM[ARG_ADDRESS]) + index, nil
+	case vmtranslator.LOCAL:
+		return int(emulator.RAM[LCL_ADDRESS]) + index, nil
+	case vmtranslator.THIS:
+		return int(emulator.RAM[THIS_ADDRESS]) + index, nil
+	case vmtranslator.THAT:
+		return int(emulator.RAM[THAT_ADDRESS]) + index, nil
+	case vmtranslator.POINTER:
+		if index < 0 || index > 1 {
+			return 0, fmt.Errorf("pointer %d out of range", index)
+		}
+		return THIS_ADDRESS + index, nil
+	case vmtranslator.TEMP:
+		if index < 0 || index > 7 {
+			return 0, fmt.Errorf("temp %d out of range", index)
+		}
+		return TEMP_ADDRESS + index, nil
+	}
+	return command.address, nil
+}
+
+// Static variables of each file are allocated in the order
+// of appearance, starting at address 16, just like the assembler does
+func (emulator *VMEmulator) getStaticAddress(className string, index int) int {
+	name := className + "." + strconv.Itoa(index)
+	address, has := emulator.statics[name]
+	if !has {
+		address = STATIC_ADDRESS + len(emulator.statics)
+		emulator.statics[name] = address
+	}
+	return address
+}
+
+func (emulator *VMEmulator) push(value int16) error {
+	sp := int(emulator.RAM[SP_ADDRESS])
+	if err := emulator.Write(sp, value); err != nil {
+		return fmt.Errorf("stack overflow")
+	}
+	emulator.RAM[SP_ADDRESS]++
+	return nil
+}
+
+func (emulator *VMEmulator) pop() (int16, error) {
+	emulator.RAM[SP_ADDRESS]--
+	return emulator.Read(int(emulator.RAM[SP_ADDRESS]))
+}
+
+func boolean(value bool) int16 {
+	if value {
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nand2tetris/software/testscript"
+)
+
+// Maximal number of the executed commands of the test programs
+const TEST_STEPS = 100000
+
+// Runs the VM emulator scripts of the examples in a temporary
+// directory, so that their outputs do not replace the tracked ones
+func TestVMExampleScripts(t *testing.T) {
+	for _, test := range []struct {
+		directory string
+		script    string
+	}{
+		{"FibonacciElement", "FibonacciElementVME.tst"},
+		{"NestedCall", "NestedCallVME.tst"},
+		{"StaticTest", "StaticsTestVME.tst"},
+	} {
+		t.Run(test.directory, func(t *testing.T) {
+			directory := filepath.Join("..", "virtual-machine-examples", test.directory)
+			temporary := t.TempDir()
+			fileNames, err := filepath.Glob(filepath.Join(directory, "*.vm"))
+			if err != nil || len(fileNames) == 0 {
+				t.Fatalf("no .vm files in %s: %v", directory, err)
+			}
+			compareFile := strings.TrimSuffix(test.script, "VME.tst") + ".cmp"
+			for _, fileName := range append(fileNames, filepath.Join(directory, test.script), filepath.Join(directory, compareFile)) {
+				data, err := os.ReadFile(fileName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(temporary, filepath.Base(fileName)), data, 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := testscript.RunFile(filepath.Join(temporary, test.script), &scriptBackend{}, io.Discard); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Runs the programs with the native OS functions and checks the memory
+func TestNativeOS(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		code     string
+		input    string
+		expected map[int]int16
+	}{
+		{"math", `function Main.main 0
+push constant 8000
+push constant 6
+push constant 7
+call Math.multiply 2
+push constant 3
+call Math.divide 2
+call Memory.poke 2
+pop temp 0
+push constant 8001
+push constant 10
+neg
+call Math.abs 1
+push constant 16
+call Math.sqrt 1
+call Math.max 2
+call Memory.poke 2
+pop temp 0
+push constant 0
+return
+`, "", map[int]int16{8000: 14, 8001: 10}},
+		{"strings", `function Main.main 1
+push constant 2
+call String.new 1
+push constant 104
+call String.appendChar 2
+push constant 105
+call String.appendChar 2
+pop local 0
+push constant 8000
+push local 0
+call String.length 1
+call Memory.poke 2
+pop temp 0
+push constant 8001
+push local 0
+push constant 1
+call String.charAt 2
+call Memory.poke 2
+pop temp 0
+push constant 0
+return
+`, "", map[int]int16{8000: 2, 8001: 'i'}},
+		{"halt loop", `function Main.main 0
+push constant 8000
+push constant 1
+pop temp 0
+pop pointer 1
+push temp 0
+pop that 0
+label END
+goto END
+`, "", map[int]int16{8000: 1}},
+		{"keyboard", `function Main.main 0
+push constant 8000
+push constant 0
+call String.new 1
+call Keyboard.readInt 1
+call Memory.poke 2
+pop temp 0
+push constant 8001
+push constant 0
+call String.new 1
+call Keyboard.readLine 1
+call String.length 1
+call Memory.poke 2
+pop temp 0
+push constant 8002
+call Keyboard.readChar 0
+call Memory.poke 2
+pop temp 0
+push constant 8003
+call Keyboard.readChar 0
+call Memory.poke 2
+pop temp 0
+label END
+goto END
+`, "42\nhi\nx\n", map[int]int16{8000: 42, 8001: 2, 8002: 'x', 8003: NEW_LINE}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "Main.vm")
+			if err := os.WriteFile(fileName, []byte(test.code), 0666); err != nil {
+				t.Fatal(err)
+			}
+			emulator := NewVMEmulator()
+			emulator.Input = strings.NewReader(test.input)
+			emulator.EnableOS()
+			if err := emulator.LoadFile(fileName); err != nil {
+				t.Fatal(err)
+			}
+			if err := emulator.Bootstrap(); err != nil {
+				t.Fatal(err)
+			}
+			halted, err := emulator.Run(TEST_STEPS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !halted {
+				t.Fatalf("expected the program to halt, stopped after %d steps", emulator.Steps)
+			}
+			for address, value := range test.expected {
+				if found, _ := emulator.Read(address); found != value {
+					t.Errorf("expected RAM[%d]=%d, found %d", address, value, found)
+				}
+			}
+		})
+	}
+}
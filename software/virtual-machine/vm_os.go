@@ -0,0 +1,678 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SCREEN_WIDTH  = 512
+	SCREEN_HEIGHT = 256
+	NEW_LINE      = 128
+	BACKSPACE     = 129
+	DOUBLE_QUOTE  = 34
+)
+
+// Native implementation of the Jack OS, used for the functions which are not
+// defined by the loaded program. Objects are allocated on the emulator's heap
+// and Strings keep the layout of software/os/String.jack (characters, length)
+// followed by the maximal length. The Output class writes the text to the
+// emulator's Output instead of drawing it on the screen, and the Keyboard
+// class reads the lines from the emulator's Input, which echoes them itself.
+var osFunctions = map[string]builtinFunction{
+	"Math.init":     osVoid,
+	"Math.abs":      mathAbs,
+	"Math.multiply": mathMultiply,
+	"Math.divide":   mathDivide,
+	"Math.min":      mathMin,
+	"Math.max":      mathMax,
+	"Math.sqrt":     mathSqrt,
+
+	"Memory.init":    osVoid,
+	"Memory.peek":    memoryPeek,
+	"Memory.poke":    memoryPoke,
+	"Memory.alloc":   memoryAlloc,
+	"Memory.deAlloc": memoryDeAlloc,
+
+	"Array.new":     memoryAlloc,
+	"Array.dispose": memoryDeAlloc,
+
+	"String.new":           stringNew,
+	"String.dispose":       stringDispose,
+	"String.length":        stringLength,
+	"String.charAt":        stringCharAt,
+	"String.setCharAt":     stringSetCharAt,
+	"String.appendChar":    stringAppendChar,
+	"String.eraseLastChar": stringEraseLastChar,
+	"String.intValue":      stringIntValue,
+	"String.setInt":        stringSetInt,
+	"String.newLine":       osConstant(NEW_LINE),
+	"String.backSpace":     osConstant(BACKSPACE),
+	"String.doubleQuote":   osConstant(DOUBLE_QUOTE),
+
+	"Output.init":        osVoid,
+	"Output.moveCursor":  osVoid,
+	"Output.printChar":   outputPrintChar,
+	"Output.printString": outputPrintString,
+	"Output.printInt":    outputPrintInt,
+	"Output.println":     outputPrintln,
+	"Output.backSpace":   outputBackSpace,
+
+	"Screen.init":          osVoid,
+	"Screen.clearScreen":   screenClear,
+	"Screen.setColor":      screenSetColor,
+	"Screen.drawPixel":     screenDrawPixel,
+	"Screen.drawLine":      screenDrawLine,
+	"Screen.drawRectangle": screenDrawRectangle,
+	"Screen.drawCircle":    screenDrawCircle,
+
+	"Keyboard.init":       osVoid,
+	"Keyboard.keyPressed": keyboardKeyPressed,
+	"Keyboard.readChar":   keyboardReadChar,
+	"Keyboard.readLine":   keyboardReadLine,
+	"Keyboard.readInt":    keyboardReadInt,
+
+	"Sys.halt":  sysHalt,
+	"Sys.wait":  osVoid,
+	"Sys.error": sysError,
+}
+
+func osVoid(emulator *VMEmulator, args []int16) (int16, error) {
+	return 0, nil
+}
+
+func osConstant(value int16) builtinFunction {
+	return func(emulator *VMEmulator, args []int16) (int16, error) {
+		return value, nil
+	}
+}
+
+func checkArguments(args []int16, count int) error {
+	if len(args) != count {
+		return fmt.Errorf("expected %d arguments, found %d", count, len(args))
+	}
+	return nil
+}
+
+func mathAbs(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	if args[0] < 0 {
+		return -args[0], nil
+	}
+	return args[0], nil
+}
+
+func mathMultiply(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	return args[0] * args[1], nil
+}
+
+func mathDivide(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	if args[1] == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return args[0] / args[1], nil
+}
+
+func mathMin(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	if args[0] < args[1] {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+func mathMax(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	if args[0] > args[1] {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+func mathSqrt(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	if args[0] < 0 {
+		return 0, fmt.Errorf("square root of negative number")
+	}
+	var root int16
+	for (root+1)*(root+1) <= args[0] && root < 181 {
+		root++
+	}
+	return root, nil
+}
+
+func memoryPeek(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	return emulator.Read(int(args[0]))
+}
+
+func memoryPoke(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	return 0, emulator.Write(int(args[0]), args[1])
+}
+
+func memoryAlloc(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	if args[0] <= 0 {
+		return 0, fmt.Errorf("allocated memory size must be positive")
+	}
+	address := emulator.heap.alloc(int(args[0]))
+	if address == 0 {
+		return 0, fmt.Errorf("heap overflow")
+	}
+	return int16(address), nil
+}
+
+func memoryDeAlloc(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	return 0, emulator.heap.deAlloc(int(args[0]))
+}
+
+func stringNew(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	if args[0] < 0 {
+		return 0, fmt.Errorf("maximum length must be non-negative")
+	}
+	object := emulator.heap.alloc(3)
+	characters := emulator.heap.alloc(int(args[0]) + 1)
+	if object == 0 || characters == 0 {
+		return 0, fmt.Errorf("heap overflow")
+	}
+	emulator.RAM[object] = int16(characters)
+	emulator.RAM[object+1] = 0
+	emulator.RAM[object+2] = args[0]
+	return int16(object), nil
+}
+
+func stringDispose(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	if err := emulator.heap.deAlloc(int(emulator.RAM[object])); err != nil {
+		return 0, err
+	}
+	return 0, emulator.heap.deAlloc(object)
+}
+
+func stringLength(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	return emulator.RAM[object+1], nil
+}
+
+func stringCharAt(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	if args[1] < 0 || args[1] >= emulator.RAM[object+1] {
+		return 0, fmt.Errorf("string index %d out of bounds", args[1])
+	}
+	return emulator.RAM[int(emulator.RAM[object])+int(args[1])], nil
+}
+
+func stringSetCharAt(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 3); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	if args[1] < 0 || args[1] >= emulator.RAM[object+1] {
+		return 0, fmt.Errorf("string index %d out of bounds", args[1])
+	}
+	emulator.RAM[int(emulator.RAM[object])+int(args[1])] = args[2]
+	return 0, nil
+}
+
+func stringAppendChar(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	length := emulator.RAM[object+1]
+	if length >= emulator.RAM[object+2] {
+		return 0, fmt.Errorf("string is full")
+	}
+	emulator.RAM[int(emulator.RAM[object])+int(length)] = args[1]
+	emulator.RAM[object+1]++
+	return args[0], nil
+}
+
+func stringEraseLastChar(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	if emulator.RAM[object+1] == 0 {
+		return 0, fmt.Errorf("string is empty")
+	}
+	emulator.RAM[object+1]--
+	return 0, nil
+}
+
+func stringIntValue(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	text, err := readString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	return parseInt(text), nil
+}
+
+// Converts the leading digits of the text, with an optional minus sign,
+// into a number like String.intValue, 0 if there are none
+func parseInt(text string) int16 {
+	end := 0
+	if end < len(text) && text[end] == '-' {
+		end++
+	}
+	for end < len(text) && text[end] >= '0' && text[end] <= '9' {
+		end++
+	}
+	value, _ := strconv.ParseInt(text[:end], 10, 16)
+	return int16(value)
+}
+
+func stringSetInt(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	text := strconv.Itoa(int(args[1]))
+	if len(text) > int(emulator.RAM[object+2]) {
+		return 0, fmt.Errorf("string is too short for %s", text)
+	}
+	characters := int(emulator.RAM[object])
+	for i, c := range text {
+		emulator.RAM[characters+i] = int16(c)
+	}
+	emulator.RAM[object+1] = int16(len(text))
+	return 0, nil
+}
+
+func outputPrintChar(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	return 0, printCharacters(emulator, args[0])
+}
+
+func outputPrintString(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	object, err := getString(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	characters := int(emulator.RAM[object])
+	length := int(emulator.RAM[object+1])
+	return 0, printCharacters(emulator, emulator.RAM[characters:characters+length]...)
+}
+
+func outputPrintInt(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	_, err := fmt.Fprint(emulator.Output, args[0])
+	return 0, err
+}
+
+func outputPrintln(emulator *VMEmulator, args []int16) (int16, error) {
+	return 0, printCharacters(emulator, NEW_LINE)
+}
+
+func outputBackSpace(emulator *VMEmulator, args []int16) (int16, error) {
+	return 0, printCharacters(emulator, BACKSPACE)
+}
+
+func screenClear(emulator *VMEmulator, args []int16) (int16, error) {
+	for address := SCREEN_ADDRESS; address < KBD_ADDRESS; address++ {
+		emulator.RAM[address] = 0
+	}
+	return 0, nil
+}
+
+func screenSetColor(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	emulator.color = args[0] != 0
+	return 0, nil
+}
+
+func screenDrawPixel(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 2); err != nil {
+		return 0, err
+	}
+	return 0, drawPixel(emulator, int(args[0]), int(args[1]))
+}
+
+func screenDrawLine(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 4); err != nil {
+		return 0, err
+	}
+	x1, y1, x2, y2 := int(args[0]), int(args[1]), int(args[2]), int(args[3])
+	dx, dy := abs(x2-x1), -abs(y2-y1)
+	sx, sy := sign(x2-x1), sign(y2-y1)
+	diff := dx + dy
+	for {
+		if err := drawPixel(emulator, x1, y1); err != nil {
+			return 0, err
+		}
+		if x1 == x2 && y1 == y2 {
+			return 0, nil
+		}
+		if 2*diff >= dy {
+			diff += dy
+			x1 += sx
+		}
+		if 2*diff <= dx {
+			diff += dx
+			y1 += sy
+		}
+	}
+}
+
+func screenDrawRectangle(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 4); err != nil {
+		return 0, err
+	}
+	for y := int(args[1]); y <= int(args[3]); y++ {
+		for x := int(args[0]); x <= int(args[2]); x++ {
+			if err := drawPixel(emulator, x, y); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+func screenDrawCircle(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 3); err != nil {
+		return 0, err
+	}
+	x, y, r := int(args[0]), int(args[1]), int(args[2])
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			if dx*dx+dy*dy <= r*r {
+				if err := drawPixel(emulator, x+dx, y+dy); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	return 0, nil
+}
+
+func keyboardKeyPressed(emulator *VMEmulator, args []int16) (int16, error) {
+	return emulator.RAM[KBD_ADDRESS], nil
+}
+
+// Reads the next character of the input, the end of a line is NEW_LINE
+func keyboardReadChar(emulator *VMEmulator, args []int16) (int16, error) {
+	c, err := readInputByte(emulator)
+	if err != nil {
+		return 0, err
+	}
+	if c == '\n' {
+		return NEW_LINE, nil
+	}
+	return int16(c), nil
+}
+
+// Prints the message and returns the next line of the input as a new String
+func keyboardReadLine(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	text, err := readInputLine(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	object, err := stringNew(emulator, []int16{int16(len(text))})
+	if err != nil {
+		return 0, err
+	}
+	characters := int(emulator.RAM[object])
+	for i := 0; i < len(text); i++ {
+		emulator.RAM[characters+i] = int16(text[i])
+	}
+	emulator.RAM[object+1] = int16(len(text))
+	return object, nil
+}
+
+// Prints the message and returns the number at the beginning of the next line
+func keyboardReadInt(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	text, err := readInputLine(emulator, args[0])
+	if err != nil {
+		return 0, err
+	}
+	return parseInt(strings.TrimSpace(text)), nil
+}
+
+// Prints the String message and reads a line of the input without its end,
+// the last line of the input may lack the end
+func readInputLine(emulator *VMEmulator, message int16) (string, error) {
+	prompt, err := readString(emulator, message)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(emulator.Output, prompt); err != nil {
+		return "", err
+	}
+	var line []byte
+	for {
+		c, err := readInputByte(emulator)
+		if err == errEndOfInput && len(line) > 0 {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if c == '\n' {
+			break
+		}
+		if c != '\r' {
+			line = append(line, c)
+		}
+	}
+	return string(line), nil
+}
+
+var errEndOfInput = errors.New("end of the input")
+
+// Reads a single byte, so that nothing is read ahead of the program
+func readInputByte(emulator *VMEmulator) (byte, error) {
+	var c [1]byte
+	if _, err := io.ReadFull(emulator.Input, c[:]); err == io.EOF {
+		return 0, errEndOfInput
+	} else if err != nil {
+		return 0, err
+	}
+	return c[0], nil
+}
+
+func sysHalt(emulator *VMEmulator, args []int16) (int16, error) {
+	emulator.halted = true
+	return 0, nil
+}
+
+func sysError(emulator *VMEmulator, args []int16) (int16, error) {
+	if err := checkArguments(args, 1); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("error code %d", args[0])
+}
+
+// Returns the address of the String object or an error
+// if the pointer does not point to the heap
+func getString(emulator *VMEmulator, pointer int16) (int, error) {
+	if !emulator.heap.isAllocated(int(pointer)) {
+		return 0, fmt.Errorf("invalid String %d", pointer)
+	}
+	return int(pointer), nil
+}
+
+func readString(emulator *VMEmulator, pointer int16) (string, error) {
+	object, err := getString(emulator, pointer)
+	if err != nil {
+		return "", err
+	}
+	characters := int(emulator.RAM[object])
+	text := make([]byte, emulator.RAM[object+1])
+	for i := range text {
+		text[i] = byte(emulator.RAM[characters+i])
+	}
+	return string(text), nil
+}
+
+func printCharacters(emulator *VMEmulator, characters ...int16) error {
+	text := make([]byte, 0, len(characters))
+	for _, c := range characters {
+		switch c {
+		case NEW_LINE:
+			text = append(text, '\n')
+		case BACKSPACE:
+			text = append(text, '\b')
+		default:
+			text = append(text, byte(c))
+		}
+	}
+	_, err := emulator.Output.Write(text)
+	return err
+}
+
+func drawPixel(emulator *VMEmulator, x, y int) error {
+	if x < 0 || x >= SCREEN_WIDTH || y < 0 || y >= SCREEN_HEIGHT {
+		return fmt.Errorf("illegal pixel coordinates (%d, %d)", x, y)
+	}
+	address := SCREEN_ADDRESS + y*SCREEN_WIDTH/16 + x/16
+	mask := int16(1) << uint(x%16)
+	if emulator.color {
+		emulator.RAM[address] |= mask
+	} else {
+		emulator.RAM[address] &^= mask
+	}
+	return nil
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func sign(value int) int {
+	if value < 0 {
+		return -1
+	} else if value > 0 {
+		return 1
+	}
+	return 0
+}
+
+// First fit allocator of the memory blocks in the given address range
+type heap struct {
+	free      map[int]int
+	allocated map[int]int
+}
+
+func newHeap(begin, end int) *heap {
+	return &heap{free: map[int]int{begin: end - begin}, allocated: make(map[int]int)}
+}
+
+// Returns the address of the allocated block or 0 if there is no free block
+func (heap *heap) alloc(size int) int {
+	addresses := make([]int, 0, len(heap.free))
+	for address := range heap.free {
+		addresses = append(addresses, address)
+	}
+	sort.Ints(addresses)
+	for _, address := range addresses {
+		if free := heap.free[address]; free >= size {
+			delete(heap.free, address)
+			if free > size {
+				heap.free[address+size] = free - size
+			}
+			heap.allocated[address] = size
+			return address
+		}
+	}
+	return 0
+}
+
+func (heap *heap) deAlloc(address int) error {
+	size, has := heap.allocated[address]
+	if !has {
+		return fmt.Errorf("address %d was not allocated", address)
+	}
+	delete(heap.allocated, address)
+	heap.free[address] = size
+	if next, has := heap.free[address+size]; has {
+		heap.free[address] = size + next
+		delete(heap.free, address+size)
+	}
+	return nil
+}
+
+func (heap *heap) isAllocated(address int) bool {
+	_, has := heap.allocated[address]
+	return has
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"nand2tetris/software/vmtranslator"
+)
+
+func main() {
+	optimize := flag.Bool("O", false, "optimize the translated code and report the number of saved instructions")
+	shared := flag.Bool("shared", false, "call shared routines for calls, returns and comparisons instead of inlining them")
+	treeShake := flag.Bool("tree-shake", false, "leave out the functions which are never called from Sys.init and list the kept and the removed ones")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [options] name of the directory containg .vm files")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := translate(flag.Arg(0), vmtranslator.Options{Optimize: *optimize, SharedRoutines: *shared, TreeShake: *treeShake}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Translates all the .vm files from the directory into a single .asm file
+// named after the directory, the .asm file is written only if there are no errors
+func translate(directoryName string, options vmtranslator.Options) error {
+	entries, err := os.ReadDir(directoryName)
+	if err != nil {
+		return err
+	}
+
+	var sources []vmtranslator.Source
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".vm" {
+			continue
+		}
+		fileName := filepath.Join(directoryName, entry.Name())
+		input, err := os.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, vmtranslator.Source{Name: fileName, Reader: bytes.NewReader(input)})
+	}
+
+	var code bytes.Buffer
+	report, err := vmtranslator.TranslateWith(sources, &code, options)
+	if err != nil {
+		return err
+	}
+	if options.TreeShake {
+		fmt.Println(report.FunctionSummary())
+	}
+	if options.Optimize || options.SharedRoutines || options.TreeShake {
+		fmt.Println(report)
+	}
+	outputName := filepath.Base(filepath.Clean(directoryName)) + ".asm"
+	return os.WriteFile(filepath.Join(directoryName, outputName), code.Bytes(), 0666)
+}
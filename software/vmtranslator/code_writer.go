@@ -1,8 +1,8 @@
-package main
+package vmtranslator
 
 import (
-	"fmt"
-	"os"
+	"io"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -11,21 +11,20 @@ import (
 // parses it, and provides convenient access to the command’s components
 // (fields and symbols). In addition, removes all white space and comments.
 type CodeWriter struct {
-	file               *os.File
+	writer             io.Writer
 	labelPrefix        string
+	functionName       string
 	labelCount         int
 	commandTranslation map[ArithmeticCommand]string
 	segmentTranslation map[Segment]string
+	optimize           bool
+	pending            *pendingPush
+	sharedRoutines     bool
+	usedRoutines       map[string]bool
 }
 
-// Opens the input file
-func NewCodeWriter(fileName string) *CodeWriter {
-	file, err := os.Create(fileName)
-	if err != nil {
-		fmt.Println("Could not save file", fileName)
-		os.Exit(1)
-	}
-
+// Creates the code writer writing the assembly code to the writer
+func NewCodeWriter(writer io.Writer) *CodeWriter {
 	commandTranslation := make(map[ArithmeticCommand]string)
 	commandTranslation[NEG] = "M=-M"
 	commandTranslation[NOT] = "M=!M"
@@ -45,18 +44,15 @@ func NewCodeWriter(fileName string) *CodeWriter {
 	segmentTranslation[POINTER] = "@THIS"
 	segmentTranslation[TEMP] = "@R5"
 
-	return &CodeWriter{commandTranslation: commandTranslation, segmentTranslation: segmentTranslation, file: file}
-}
-
-// Closes the file
-func (codeWriter *CodeWriter) Close() error {
-	return codeWriter.file.Close()
+	return &CodeWriter{commandTranslation: commandTranslation, segmentTranslation: segmentTranslation, writer: writer, usedRoutines: make(map[string]bool)}
 }
 
-// Sets the file name of current parsing file
+// Sets the file name of current parsing file, the static variables are
+// named after the file name without the directory and the extension
 func (codeWriter *CodeWriter) SetFileName(fileName string) {
-	labelPrefix := strings.Replace(fileName, "/", "_", -1)
-	codeWriter.labelPrefix = labelPrefix
+	codeWriter.Flush()
+	codeWriter.labelPrefix = strings.TrimSuffix(filepath.Base(fileName), ".vm")
+	codeWriter.functionName = ""
 }
 
 // Writes bootsrap
@@ -72,6 +68,14 @@ func (codeWriter *CodeWriter) WriteInit() {
 
 // Writes the assembly code that is the translation of the given ARITHMETIC command
 func (codeWriter *CodeWriter) WriteArithmetic(command ArithmeticCommand) {
+	if codeWriter.sharedRoutines && (command == EQ || command == GT || command == LT) {
+		codeWriter.writeSharedComparison(command)
+		return
+	}
+	if codeWriter.optimize {
+		codeWriter.writeOptimizedArithmetic(command)
+		return
+	}
 	// a = pop()
 	codeWriter.write("@SP")
 	if command == NEG || command == NOT {
@@ -109,6 +113,10 @@ func (codeWriter *CodeWriter) WriteArithmetic(command ArithmeticCommand) {
 
 // Writes the assembly code that is the translation of the given POP command
 func (codeWriter *CodeWriter) WritePop(segment Segment, index string) {
+	if codeWriter.optimize {
+		codeWriter.writeOptimizedPop(segment, index)
+		return
+	}
 	if segment == STATIC {
 		// *(static+index) = pop()
 		codeWriter.write("@SP")
@@ -140,6 +148,11 @@ func (codeWriter *CodeWriter) WritePop(segment Segment, index string) {
 
 // Writes the assembly code that is the translation of the given PUSH
 func (codeWriter *CodeWriter) WritePush(segment Segment, index string) {
+	if codeWriter.optimize {
+		codeWriter.Flush()
+		codeWriter.pending = &pendingPush{segment: segment, index: index}
+		return
+	}
 	if segment == STATIC {
 		// push(*(static+index))
 		codeWriter.write("@" + codeWriter.labelPrefix + "." + index)
@@ -167,8 +180,41 @@ func (codeWriter *CodeWriter) WritePush(segment Segment, index string) {
 
 // Writes the assembly code that is translation of call command
 func (codeWriter *CodeWriter) WriteCall(functionName string, argNumber int) {
+	codeWriter.Flush()
+	if codeWriter.sharedRoutines {
+		codeWriter.writeSharedCall(functionName, argNumber)
+		return
+	}
 	label := codeWriter.nextLabel()
-	codeWriter.WritePush(CONSTANT, label)
+	// push(Return_address)
+	codeWriter.write("@" + label)
+	codeWriter.write("D=A")
+	codeWriter.write("@SP")
+	codeWriter.write("M=M+1")
+	codeWriter.write("A=M-1")
+	codeWriter.write("M=D")
+	codeWriter.writeSaveFrame()
+	// ARG = SP - 5 - argNumber
+	codeWriter.write("@SP")
+	codeWriter.write("D=M")
+	codeWriter.write("@" + strconv.Itoa(5+argNumber))
+	codeWriter.write("D=D-A")
+	codeWriter.write("@ARG")
+	codeWriter.write("M=D")
+	// LCL = SP
+	codeWriter.write("@SP")
+	codeWriter.write("D=M")
+	codeWriter.write("@LCL")
+	codeWriter.write("M=D")
+	// goto functionName
+	codeWriter.write("@" + functionName)
+	codeWriter.write("0;JMP")
+	// (Return_address)
+	codeWriter.write("(" + label + ")")
+}
+
+// Pushes the segment pointers of the calling function
+func (codeWriter *CodeWriter) writeSaveFrame() {
 	// codeWriter.WritePush(CONSTANT, "LCL")
 	codeWriter.write("@LCL")
 	codeWriter.write("D=M")
@@ -197,27 +243,13 @@ func (codeWriter *CodeWriter) WriteCall(functionName string, argNumber int) {
 	codeWriter.write("M=M+1")
 	codeWriter.write("A=M-1")
 	codeWriter.write("M=D")
-	// ARG = SP - 5 - argNumber
-	codeWriter.write("@SP")
-	codeWriter.write("D=M")
-	codeWriter.write("@" + strconv.Itoa(5+argNumber))
-	codeWriter.write("D=D-A")
-	codeWriter.write("@ARG")
-	codeWriter.write("M=D")
-	// LCL = SP
-	codeWriter.write("@SP")
-	codeWriter.write("D=M")
-	codeWriter.write("@LCL")
-	codeWriter.write("M=D")
-	// goto functionName
-	codeWriter.WriteGoto(functionName)
-	// (Return_address)
-	codeWriter.WriteLabel(label)
 }
 
 // Writes the assembly code that is translation of function command
 func (codeWriter *CodeWriter) WriteFunction(functionName string, localNumber int) {
-	codeWriter.WriteLabel(functionName)
+	codeWriter.Flush()
+	codeWriter.functionName = functionName
+	codeWriter.write("(" + functionName + ")")
 	for i := 0; i < localNumber; i++ {
 		codeWriter.WritePush(CONSTANT, "0")
 	}
@@ -225,6 +257,16 @@ func (codeWriter *CodeWriter) WriteFunction(functionName string, localNumber int
 
 // Writes the assembly code that is translation of return command
 func (codeWriter *CodeWriter) WriteReturn() {
+	codeWriter.Flush()
+	if codeWriter.sharedRoutines {
+		codeWriter.writeSharedReturn()
+		return
+	}
+	codeWriter.writeRestoreFrame()
+}
+
+// Returns from the current function, restoring the segment pointers of the caller
+func (codeWriter *CodeWriter) writeRestoreFrame() {
 	// frame = LCL
 	codeWriter.write("@LCL")
 	codeWriter.write("D=M")
@@ -287,24 +329,39 @@ func (codeWriter *CodeWriter) WriteReturn() {
 
 // Writes the assembly code that is translation of if-goto command
 func (codeWriter *CodeWriter) WriteIf(label string) {
+	if codeWriter.optimize {
+		codeWriter.writeOptimizedIf(label)
+		return
+	}
 	// if pop() != 0 goto label
 	codeWriter.write("@SP")
 	codeWriter.write("AM=M-1")
 	codeWriter.write("D=M")
-	codeWriter.write("@" + label)
+	codeWriter.write("@" + codeWriter.getLabel(label))
 	codeWriter.write("D;JNE")
 }
 
 // Writes the assembly code that is translation of goto command
 func (codeWriter *CodeWriter) WriteGoto(label string) {
+	codeWriter.Flush()
 	// goto label
-	codeWriter.write("@" + label)
+	codeWriter.write("@" + codeWriter.getLabel(label))
 	codeWriter.write("0;JMP")
 }
 
 // Writes the assembly code that is translation of label command
 func (codeWriter *CodeWriter) WriteLabel(label string) {
-	codeWriter.write("(" + label + ")")
+	codeWriter.Flush()
+	codeWriter.write("(" + codeWriter.getLabel(label) + ")")
+}
+
+// Returns the label of the assembly code, the labels of the VM code
+// are local to the function and are named functionName$label
+func (codeWriter *CodeWriter) getLabel(label string) string {
+	if codeWriter.functionName == "" {
+		return label
+	}
+	return codeWriter.functionName + "$" + label
 }
 
 // Writes the comment
@@ -326,5 +383,5 @@ func (codeWriter *CodeWriter) writeSegmentTranslation(segment Segment) {
 }
 
 func (codeWriter *CodeWriter) write(command string) {
-	codeWriter.file.WriteString(command + "\n")
+	io.WriteString(codeWriter.writer, command+"\n")
 }
@@ -0,0 +1,334 @@
+package vmtranslator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Push waiting for the next command, which may take the pushed value
+// directly instead of through the stack
+type pendingPush struct {
+	segment Segment
+	index   string
+}
+
+// Base addresses of the segments placed at fixed addresses
+var fixedSegments = map[Segment]int{
+	POINTER: 3,
+	TEMP:    5,
+}
+
+// Entries of the segments given by pointers up to this index are addressed
+// by incrementing A, further entries by adding the index to the pointer
+const MAX_INCREMENTED_INDEX = 3
+
+// Enables the optimization of the generated code. Pushes are delayed until
+// the next command, so that pops, arithmetic commands and if-gotos take the
+// pushed value in D instead of through the stack.
+func (codeWriter *CodeWriter) SetOptimize(optimize bool) {
+	codeWriter.optimize = optimize
+}
+
+// Writes the push waiting for the next command
+func (codeWriter *CodeWriter) Flush() {
+	if push := codeWriter.takePending(); push != nil {
+		codeWriter.writeLoad(push.segment, push.index)
+		codeWriter.writePushD()
+	}
+}
+
+func (codeWriter *CodeWriter) takePending() *pendingPush {
+	push := codeWriter.pending
+	codeWriter.pending = nil
+	return push
+}
+
+func (codeWriter *CodeWriter) writeOptimizedPop(segment Segment, index string) {
+	if codeWriter.isAddressable(segment, index) {
+		codeWriter.writeOperand()
+		codeWriter.writeAddress(segment, index)
+		codeWriter.write("M=D")
+		return
+	}
+	// R13 = segment + index, computed before D holds the value
+	push := codeWriter.takePending()
+	codeWriter.write("@" + index)
+	codeWriter.write("D=A")
+	codeWriter.writeSegmentTranslation(segment)
+	codeWriter.write("D=M+D")
+	codeWriter.write("@R13")
+	codeWriter.write("M=D")
+	codeWriter.pending = push
+	codeWriter.writeOperand()
+	codeWriter.write("@R13")
+	codeWriter.write("A=M")
+	codeWriter.write("M=D")
+}
+
+func (codeWriter *CodeWriter) writeOptimizedArithmetic(command ArithmeticCommand) {
+	if command == NEG || command == NOT {
+		if push := codeWriter.takePending(); push != nil {
+			// push(command(value))
+			codeWriter.writeLoad(push.segment, push.index)
+			codeWriter.write(map[ArithmeticCommand]string{NEG: "D=-D", NOT: "D=!D"}[command])
+			codeWriter.writePushD()
+		} else {
+			codeWriter.write("@SP")
+			codeWriter.write("A=M-1")
+			codeWriter.writeCommandTranslation(command)
+		}
+		return
+	}
+	if push := codeWriter.pending; push != nil && push.segment == CONSTANT {
+		// a + 0, a - 0 and a | 0 do not change a, a + 1 and a - 1 need no D
+		if push.index == "0" && (command == ADD || command == SUB || command == OR) {
+			codeWriter.pending = nil
+			return
+		}
+		if push.index == "1" && (command == ADD || command == SUB) {
+			codeWriter.pending = nil
+			codeWriter.write("@SP")
+			codeWriter.write("A=M-1")
+			codeWriter.write(map[ArithmeticCommand]string{ADD: "M=M+1", SUB: "M=M-1"}[command])
+			return
+		}
+	}
+	// b = value or pop()
+	codeWriter.writeOperand()
+	codeWriter.write("@SP")
+	codeWriter.write("A=M-1")
+	if command == ADD || command == SUB || command == AND || command == OR {
+		codeWriter.writeCommandTranslation(command)
+		return
+	}
+	// a = command(a, b) ? -1 : 0
+	label := codeWriter.nextLabel()
+	codeWriter.write("D=M-D")
+	codeWriter.write("M=-1")
+	codeWriter.write("@" + label)
+	codeWriter.writeCommandTranslation(command)
+	codeWriter.write("@SP")
+	codeWriter.write("A=M-1")
+	codeWriter.write("M=0")
+	codeWriter.write("(" + label + ")")
+}
+
+func (codeWriter *CodeWriter) writeOptimizedIf(label string) {
+	// if value or pop() != 0 goto label
+	codeWriter.writeOperand()
+	codeWriter.write("@" + codeWriter.getLabel(label))
+	codeWriter.write("D;JNE")
+}
+
+// Loads the value of the pending push into D or pops it from the stack
+func (codeWriter *CodeWriter) writeOperand() {
+	if push := codeWriter.takePending(); push != nil {
+		codeWriter.writeLoad(push.segment, push.index)
+	} else {
+		codeWriter.write("@SP")
+		codeWriter.write("AM=M-1")
+		codeWriter.write("D=M")
+	}
+}
+
+// Loads the entry of the segment into D
+func (codeWriter *CodeWriter) writeLoad(segment Segment, index string) {
+	if segment == CONSTANT {
+		if index == "0" || index == "1" {
+			codeWriter.write("D=" + index)
+		} else {
+			codeWriter.write("@" + index)
+			codeWriter.write("D=A")
+		}
+		return
+	}
+	if codeWriter.isAddressable(segment, index) {
+		codeWriter.writeAddress(segment, index)
+	} else {
+		codeWriter.write("@" + index)
+		codeWriter.write("D=A")
+		codeWriter.writeSegmentTranslation(segment)
+		codeWriter.write("A=M+D")
+	}
+	codeWriter.write("D=M")
+}
+
+// Returns true if the address of the entry can be set to A without using D
+func (codeWriter *CodeWriter) isAddressable(segment Segment, index string) bool {
+	if segment == LOCAL || segment == ARGUMENT || segment == THIS || segment == THAT {
+		number, _ := strconv.Atoi(index)
+		return number <= MAX_INCREMENTED_INDEX
+	}
+	return segment != CONSTANT
+}
+
+// Sets A to the address of the entry of the segment, see isAddressable
+func (codeWriter *CodeWriter) writeAddress(segment Segment, index string) {
+	if segment == STATIC {
+		codeWriter.write("@" + codeWriter.labelPrefix + "." + index)
+		return
+	}
+	number, _ := strconv.Atoi(index)
+	if base, fixed := fixedSegments[segment]; fixed {
+		codeWriter.write("@" + strconv.Itoa(base+number))
+		return
+	}
+	codeWriter.writeSegmentTranslation(segment)
+	if number == 0 {
+		codeWriter.write("A=M")
+		return
+	}
+	codeWriter.write("A=M+1")
+	for i := 1; i < number; i++ {
+		codeWriter.write("A=A+1")
+	}
+}
+
+func (codeWriter *CodeWriter) writePushD() {
+	codeWriter.write("@SP")
+	codeWriter.write("M=M+1")
+	codeWriter.write("A=M-1")
+	codeWriter.write("M=D")
+}
+
+// Instructions setting A to an address computed from the memory,
+// which follow the A-instruction in writeAddress and similar code
+var addressInstructions = map[string]bool{
+	"A=M":   true,
+	"A=M+1": true,
+	"A=M-1": true,
+	"A=A+1": true,
+}
+
+// Peephole optimization of the assembly code, removes the jumps to the next
+// instruction, the values loaded into D right after they were stored from D
+// and the pushes popped right away.
+// Comments are kept, labels end the optimized sequences.
+func optimizeAssembly(code string) string {
+	lines := strings.Split(code, "\n")
+	for {
+		peephole := newPeephole(lines)
+		if !peephole.optimize() {
+			return strings.Join(lines, "\n")
+		}
+		lines = peephole.result()
+	}
+}
+
+type peephole struct {
+	lines   []string
+	code    []int // indexes of the lines with instructions and labels
+	removed map[int]bool
+}
+
+func newPeephole(lines []string) *peephole {
+	peephole := &peephole{lines: lines, removed: make(map[int]bool)}
+	for i, line := range lines {
+		if line != "" && !strings.HasPrefix(line, "//") {
+			peephole.code = append(peephole.code, i)
+		}
+	}
+	return peephole
+}
+
+// Returns the k-th instruction or label
+func (peephole *peephole) at(k int) string {
+	if k < 0 || k >= len(peephole.code) {
+		return ""
+	}
+	return peephole.lines[peephole.code[k]]
+}
+
+func (peephole *peephole) matches(k int, pattern ...string) bool {
+	for i, instruction := range pattern {
+		if peephole.at(k+i) != instruction {
+			return false
+		}
+	}
+	return true
+}
+
+func (peephole *peephole) remove(k, count int) {
+	for i := k; i < k+count; i++ {
+		peephole.removed[peephole.code[i]] = true
+	}
+}
+
+// Applies the rules once, sequences changed by a rule are not
+// changed by another rule until the next pass
+func (peephole *peephole) optimize() bool {
+	changed := false
+	for k, start := 0, 0; k < len(peephole.code); k++ {
+		if length := peephole.apply(k, start); length > 0 {
+			changed = true
+			k += length - 1
+			start = k + 1
+		}
+	}
+	return changed
+}
+
+// Applies the first matching rule at the k-th instruction and returns the
+// number of the instructions it covers. The rules may look back to start.
+func (peephole *peephole) apply(k, start int) int {
+	at := peephole.at
+	switch {
+	case strings.HasPrefix(at(k), "@") && at(k+1) == "0;JMP":
+		// goto next
+		for label := k + 2; strings.HasPrefix(at(label), "("); label++ {
+			if at(label) == "("+at(k)[1:]+")" {
+				peephole.remove(k, 2)
+				return 2
+			}
+		}
+	case peephole.matches(k, "@SP", "M=M+1", "A=M-1", "M=D", "@SP", "AM=M-1", "D=M"):
+		// push(D), D = pop()
+		peephole.remove(k, 7)
+		return 7
+	case at(k) == "M=D" && at(k+1) == "D=M":
+		// *address = D, D = *address
+		peephole.remove(k+1, 1)
+		return 2
+	case at(k) == "M=D":
+		// *address = D, the same address computed again, D = *address
+		address := k - 1
+		for address > start && addressInstructions[at(address)] {
+			address--
+		}
+		if address < start || !strings.HasPrefix(at(address), "@") {
+			return 0
+		}
+		length := k - address
+		for i := 0; i < length; i++ {
+			if at(k+1+i) != at(address+i) {
+				return 0
+			}
+		}
+		if at(k+1+length) == "D=M" {
+			peephole.remove(k+1, length+1)
+			return length + 2
+		}
+	}
+	return 0
+}
+
+func (peephole *peephole) result() []string {
+	lines := make([]string, 0, len(peephole.lines)-len(peephole.removed))
+	for i, line := range peephole.lines {
+		if !peephole.removed[i] {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Returns the number of the instructions of the assembly code
+func countInstructions(code string) int {
+	count := 0
+	for _, line := range strings.Split(code, "\n") {
+		if line != "" && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "(") {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,36 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+// Checks the rules of the peephole optimization, the instructions
+// are separated by spaces
+func TestPeephole(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"goto next", "@L 0;JMP (L) D=M", "(L) D=M"},
+		{"goto next after labels", "@L 0;JMP (K) (L) D=M", "(K) (L) D=M"},
+		{"goto elsewhere", "@L 0;JMP D=M (L)", "@L 0;JMP D=M (L)"},
+		{"push popped", "@SP M=M+1 A=M-1 M=D @SP AM=M-1 D=M @R13 M=D", "@R13 M=D"},
+		// !x is only 0 for x = -1, D;JEQ would jump for x = 0 instead
+		{"not tested by if-goto", "@SP A=M-1 M=!M @SP AM=M-1 D=M @L D;JNE", "@SP A=M-1 M=!M @SP AM=M-1 D=M @L D;JNE"},
+		{"load after store", "@5 M=D D=M", "@5 M=D"},
+		{"load of the same address", "@LCL A=M+1 M=D @LCL A=M+1 D=M", "@LCL A=M+1 M=D"},
+		{"load of another address", "@LCL A=M+1 M=D @ARG A=M+1 D=M", "@LCL A=M+1 M=D @ARG A=M+1 D=M"},
+		{"label between", "@5 M=D (L) D=M", "@5 M=D (L) D=M"},
+		{"comments kept", "@5 M=D //_comment D=M", "@5 M=D //_comment"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			code := strings.Join(strings.Fields(test.code), "\n")
+			expected := strings.Join(strings.Fields(test.expected), "\n")
+			if found := optimizeAssembly(code); found != expected {
+				t.Errorf("expected\n%s\nfound\n%s", expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package vmtranslator
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Encapsulates access to the input code. Reads an Virtual Machine command,
+// parses it, and provides convenient access to the command’s components
+// (fields and symbols). In addition, removes all white space and comments.
+// Malformed commands are recorded in Diagnostics and skipped.
+type Parser struct {
+	Diagnostics diagnostics.List
+	scanner     *bufio.Scanner
+	fileName    string
+	line        int
+	fields      []string
+	columns     []int
+}
+
+type CommandType int
+
+const (
+	ARITHMETIC CommandType = iota
+	PUSH       CommandType = iota
+	POP        CommandType = iota
+	LABEL      CommandType = iota
+	GOTO       CommandType = iota
+	IF         CommandType = iota
+	FUNCTION   CommandType = iota
+	RETURN     CommandType = iota
+	CALL       CommandType = iota
+)
+
+type ArithmeticCommand int
+
+const (
+	ADD ArithmeticCommand = iota
+	SUB ArithmeticCommand = iota
+	NEG ArithmeticCommand = iota
+	NOT ArithmeticCommand = iota
+	AND ArithmeticCommand = iota
+	OR  ArithmeticCommand = iota
+	EQ  ArithmeticCommand = iota
+	LT  ArithmeticCommand = iota
+	GT  ArithmeticCommand = iota
+)
+
+type Segment int
+
+const (
+	ARGUMENT Segment = iota
+	LOCAL    Segment = iota
+	THIS     Segment = iota
+	THAT     Segment = iota
+	POINTER  Segment = iota
+	TEMP     Segment = iota
+	STATIC   Segment = iota
+	CONSTANT Segment = iota
+)
+
+// Number of arguments of each command
+var argumentCounts = map[string]int{
+	"push":     2,
+	"pop":      2,
+	"label":    1,
+	"goto":     1,
+	"if-goto":  1,
+	"function": 2,
+	"call":     2,
+	"return":   0,
+	"add":      0,
+	"sub":      0,
+	"neg":      0,
+	"eq":       0,
+	"gt":       0,
+	"lt":       0,
+	"and":      0,
+	"or":       0,
+	"not":      0,
+}
+
+// Creates the parser of the input, the file name is used by the diagnostics
+func NewParser(reader io.Reader, fileName string) *Parser {
+	scanner := bufio.NewScanner(reader)
+	return &Parser{scanner: scanner, fileName: fileName}
+}
+
+// Reads the next command from the input and makes it current
+// Returns true if there are more commands in the input
+func (parser *Parser) Advance() bool {
+	for parser.scanner.Scan() {
+		parser.line++
+		parser.splitFields()
+		if len(parser.fields) > 0 && parser.validate() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the type of current command
+func (parser *Parser) GetCommandType() CommandType {
+	switch parser.fields[0] {
+	case "label":
+		return LABEL
+	case "goto":
+		return GOTO
+	case "if-goto":
+		return IF
+	case "function":
+		return FUNCTION
+	case "call":
+		return CALL
+	case "return":
+		return RETURN
+	case "pop":
+		return POP
+	case "push":
+		return PUSH
+	}
+	return ARITHMETIC
+}
+
+// Returns arithmetic command
+func (parser *Parser) GetArithmeticCommand() ArithmeticCommand {
+	return arithmeticCommands[parser.fields[0]]
+}
+
+func (parser *Parser) GetSegment() Segment {
+	return segments[parser.fields[1]]
+}
+
+// Returns the first argument of current command.
+// Should not be called if the current command is RETURN
+func (parser *Parser) GetFirstArgument() string {
+	return parser.fields[1]
+}
+
+// Returns the second argument of current command.
+func (parser *Parser) GetSecondArgumentAsInt() int {
+	text := parser.GetSecondArgument()
+	number, _ := strconv.Atoi(text)
+	return number
+}
+
+// Returns the second argument of current command.
+// Should be called only if the current command is PUSH, POP, FUNCTION or CALL.
+func (parser *Parser) GetSecondArgument() string {
+	return parser.fields[2]
+}
+
+func (parser *Parser) GetVMCommand() string {
+	return strings.Join(parser.fields, " ")
+}
+
+// Returns the position of the given field of the current command,
+// the field after the last one is the end of line
+func (parser *Parser) Position(field int) diagnostics.Position {
+	return diagnostics.Position{FileName: parser.fileName, Line: parser.line, Column: parser.columns[field]}
+}
+
+var arithmeticCommands = map[string]ArithmeticCommand{
+	"add": ADD,
+	"sub": SUB,
+	"and": AND,
+	"or":  OR,
+	"not": NOT,
+	"neg": NEG,
+	"eq":  EQ,
+	"gt":  GT,
+	"lt":  LT,
+}
+
+var segments = map[string]Segment{
+	"argument": ARGUMENT,
+	"local":    LOCAL,
+	"this":     THIS,
+	"that":     THAT,
+	"pointer":  POINTER,
+	"temp":     TEMP,
+	"static":   STATIC,
+	"constant": CONSTANT,
+}
+
+// Checks the number and the values of the arguments of the current command
+func (parser *Parser) validate() bool {
+	command := parser.fields[0]
+	count, known := argumentCounts[command]
+	if !known {
+		parser.Diagnostics.Add(diagnostics.Errorf(parser.Position(0), command, "unknown command %s", command))
+		return false
+	}
+	if len(parser.fields) > count+1 {
+		extra := parser.fields[count+1]
+		parser.Diagnostics.Add(diagnostics.Expected(parser.Position(count+1), extra, "end of line", diagnostics.Quote(extra)))
+		return false
+	}
+	if len(parser.fields) < count+1 {
+		parser.Diagnostics.Add(diagnostics.Expected(parser.Position(len(parser.fields)), "", parser.describeArgument(len(parser.fields)), "end of line"))
+		return false
+	}
+
+	switch parser.GetCommandType() {
+	case PUSH, POP:
+		segment, ok := segments[parser.fields[1]]
+		if !ok {
+			return parser.expected(1, "segment")
+		}
+		index, err := strconv.Atoi(parser.fields[2])
+		if err != nil || index < 0 {
+			return parser.expected(2, "non-negative integer")
+		}
+		if segment == CONSTANT && command == "pop" {
+			parser.Diagnostics.Add(diagnostics.Errorf(parser.Position(1), parser.fields[1], "cannot pop to constant segment"))
+			return false
+		}
+		if limit, has := segmentSizes[segment]; has && index >= limit {
+			parser.Diagnostics.Add(diagnostics.Errorf(parser.Position(2), parser.fields[2], "index %d out of range of %s segment", index, parser.fields[1]))
+			return false
+		}
+	case LABEL, GOTO, IF:
+		if !isSymbol(parser.fields[1]) {
+			return parser.expected(1, "label")
+		}
+	case FUNCTION, CALL:
+		if !isSymbol(parser.fields[1]) {
+			return parser.expected(1, "function name")
+		}
+		if count, err := strconv.Atoi(parser.fields[2]); err != nil || count < 0 {
+			return parser.expected(2, "non-negative integer")
+		}
+	}
+	return true
+}
+
+// Number of the entries of the fixed size segments
+var segmentSizes = map[Segment]int{
+	POINTER:  2,
+	TEMP:     8,
+	CONSTANT: 0x8000,
+}
+
+func (parser *Parser) expected(field int, description string) bool {
+	text := parser.fields[field]
+	parser.Diagnostics.Add(diagnostics.Expected(parser.Position(field), text, description, diagnostics.Quote(text)))
+	return false
+}
+
+func (parser *Parser) describeArgument(field int) string {
+	switch parser.fields[0] {
+	case "push", "pop":
+		if field == 1 {
+			return "segment"
+		}
+		return "index"
+	case "function":
+		if field == 1 {
+			return "function name"
+		}
+		return "number of local variables"
+	case "call":
+		if field == 1 {
+			return "function name"
+		}
+		return "number of arguments"
+	}
+	return "label"
+}
+
+// Labels and function names consist of letters, digits, _ . $ and :
+// and do not begin with a digit
+func isSymbol(text string) bool {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.IndexByte("_.$:", c) != -1
+		if !isLetter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Splits the current line without the comment into fields
+// and remembers the column of each field
+func (parser *Parser) splitFields() {
+	text := parser.scanner.Text()
+	if commentIndex := strings.Index(text, "//"); commentIndex != -1 {
+		text = text[:commentIndex]
+	}
+	parser.fields = parser.fields[:0]
+	parser.columns = parser.columns[:0]
+	for i := 0; i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' || text[i] == '\r' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(text) && text[i] != ' ' && text[i] != '\t' && text[i] != '\r' {
+			i++
+		}
+		parser.fields = append(parser.fields, text[start:i])
+		parser.columns = append(parser.columns, start+1)
+	}
+	parser.columns = append(parser.columns, len(strings.TrimRight(text, " \t\r"))+1)
+}
@@ -0,0 +1,137 @@
+package vmtranslator
+
+import "strconv"
+
+// Names of the shared comparison routines
+var comparisonRoutines = map[ArithmeticCommand]string{
+	EQ: "$$eq",
+	GT: "$$gt",
+	LT: "$$lt",
+}
+
+// Enables the shared routines. Instead of inlining the whole calling
+// sequence, calls and returns jump to the $$call and $$return routines
+// and comparisons to $$eq, $$gt and $$lt, which are written once by
+// WriteSharedRoutines. The stack frame is the same as with the inlined code.
+func (codeWriter *CodeWriter) SetSharedRoutines(sharedRoutines bool) {
+	codeWriter.sharedRoutines = sharedRoutines
+}
+
+func (codeWriter *CodeWriter) writeSharedCall(functionName string, argNumber int) {
+	codeWriter.usedRoutines["$$call"] = true
+	label := codeWriter.nextLabel()
+	// R14 = Return_address
+	codeWriter.write("@" + label)
+	codeWriter.write("D=A")
+	codeWriter.write("@R14")
+	codeWriter.write("M=D")
+	// R13 = 5 + argNumber
+	codeWriter.write("@" + strconv.Itoa(5+argNumber))
+	codeWriter.write("D=A")
+	codeWriter.write("@R13")
+	codeWriter.write("M=D")
+	// D = functionName
+	codeWriter.write("@" + functionName)
+	codeWriter.write("D=A")
+	codeWriter.write("@$$call")
+	codeWriter.write("0;JMP")
+	// (Return_address)
+	codeWriter.write("(" + label + ")")
+}
+
+func (codeWriter *CodeWriter) writeSharedReturn() {
+	codeWriter.usedRoutines["$$return"] = true
+	codeWriter.write("@$$return")
+	codeWriter.write("0;JMP")
+}
+
+func (codeWriter *CodeWriter) writeSharedComparison(command ArithmeticCommand) {
+	routine := comparisonRoutines[command]
+	codeWriter.usedRoutines[routine] = true
+	label := codeWriter.nextLabel()
+	// R14 = Return_address
+	codeWriter.write("@" + label)
+	codeWriter.write("D=A")
+	codeWriter.write("@R14")
+	codeWriter.write("M=D")
+	// D = a - b, b is popped
+	codeWriter.writeOperand()
+	codeWriter.write("@SP")
+	codeWriter.write("A=M-1")
+	codeWriter.write("D=M-D")
+	codeWriter.write("@" + routine)
+	codeWriter.write("0;JMP")
+	// (Return_address)
+	codeWriter.write("(" + label + ")")
+}
+
+// Writes the shared routines used by the translated code
+func (codeWriter *CodeWriter) WriteSharedRoutines() {
+	codeWriter.Flush()
+	if codeWriter.usedRoutines["$$call"] {
+		codeWriter.writeCallRoutine()
+	}
+	if codeWriter.usedRoutines["$$return"] {
+		codeWriter.WriteComment("shared return")
+		codeWriter.write("($$return)")
+		codeWriter.writeRestoreFrame()
+	}
+	for _, command := range []ArithmeticCommand{EQ, GT, LT} {
+		if routine := comparisonRoutines[command]; codeWriter.usedRoutines[routine] {
+			codeWriter.writeComparisonRoutine(command, routine)
+		}
+	}
+}
+
+// Calls the function given by D with the return address in R14
+// and the number of the arguments plus 5 in R13
+func (codeWriter *CodeWriter) writeCallRoutine() {
+	codeWriter.WriteComment("shared call")
+	codeWriter.write("($$call)")
+	codeWriter.write("@R15")
+	codeWriter.write("M=D")
+	// push(R14)
+	codeWriter.write("@R14")
+	codeWriter.write("D=M")
+	codeWriter.write("@SP")
+	codeWriter.write("M=M+1")
+	codeWriter.write("A=M-1")
+	codeWriter.write("M=D")
+	codeWriter.writeSaveFrame()
+	// ARG = SP - R13
+	codeWriter.write("@R13")
+	codeWriter.write("D=M")
+	codeWriter.write("@SP")
+	codeWriter.write("D=M-D")
+	codeWriter.write("@ARG")
+	codeWriter.write("M=D")
+	// LCL = SP
+	codeWriter.write("@SP")
+	codeWriter.write("D=M")
+	codeWriter.write("@LCL")
+	codeWriter.write("M=D")
+	// goto R15
+	codeWriter.write("@R15")
+	codeWriter.write("A=M")
+	codeWriter.write("0;JMP")
+}
+
+// Replaces the top of the stack by the result of comparing D = a - b
+// with zero and returns to R14
+func (codeWriter *CodeWriter) writeComparisonRoutine(command ArithmeticCommand, routine string) {
+	codeWriter.WriteComment("shared " + routine[2:])
+	codeWriter.write("(" + routine + ")")
+	// a = -1, unless the comparison fails
+	codeWriter.write("@SP")
+	codeWriter.write("A=M-1")
+	codeWriter.write("M=-1")
+	codeWriter.write("@" + routine + ".true")
+	codeWriter.writeCommandTranslation(command)
+	codeWriter.write("@SP")
+	codeWriter.write("A=M-1")
+	codeWriter.write("M=0")
+	codeWriter.write("(" + routine + ".true)")
+	codeWriter.write("@R14")
+	codeWriter.write("A=M")
+	codeWriter.write("0;JMP")
+}
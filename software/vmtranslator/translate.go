@@ -0,0 +1,153 @@
+package vmtranslator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"nand2tetris/software/diagnostics"
+)
+
+// Input of the translator. The name identifies the static variables and
+// the labels of the file and is used by the diagnostics.
+type Source struct {
+	Name   string
+	Reader io.Reader
+}
+
+// Settings of the translation
+type Options struct {
+	// Fuses the pushes with the following commands and removes
+	// the redundant instructions, see optimizeAssembly
+	Optimize bool
+	// Calls, returns and comparisons jump to shared routines
+	// instead of inlining the code, see SetSharedRoutines
+	SharedRoutines bool
+	// Leaves out the functions which are not reachable by the calls from
+	// Sys.init and lists the kept and the removed functions in the report
+	TreeShake bool
+}
+
+// Number of the instructions of the translated program
+type Report struct {
+	// Without the optimizations and the shared routines
+	Instructions int
+	// Written to the output
+	Optimized int
+	// Functions kept and removed by the tree shaking, in the order of the sources
+	Kept    []FunctionSize
+	Removed []FunctionSize
+}
+
+func (report Report) String() string {
+	saved := report.Instructions - report.Optimized
+	return fmt.Sprintf("%d instructions, %d (%.1f%%) fewer than the plain translation", report.Optimized, saved, 100*float64(saved)/float64(report.Instructions))
+}
+
+// Translates the sources into a single assembly program, starting with the
+// bootstrap code calling Sys.init. All the errors found in the sources are
+// returned together, nothing is written if there are any.
+func Translate(sources []Source, writer io.Writer) error {
+	_, err := TranslateWith(sources, writer, Options{})
+	return err
+}
+
+// Translates the sources like Translate with the given options and reports
+// the number of the instructions saved by the optimizations
+func TranslateWith(sources []Source, writer io.Writer, options Options) (Report, error) {
+	inputs := make([][]byte, len(sources))
+	for i, source := range sources {
+		input, err := io.ReadAll(source.Reader)
+		if err != nil {
+			return Report{}, err
+		}
+		inputs[i] = input
+	}
+
+	var functions []string
+	var reachable map[string]bool
+	if options.TreeShake {
+		functions, reachable = findReachableFunctions(sources, inputs)
+	}
+	code, err := translate(sources, inputs, options, reachable)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{Instructions: countInstructions(code), Optimized: countInstructions(code)}
+	if options.Optimize || options.SharedRoutines || options.TreeShake {
+		plain, _ := translate(sources, inputs, Options{}, nil)
+		report.Instructions = countInstructions(plain)
+	}
+	if options.TreeShake {
+		// The removed functions are measured in the translation of all the functions
+		all, _ := translate(sources, inputs, options, nil)
+		sizes := measureFunctions(all, functions)
+		for _, function := range functions {
+			size := FunctionSize{Name: function, Instructions: sizes[function]}
+			if reachable == nil || reachable[function] {
+				report.Kept = append(report.Kept, size)
+			} else {
+				report.Removed = append(report.Removed, size)
+			}
+		}
+	}
+	_, err = io.WriteString(writer, code)
+	return report, err
+}
+
+// Translates the sources, only the functions which are reachable
+// if they are not nil, and the code outside of the functions
+func translate(sources []Source, inputs [][]byte, options Options, reachable map[string]bool) (string, error) {
+	var code strings.Builder
+	codeWriter := NewCodeWriter(&code)
+	codeWriter.SetOptimize(options.Optimize)
+	codeWriter.SetSharedRoutines(options.SharedRoutines)
+	codeWriter.WriteInit()
+
+	var errors diagnostics.List
+	for i, source := range sources {
+		parser := NewParser(bytes.NewReader(inputs[i]), source.Name)
+		codeWriter.SetFileName(source.Name)
+
+		kept := true
+		for parser.Advance() {
+			if parser.GetCommandType() == FUNCTION {
+				kept = reachable == nil || reachable[parser.GetFirstArgument()]
+			}
+			if !kept {
+				continue
+			}
+			codeWriter.WriteComment(parser.GetVMCommand())
+			switch parser.GetCommandType() {
+			case ARITHMETIC:
+				codeWriter.WriteArithmetic(parser.GetArithmeticCommand())
+			case POP:
+				codeWriter.WritePop(parser.GetSegment(), parser.GetSecondArgument())
+			case PUSH:
+				codeWriter.WritePush(parser.GetSegment(), parser.GetSecondArgument())
+			case IF:
+				codeWriter.WriteIf(parser.GetFirstArgument())
+			case GOTO:
+				codeWriter.WriteGoto(parser.GetFirstArgument())
+			case LABEL:
+				codeWriter.WriteLabel(parser.GetFirstArgument())
+			case CALL:
+				codeWriter.WriteCall(parser.GetFirstArgument(), parser.GetSecondArgumentAsInt())
+			case FUNCTION:
+				codeWriter.WriteFunction(parser.GetFirstArgument(), parser.GetSecondArgumentAsInt())
+			case RETURN:
+				codeWriter.WriteReturn()
+			}
+		}
+		errors = append(errors, parser.Diagnostics...)
+	}
+	codeWriter.WriteSharedRoutines()
+	if len(errors) > 0 {
+		return "", errors
+	}
+	if options.Optimize {
+		return optimizeAssembly(code.String()), nil
+	}
+	return code.String(), nil
+}
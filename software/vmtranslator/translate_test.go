@@ -0,0 +1,64 @@
+package vmtranslator
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Directory with the example programs, each in its own directory
+const EXAMPLES_DIRECTORY = "../virtual-machine-examples"
+
+// Checks that the optimizations shrink the translation of the examples,
+// the programs still pass their scripts, see cpu-emulator
+func TestReport(t *testing.T) {
+	for _, directory := range []string{"FibonacciElement", "NestedCall", "StaticTest"} {
+		for _, test := range []struct {
+			name    string
+			options Options
+		}{
+			{"optimized", Options{Optimize: true}},
+			{"shared", Options{SharedRoutines: true}},
+			{"optimized shared", Options{Optimize: true, SharedRoutines: true}},
+		} {
+			t.Run(directory+"/"+test.name, func(t *testing.T) {
+				var code strings.Builder
+				report, err := TranslateWith(readSources(t, directory), &code, test.options)
+				if err != nil {
+					t.Fatal(err)
+				}
+				plain, err := TranslateWith(readSources(t, directory), io.Discard, Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if report.Instructions != plain.Optimized || report.Optimized != countInstructions(code.String()) {
+					t.Errorf("expected %d instructions of the plain translation and %d written, found %+v", plain.Optimized, countInstructions(code.String()), report)
+				}
+				if report.Optimized >= report.Instructions {
+					t.Errorf("expected fewer instructions than %d, found %d", report.Instructions, report.Optimized)
+				}
+			})
+		}
+	}
+}
+
+// Opens the .vm files of the example directory
+func readSources(t *testing.T, directoryName string) []Source {
+	t.Helper()
+	fileNames, err := filepath.Glob(filepath.Join(EXAMPLES_DIRECTORY, directoryName, "*.vm"))
+	if err != nil || len(fileNames) == 0 {
+		t.Fatalf("no .vm files in %s: %v", directoryName, err)
+	}
+	var sources []Source
+	for _, fileName := range fileNames {
+		file, err := os.Open(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { file.Close() })
+		sources = append(sources, Source{Name: fileName, Reader: file})
+	}
+	return sources
+}
@@ -0,0 +1,95 @@
+package vmtranslator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Number of the instructions of a function of the translated program
+type FunctionSize struct {
+	Name         string
+	Instructions int
+}
+
+// Returns the functions defined by the sources in their order and those
+// reachable by the calls from Sys.init, which is called by the bootstrap code.
+// The reachable functions are nil if Sys.init is not defined, then nothing
+// can be removed. Syntax errors are reported by the translation.
+func findReachableFunctions(sources []Source, inputs [][]byte) ([]string, map[string]bool) {
+	var functions []string
+	calls := make(map[string][]string)
+	for i, source := range sources {
+		parser := NewParser(bytes.NewReader(inputs[i]), source.Name)
+		function := ""
+		for parser.Advance() {
+			switch parser.GetCommandType() {
+			case FUNCTION:
+				function = parser.GetFirstArgument()
+				functions = append(functions, function)
+			case CALL:
+				calls[function] = append(calls[function], parser.GetFirstArgument())
+			}
+		}
+	}
+	defined := make(map[string]bool)
+	for _, function := range functions {
+		defined[function] = true
+	}
+	if !defined["Sys.init"] {
+		return functions, nil
+	}
+	reachable := make(map[string]bool)
+	var visit func(function string)
+	visit = func(function string) {
+		if reachable[function] {
+			return
+		}
+		reachable[function] = true
+		for _, callee := range calls[function] {
+			visit(callee)
+		}
+	}
+	visit("Sys.init")
+	return functions, reachable
+}
+
+// Returns the number of the instructions of each function in the assembly
+// code, from its label to the label of the next function or shared routine
+func measureFunctions(code string, functions []string) map[string]int {
+	labels := make(map[string]string)
+	for _, function := range functions {
+		labels["("+function+")"] = function
+	}
+	sizes := make(map[string]int)
+	function := ""
+	for _, line := range strings.Split(code, "\n") {
+		if name, isFunction := labels[line]; isFunction {
+			function = name
+		} else if strings.HasPrefix(line, "($$") {
+			function = ""
+		} else if function != "" && line != "" && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "(") {
+			sizes[function]++
+		}
+	}
+	return sizes
+}
+
+// Lists the kept and the removed functions with their numbers of instructions
+func (report Report) FunctionSummary() string {
+	var summary strings.Builder
+	for _, group := range []struct {
+		name      string
+		functions []FunctionSize
+	}{{"kept", report.Kept}, {"removed", report.Removed}} {
+		total := 0
+		for _, function := range group.functions {
+			total += function.Instructions
+		}
+		fmt.Fprintf(&summary, "%s %d functions, %d instructions\n", group.name, len(group.functions), total)
+		for _, function := range group.functions {
+			fmt.Fprintf(&summary, "  %-40s %6d\n", function.Name, function.Instructions)
+		}
+	}
+	return strings.TrimSuffix(summary.String(), "\n")
+}
@@ -0,0 +1,86 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+// Program whose Main.unused and Lib.dead are not reachable from Sys.init,
+// Lib.dead calls Lib.used which is kept for Main.main
+var unreachableFunctions = map[string]string{
+	"Sys.vm": `function Sys.init 0
+call Main.main 0
+label END
+goto END
+`,
+	"Main.vm": `function Main.main 0
+push constant 1
+call Lib.used 1
+return
+function Main.unused 0
+call Main.unused 0
+return
+`,
+	"Lib.vm": `function Lib.used 0
+push argument 0
+return
+function Lib.dead 0
+push constant 2
+call Lib.used 1
+return
+`,
+}
+
+// Checks the functions removed by the tree shaking
+func TestTreeShaking(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		files   []string
+		kept    []string
+		removed []string
+	}{
+		{"unreachable", []string{"Sys.vm", "Main.vm", "Lib.vm"},
+			[]string{"Sys.init", "Main.main", "Lib.used"}, []string{"Main.unused", "Lib.dead"}},
+		{"without Sys.init", []string{"Main.vm", "Lib.vm"},
+			[]string{"Main.main", "Main.unused", "Lib.used", "Lib.dead"}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var sources []Source
+			for _, fileName := range test.files {
+				sources = append(sources, Source{Name: fileName, Reader: strings.NewReader(unreachableFunctions[fileName])})
+			}
+			var code strings.Builder
+			report, err := TranslateWith(sources, &code, Options{TreeShake: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFunctions(t, "kept", report.Kept, test.kept)
+			checkFunctions(t, "removed", report.Removed, test.removed)
+			for _, function := range test.kept {
+				if !strings.Contains(code.String(), "("+function+")\n") {
+					t.Errorf("expected the code of %s", function)
+				}
+			}
+			for _, function := range test.removed {
+				if strings.Contains(code.String(), "("+function+")\n") {
+					t.Errorf("expected %s to be removed", function)
+				}
+			}
+		})
+	}
+}
+
+// Compares the names of the functions, which have their code measured
+func checkFunctions(t *testing.T, group string, found []FunctionSize, expected []string) {
+	t.Helper()
+	var names []string
+	for _, function := range found {
+		names = append(names, function.Name)
+		if function.Instructions == 0 {
+			t.Errorf("expected the instructions of %s", function.Name)
+		}
+	}
+	if strings.Join(names, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %s %v, found %v", group, expected, names)
+	}
+}